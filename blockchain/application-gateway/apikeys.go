@@ -0,0 +1,321 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// API key scopes. Sessions of issuer users carry all of them.
+const (
+	scopeIssue  = "issue"  // Create credentials and graduate details
+	scopeRevoke = "revoke" // Revoke, suspend and reinstate credentials
+	scopeList   = "list"   // List credentials and read graduate details
+	// Manage webhook subscriptions. A key with only this scope suits a verifier: it manages its own
+	// subscriptions to single credentials of the issuer.
+	scopeWebhooks = "webhooks"
+)
+
+var allScopes = []string{scopeIssue, scopeRevoke, scopeList, scopeWebhooks}
+
+const (
+	// Keys look like dvk_<id>_<secret>, the ID finds the record and the secret is checked against its hash
+	apiKeyPrefix      = "dvk_"
+	apiKeyIDBytes     = 8
+	apiKeySecretBytes = 32
+	// Lifetime of keys created without an expiry
+	defaultAPIKeyTTL = 90 * 24 * time.Hour
+)
+
+var errInvalidAPIKey = errors.New("invalid API key")
+
+// APIKey is a stored API key. Only the SHA-256 of its secret is kept, the key itself is shown once.
+type APIKey struct {
+	ID         string     `json:"id"`
+	IssuerID   string     `json:"issuerId"`
+	Name       string     `json:"name,omitempty"`
+	Scopes     []string   `json:"scopes"`
+	SecretHash string     `json:"secretHash,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	CreatedBy  string     `json:"createdBy"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+// Active reports whether the key is neither revoked nor expired
+func (k *APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && now.Before(k.ExpiresAt)
+}
+
+// redacted returns a copy without the secret hash, for responses
+func (k *APIKey) redacted() *APIKey {
+	clone := *k
+	clone.SecretHash = ""
+	clone.Scopes = slices.Clone(k.Scopes)
+	return &clone
+}
+
+// CreateAPIKeyRequest for POST /admin/issuers/:issuerId/api-keys
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1,dive,oneof=issue revoke list webhooks"`
+	ExpiresAt *time.Time `json:"expiresAt"` // Defaults to 90 days from now
+}
+
+// APIKeyStore keeps API keys, persisted to a JSON file
+type APIKeyStore struct {
+	mu   sync.RWMutex
+	path string
+	keys map[string]*APIKey
+}
+
+type apiKeyStoreFileContents struct {
+	Keys []*APIKey `json:"keys"`
+}
+
+// LoadAPIKeyStore opens the store at path. A missing file yields an empty store.
+func LoadAPIKeyStore(path string) (*APIKeyStore, error) {
+	store := &APIKeyStore{
+		path: path,
+		keys: make(map[string]*APIKey),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var contents apiKeyStoreFileContents
+	if err := json.Unmarshal(data, &contents); err != nil {
+		return nil, err
+	}
+	for _, key := range contents.Keys {
+		store.keys[key.ID] = key
+	}
+
+	return store, nil
+}
+
+// save writes the store to disk. Callers must hold the write lock.
+func (s *APIKeyStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	contents := apiKeyStoreFileContents{Keys: make([]*APIKey, 0, len(s.keys))}
+	for _, key := range s.keys {
+		contents.Keys = append(contents.Keys, key)
+	}
+	sort.Slice(contents.Keys, func(i, j int) bool { return contents.Keys[i].CreatedAt.Before(contents.Keys[j].CreatedAt) })
+
+	data, err := json.MarshalIndent(contents, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// Create generates a key for an issuer. It returns the stored record and the key, which cannot
+// be recovered later.
+func (s *APIKeyStore) Create(issuerID string, req CreateAPIKeyRequest, createdBy string) (*APIKey, string, error) {
+	now := time.Now().UTC()
+	expiresAt := now.Add(defaultAPIKeyTTL)
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(now) {
+			return nil, "", fmt.Errorf("expiresAt must be in the future")
+		}
+		expiresAt = req.ExpiresAt.UTC()
+	}
+
+	id, err := randomHex(apiKeyIDBytes)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := randomHex(apiKeySecretBytes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Scopes are kept in a fixed order without duplicates
+	var scopes []string
+	for _, scope := range allScopes {
+		if slices.Contains(req.Scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	key := &APIKey{
+		ID:         id,
+		IssuerID:   issuerID,
+		Name:       req.Name,
+		Scopes:     scopes,
+		SecretHash: hashAPIKeySecret(secret),
+		CreatedAt:  now,
+		CreatedBy:  createdBy,
+		ExpiresAt:  expiresAt,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[id] = key
+	if err := s.save(); err != nil {
+		delete(s.keys, id)
+		return nil, "", err
+	}
+
+	return key.redacted(), apiKeyPrefix + id + "_" + secret, nil
+}
+
+// List returns an issuer's keys, including revoked and expired ones, oldest first
+func (s *APIKeyStore) List(issuerID string) []*APIKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := []*APIKey{}
+	for _, key := range s.keys {
+		if key.IssuerID == issuerID {
+			keys = append(keys, key.redacted())
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys
+}
+
+// Revoke stops a key from authenticating, the record is kept for auditing
+func (s *APIKeyStore) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return fmt.Errorf("API key %s not found", id)
+	}
+	if key.RevokedAt != nil {
+		return fmt.Errorf("API key %s is already revoked", id)
+	}
+
+	now := time.Now().UTC()
+	key.RevokedAt = &now
+	if err := s.save(); err != nil {
+		key.RevokedAt = nil
+		return err
+	}
+	return nil
+}
+
+// Active reports whether the key with the given ID exists and is neither revoked nor expired
+func (s *APIKeyStore) Active(id string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.keys[id]
+	return ok && key.Active(time.Now())
+}
+
+// Authenticate returns the active key matching a presented API key
+func (s *APIKeyStore) Authenticate(presented string) (*APIKey, error) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(presented, apiKeyPrefix), "_")
+	if !ok || !strings.HasPrefix(presented, apiKeyPrefix) {
+		return nil, errInvalidAPIKey
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.keys[id]
+	if !ok || subtle.ConstantTimeCompare([]byte(hashAPIKeySecret(secret)), []byte(key.SecretHash)) != 1 {
+		return nil, errInvalidAPIKey
+	}
+	if !key.Active(time.Now()) {
+		return nil, errors.New("API key is revoked or expired")
+	}
+	return key.redacted(), nil
+}
+
+// hashAPIKeySecret hashes the random secret part of a key. The secret has 256 bits of entropy,
+// so a fast hash is enough unlike for passwords.
+func hashAPIKeySecret(secret string) string {
+	h := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(h[:])
+}
+
+// knownIssuer reports whether an issuer is listed in the issuers file
+func knownIssuer(issuerID string) bool {
+	for _, issuer := range issuers {
+		if issuer.ID == issuerID {
+			return true
+		}
+	}
+	return false
+}
+
+// registerAPIKeyRoutes adds the admin routes managing issuer API keys
+func registerAPIKeyRoutes(router gin.IRoutes, keys *APIKeyStore) {
+	// POST /admin/issuers/:issuerId/api-keys - Create a key, the response is the only place it is shown
+	router.POST("/admin/issuers/:issuerId/api-keys", func(c *gin.Context) {
+		issuerID := c.Param("issuerId")
+		if !knownIssuer(issuerID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Issuer not found"})
+			return
+		}
+
+		var req CreateAPIKeyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+			return
+		}
+
+		key, apiKey, err := keys.Create(issuerID, req, c.GetString(contextUsername))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to create API key", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"apiKey": apiKey,
+			"key":    key,
+		})
+	})
+
+	// GET /admin/issuers/:issuerId/api-keys - List an issuer's keys without their secrets
+	router.GET("/admin/issuers/:issuerId/api-keys", func(c *gin.Context) {
+		issuerID := c.Param("issuerId")
+		if !knownIssuer(issuerID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Issuer not found"})
+			return
+		}
+
+		list := keys.List(issuerID)
+		c.JSON(http.StatusOK, gin.H{"keys": list, "count": len(list)})
+	})
+
+	// DELETE /admin/api-keys/:id - Revoke a key
+	router.DELETE("/admin/api-keys/:id", func(c *gin.Context) {
+		if err := keys.Revoke(c.Param("id")); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found or already revoked", "details": err.Error()})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,543 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 7 * 24 * time.Hour
+	// iss claim of the tokens this gateway signs
+	tokenIssuer = "diploma-gateway"
+	// bcrypt cost of new password hashes
+	passwordHashCost = 12
+	// Shortest accepted HMAC secret for signing tokens
+	minJWTSecretLength = 32
+)
+
+// Token types, an access token cannot be used to refresh and a refresh token cannot call routes
+const (
+	tokenTypeAccess  = "access"
+	tokenTypeRefresh = "refresh"
+)
+
+// Keys the authentication middleware sets on the gin context
+const (
+	contextIssuerID = "auth.issuerId"
+	contextUsername = "auth.username"
+	contextAPIKeyID = "auth.apiKeyId"
+	contextScopes   = "auth.scopes"
+	contextAdmin    = "auth.admin"
+)
+
+// User role allowed on the /admin routes, such users belong to no issuer
+const roleAdmin = "admin"
+
+var errInvalidCredentials = errors.New("invalid username or password")
+
+var errIssuerNotServed = errors.New("issuer is not served by this gateway")
+
+// SessionClaims are the claims of access and refresh tokens
+type SessionClaims struct {
+	IssuerID  string `json:"issuerId,omitempty"`
+	Role      string `json:"role,omitempty"`
+	TokenType string `json:"tokenType"`
+	jwt.RegisteredClaims
+}
+
+// TokenPair is returned by login and refresh
+type TokenPair struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	TokenType    string `json:"tokenType"`
+	ExpiresIn    int    `json:"expiresIn"` // Seconds until the access token expires
+}
+
+// Authenticator checks user passwords and API keys and signs and verifies session tokens
+type Authenticator struct {
+	secret    []byte
+	users     map[string]User
+	keys      *APIKeyStore
+	dummyHash []byte // Compared against for unknown users, so they take as long as wrong passwords
+
+	mu      sync.Mutex
+	revoked map[string]time.Time // IDs of used or logged out refresh tokens, until they expire
+
+	// Set by ServeOnlyIssuer, issuer callers of other issuers are refused
+	restricted   bool
+	servedIssuer string
+}
+
+// NewAuthenticator loads the users once. Without a secret tokens are signed with a random key
+// and do not survive a restart.
+func NewAuthenticator(secret string, users []User, keys *APIKeyStore) (*Authenticator, error) {
+	a := &Authenticator{
+		users:   make(map[string]User, len(users)),
+		keys:    keys,
+		revoked: make(map[string]time.Time),
+	}
+
+	if secret == "" {
+		log.Printf("no JWT secret configured, sessions end when the gateway restarts")
+		a.secret = make([]byte, minJWTSecretLength)
+		if _, err := rand.Read(a.secret); err != nil {
+			return nil, err
+		}
+	} else {
+		a.secret = []byte(secret)
+	}
+
+	for _, user := range users {
+		if _, err := bcrypt.Cost([]byte(user.PasswordHash)); err != nil {
+			return nil, fmt.Errorf("user %s: password hash is not a bcrypt hash, create one with ./gateway hash-password", user.Username)
+		}
+		switch {
+		case user.Role == roleAdmin && user.IssuerID != "":
+			return nil, fmt.Errorf("user %s: admins cannot belong to an issuer", user.Username)
+		case user.Role == "" && user.IssuerID == "":
+			return nil, fmt.Errorf("user %s: issuerId is required", user.Username)
+		case user.Role != "" && user.Role != roleAdmin:
+			return nil, fmt.Errorf("user %s: unknown role %q", user.Username, user.Role)
+		}
+		a.users[user.Username] = user
+	}
+
+	dummyHash, err := bcrypt.GenerateFromPassword([]byte("dummy password"), passwordHashCost)
+	if err != nil {
+		return nil, err
+	}
+	a.dummyHash = dummyHash
+
+	return a, nil
+}
+
+// ServeOnlyIssuer limits issuer users and API keys to the issuer the gateway's Fabric identity
+// acts for. The chaincode rejects transactions of that identity for any other issuer, so their
+// callers are refused up front. An empty issuerID leaves only admin users.
+func (a *Authenticator) ServeOnlyIssuer(issuerID string) {
+	a.restricted = true
+	a.servedIssuer = issuerID
+}
+
+// servesIssuer reports whether callers acting for issuerID are accepted
+func (a *Authenticator) servesIssuer(issuerID string) bool {
+	return !a.restricted || issuerID == a.servedIssuer
+}
+
+// Login checks a username and password
+func (a *Authenticator) Login(username string, password string) (*User, error) {
+	user, ok := a.users[username]
+	if !ok {
+		bcrypt.CompareHashAndPassword(a.dummyHash, []byte(password))
+		return nil, errInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, errInvalidCredentials
+	}
+	if user.Role != roleAdmin && !a.servesIssuer(user.IssuerID) {
+		return nil, errIssuerNotServed
+	}
+	return &user, nil
+}
+
+// IssueTokens signs a new access and refresh token for a user
+func (a *Authenticator) IssueTokens(user *User) (*TokenPair, error) {
+	accessToken, err := a.sign(user, tokenTypeAccess, accessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := a.sign(user, tokenTypeRefresh, refreshTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(accessTokenTTL.Seconds()),
+	}, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair. Refresh tokens are single use, the
+// presented token is revoked.
+func (a *Authenticator) Refresh(refreshToken string) (*User, *TokenPair, error) {
+	claims, err := a.parse(refreshToken, tokenTypeRefresh)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := a.revoke(claims); err != nil {
+		return nil, nil, err
+	}
+
+	// The user may have been removed or moved to another issuer or role since the token was signed
+	user, ok := a.users[claims.Subject]
+	if !ok || user.IssuerID != claims.IssuerID || user.Role != claims.Role {
+		return nil, nil, errors.New("user no longer exists")
+	}
+	if user.Role != roleAdmin && !a.servesIssuer(user.IssuerID) {
+		return nil, nil, errIssuerNotServed
+	}
+
+	tokens, err := a.IssueTokens(&user)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &user, tokens, nil
+}
+
+// Logout revokes a refresh token. Access tokens stay valid until they expire.
+func (a *Authenticator) Logout(refreshToken string) error {
+	claims, err := a.parse(refreshToken, tokenTypeRefresh)
+	if err != nil {
+		return err
+	}
+	return a.revoke(claims)
+}
+
+func (a *Authenticator) sign(user *User, tokenType string, ttl time.Duration) (string, error) {
+	tokenID, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims := SessionClaims{
+		IssuerID:  user.IssuerID,
+		Role:      user.Role,
+		TokenType: tokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    tokenIssuer,
+			Subject:   user.Username,
+			ID:        tokenID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(a.secret)
+}
+
+// parse verifies a token's signature, expiry, issuer and type
+func (a *Authenticator) parse(token string, tokenType string) (*SessionClaims, error) {
+	claims := &SessionClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return a.secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(tokenIssuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType != tokenType {
+		return nil, fmt.Errorf("wrong token type %q, expected %s", claims.TokenType, tokenType)
+	}
+	if claims.Subject == "" || (claims.IssuerID == "") == (claims.Role != roleAdmin) {
+		return nil, errors.New("token must name a user and either an issuer or the admin role")
+	}
+	return claims, nil
+}
+
+// revoke marks a refresh token as used, it fails when the token was used before
+func (a *Authenticator) revoke(claims *SessionClaims) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	for id, expiresAt := range a.revoked {
+		if expiresAt.Before(now) {
+			delete(a.revoked, id)
+		}
+	}
+
+	if _, used := a.revoked[claims.ID]; used {
+		return errors.New("refresh token has already been used")
+	}
+	a.revoked[claims.ID] = claims.ExpiresAt.Time
+	return nil
+}
+
+// bearerClaims verifies the access token of the Authorization header. It writes the error
+// response and returns nil when the header is malformed or the token is invalid.
+func (a *Authenticator) bearerClaims(c *gin.Context) *SessionClaims {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header must be a Bearer token"})
+		return nil
+	}
+	claims, err := a.parse(token, tokenTypeAccess)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid access token", "details": err.Error()})
+		return nil
+	}
+	return claims
+}
+
+// requireIssuer accepts a bearer access token of an issuer user, with every scope, or an
+// X-API-Key header, with the key's scopes. Either way the caller acts for one issuer.
+func (a *Authenticator) requireIssuer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "" {
+			claims := a.bearerClaims(c)
+			if claims == nil {
+				return
+			}
+			if claims.IssuerID == "" {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Only issuer users can act for an issuer"})
+				return
+			}
+			if !a.servesIssuer(claims.IssuerID) {
+				a.abortIssuerNotServed(c, claims.IssuerID)
+				return
+			}
+			c.Set(contextIssuerID, claims.IssuerID)
+			c.Set(contextUsername, claims.Subject)
+			c.Set(contextScopes, allScopes)
+			c.Next()
+			return
+		}
+
+		if presented := c.GetHeader("X-API-Key"); presented != "" {
+			key, err := a.keys.Authenticate(presented)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key", "details": err.Error()})
+				return
+			}
+			if !a.servesIssuer(key.IssuerID) {
+				a.abortIssuerNotServed(c, key.IssuerID)
+				return
+			}
+			c.Set(contextIssuerID, key.IssuerID)
+			c.Set(contextAPIKeyID, key.ID)
+			c.Set(contextScopes, key.Scopes)
+			c.Next()
+			return
+		}
+
+		c.Header("WWW-Authenticate", `Bearer realm="`+tokenIssuer+`"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication is required"})
+	}
+}
+
+// abortIssuerNotServed rejects a caller of an issuer the gateway's Fabric identity cannot act for
+func (a *Authenticator) abortIssuerNotServed(c *gin.Context, issuerID string) {
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+		"error":        "This gateway does not act for issuer " + issuerID,
+		"servedIssuer": a.servedIssuer,
+	})
+}
+
+// requireScope rejects callers whose API key lacks a scope, it runs after requireIssuer
+func requireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !slices.Contains(c.GetStringSlice(contextScopes), scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "The " + scope + " scope is required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// requireAdmin accepts bearer access tokens of admin users only
+func (a *Authenticator) requireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Header("WWW-Authenticate", `Bearer realm="`+tokenIssuer+`"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication is required"})
+			return
+		}
+		claims := a.bearerClaims(c)
+		if claims == nil {
+			return
+		}
+		if claims.Role != roleAdmin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin role is required"})
+			return
+		}
+		c.Set(contextUsername, claims.Subject)
+		c.Next()
+	}
+}
+
+// requireIssuerOrAdmin accepts admin users, with every scope and no issuer, and otherwise
+// authenticates like requireIssuer
+func (a *Authenticator) requireIssuerOrAdmin() gin.HandlerFunc {
+	requireIssuer := a.requireIssuer()
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "" {
+			claims := a.bearerClaims(c)
+			if claims == nil {
+				return
+			}
+			if claims.Role == roleAdmin {
+				c.Set(contextAdmin, true)
+				c.Set(contextUsername, claims.Subject)
+				c.Set(contextScopes, allScopes)
+				c.Next()
+				return
+			}
+		}
+		requireIssuer(c)
+	}
+}
+
+// authenticatedAdmin reports whether requireIssuerOrAdmin accepted an admin user
+func authenticatedAdmin(c *gin.Context) bool {
+	return c.GetBool(contextAdmin)
+}
+
+// authenticatedIssuer returns the issuer the caller acts for, set by requireIssuer
+func authenticatedIssuer(c *gin.Context) string {
+	return c.GetString(contextIssuerID)
+}
+
+// issuerAuthorized reports whether the caller authenticated by requireIssuer may act for issuerID
+func issuerAuthorized(c *gin.Context, issuerID string) bool {
+	authenticated := authenticatedIssuer(c)
+	return authenticated != "" && authenticated == issuerID
+}
+
+// authorizeCredentialIssuer reads a credential and checks the caller acts for its issuer.
+// It writes the error response and returns nil when the caller is not authorized.
+func authorizeCredentialIssuer(c *gin.Context, store CredentialStore, id string) *Credential {
+	credential, err := store.ReadCredential(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Credential not found", "details": err.Error()})
+		return nil
+	}
+
+	if !issuerAuthorized(c, credential.IssuerID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized for the credential issuer"})
+		return nil
+	}
+
+	return credential
+}
+
+// issuerProfile is the issuer as returned to a logged in user, without its API key
+func issuerProfile(issuerID string) gin.H {
+	for _, issuer := range issuers {
+		if issuer.ID == issuerID {
+			return gin.H{"id": issuer.ID, "name": issuer.Name, "signature": issuer.Signature}
+		}
+	}
+	return nil
+}
+
+// sessionResponse is the body of a successful login or refresh
+func sessionResponse(user *User, tokens *TokenPair) gin.H {
+	return gin.H{
+		"accessToken":  tokens.AccessToken,
+		"refreshToken": tokens.RefreshToken,
+		"tokenType":    tokens.TokenType,
+		"expiresIn":    tokens.ExpiresIn,
+		"issuer":       issuerProfile(user.IssuerID),
+		"role":         user.Role,
+		"username":     user.Username,
+		"firstName":    user.FirstName,
+		"lastName":     user.LastName,
+	}
+}
+
+// registerAuthRoutes adds login, token refresh and logout
+func registerAuthRoutes(router gin.IRoutes, auth *Authenticator) {
+	// POST /auth/login - Exchange username and password for an access and refresh token
+	router.POST("/auth/login", func(c *gin.Context) {
+		var req struct {
+			Username string `json:"username" binding:"required"`
+			Password string `json:"password" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+			return
+		}
+
+		user, err := auth.Login(req.Username, req.Password)
+		if errors.Is(err, errIssuerNotServed) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This gateway does not act for the user's issuer", "servedIssuer": auth.servedIssuer})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+			return
+		}
+
+		tokens, err := auth.IssueTokens(user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue tokens", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, sessionResponse(user, tokens))
+	})
+
+	// POST /auth/refresh - Exchange a refresh token for a new token pair
+	router.POST("/auth/refresh", func(c *gin.Context) {
+		var req struct {
+			RefreshToken string `json:"refreshToken" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+			return
+		}
+
+		user, tokens, err := auth.Refresh(req.RefreshToken)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, sessionResponse(user, tokens))
+	})
+
+	// POST /auth/logout - Revoke a refresh token
+	router.POST("/auth/logout", func(c *gin.Context) {
+		var req struct {
+			RefreshToken string `json:"refreshToken" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+			return
+		}
+
+		if err := auth.Logout(req.RefreshToken); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token", "details": err.Error()})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	})
+}
+
+// runHashPassword reads a password from stdin and prints its bcrypt hash for users.json
+func runHashPassword() error {
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		return err
+	}
+	password = strings.TrimRight(password, "\r\n")
+	if password == "" {
+		return errors.New("password is empty")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordHashCost)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(hash))
+	return nil
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const (
+	// Rows per CreateCredentialsBatch transaction, the chaincode accepts up to 100
+	batchChunkSize = 50
+	maxBatchRows   = 5000
+)
+
+// csvColumns are the accepted CSV header names, mapped onto CreateCredentialRequest
+var csvColumns = map[string]func(req *CreateCredentialRequest, value string){
+	"diplomaHash":       func(req *CreateCredentialRequest, v string) { req.DiplomaHash = v },
+	"hashScheme":        func(req *CreateCredentialRequest, v string) { req.HashScheme = v },
+	"graduatePublicKey": func(req *CreateCredentialRequest, v string) { req.GraduatePublicKey = v },
+	"issuerId":          func(req *CreateCredentialRequest, v string) { req.IssuerID = v },
+	"issuerSignature":   func(req *CreateCredentialRequest, v string) { req.IssuerSignature = v },
+	"credentialType":    func(req *CreateCredentialRequest, v string) { req.CredentialType = v },
+	"universityName":    func(req *CreateCredentialRequest, v string) { req.DiplomaMetadata.UniversityName = v },
+	"degreeName":        func(req *CreateCredentialRequest, v string) { req.DiplomaMetadata.DegreeName = v },
+	"issueDate":         func(req *CreateCredentialRequest, v string) { req.DiplomaMetadata.IssueDate = v },
+	"expiryDate":        func(req *CreateCredentialRequest, v string) { req.DiplomaMetadata.ExpiryDate = v },
+}
+
+// BatchRowResult mirrors the chaincode per-row outcome. Row is 1-based in gateway reports.
+type BatchRowResult struct {
+	Row          int    `json:"row"`
+	CredentialID string `json:"credentialId,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// BatchResult mirrors the chaincode CreateCredentialsBatch report of one chunk
+type BatchResult struct {
+	IssuerID string            `json:"issuerId"`
+	Created  int               `json:"created"`
+	Failed   int               `json:"failed"`
+	Rows     []*BatchRowResult `json:"rows"`
+}
+
+// BatchReport is the response of POST /credentials/batch, one entry per submitted row
+type BatchReport struct {
+	Total   int               `json:"total"`
+	Created int               `json:"created"`
+	Failed  int               `json:"failed"`
+	Rows    []*BatchRowResult `json:"rows"`
+}
+
+// CreateCredentialsBatch submits one chunk of credentials of a single issuer
+func (f *FabricService) CreateCredentialsBatch(creds []*Credential) (*BatchResult, error) {
+	credsJSON, err := json.Marshal(creds)
+	if err != nil {
+		return nil, err
+	}
+	result, err := f.submitTransaction("CreateCredentialsBatch", string(credsJSON))
+	if err != nil {
+		return nil, err
+	}
+	var batch BatchResult
+	if err := json.Unmarshal(result, &batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// parseBatchRequest reads the rows of a JSON array, a text/csv body or a multipart "file" CSV upload
+func parseBatchRequest(c *gin.Context) ([]*CreateCredentialRequest, error) {
+	switch c.ContentType() {
+	case binding.MIMEJSON:
+		var rows []*CreateCredentialRequest
+		if err := json.NewDecoder(c.Request.Body).Decode(&rows); err != nil {
+			return nil, fmt.Errorf("body must be a JSON array of credentials: %v", err)
+		}
+		return rows, nil
+	case "text/csv":
+		return parseCredentialsCSV(c.Request.Body)
+	case binding.MIMEMultipartPOSTForm:
+		header, err := c.FormFile("file")
+		if err != nil {
+			return nil, fmt.Errorf("multipart upload must contain a CSV file field named file: %v", err)
+		}
+		file, err := header.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return parseCredentialsCSV(file)
+	default:
+		return nil, fmt.Errorf("unsupported content type %q, use application/json, text/csv or multipart/form-data", c.ContentType())
+	}
+}
+
+// parseCredentialsCSV reads credentials from CSV with a header row naming csvColumns
+func parseCredentialsCSV(r io.Reader) ([]*CreateCredentialRequest, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %v", err)
+	}
+
+	setters := make([]func(req *CreateCredentialRequest, value string), len(header))
+	for i, name := range header {
+		setter, ok := csvColumns[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown CSV column %q", name)
+		}
+		setters[i] = setter
+	}
+
+	var rows []*CreateCredentialRequest
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: %v", err)
+		}
+
+		row := &CreateCredentialRequest{}
+		for i, value := range record {
+			setters[i](row, strings.TrimSpace(value))
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// validateBatchRow applies the POST /credential checks to one row
+func validateBatchRow(c *gin.Context, row *CreateCredentialRequest) error {
+	if row == nil {
+		return fmt.Errorf("row is empty")
+	}
+	if err := binding.Validator.ValidateStruct(row); err != nil {
+		return err
+	}
+	if row.GraduateDetails != nil {
+		return fmt.Errorf("graduateDetails are not supported in batches, use PUT /credential/:id/private")
+	}
+	if !issuerAuthorized(c, row.IssuerID) {
+		return fmt.Errorf("not authorized for issuer %s", row.IssuerID)
+	}
+	return nil
+}
+
+// batchCredentialsHandler serves POST /credentials/batch. Every row is validated first, valid rows
+// are grouped by issuer and submitted in chunks of batchChunkSize. Rows that fail validation, are
+// rejected by the chaincode or belong to a chunk whose transaction failed are reported with an
+// error, all other rows are created. Responds 201 when every row was created, 207 when some were
+// and 422 when none were.
+func batchCredentialsHandler(fs *FabricService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rows, err := parseBatchRequest(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+			return
+		}
+		if len(rows) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": "the batch is empty"})
+			return
+		}
+		if len(rows) > maxBatchRows {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Batch too large", "details": fmt.Sprintf("at most %d rows are allowed", maxBatchRows)})
+			return
+		}
+
+		report := &BatchReport{Total: len(rows), Rows: make([]*BatchRowResult, len(rows))}
+
+		// Validate every row up front and group the valid ones by issuer, a chaincode batch has one issuer
+		var issuerOrder []string
+		pending := map[string][]int{}
+		for i, row := range rows {
+			report.Rows[i] = &BatchRowResult{Row: i + 1}
+			if err := validateBatchRow(c, row); err != nil {
+				report.Rows[i].Error = err.Error()
+				continue
+			}
+			if _, ok := pending[row.IssuerID]; !ok {
+				issuerOrder = append(issuerOrder, row.IssuerID)
+			}
+			pending[row.IssuerID] = append(pending[row.IssuerID], i)
+		}
+
+		for _, issuerID := range issuerOrder {
+			indexes := pending[issuerID]
+			for start := 0; start < len(indexes); start += batchChunkSize {
+				chunk := indexes[start:min(start+batchChunkSize, len(indexes))]
+
+				creds := make([]*Credential, len(chunk))
+				for j, i := range chunk {
+					row := rows[i]
+					creds[j] = &Credential{
+						DiplomaHash:       row.DiplomaHash,
+						HashScheme:        row.HashScheme,
+						GraduatePublicKey: row.GraduatePublicKey,
+						IssuerID:          row.IssuerID,
+						IssuerSignature:   row.IssuerSignature,
+						DiplomaMetadata:   row.DiplomaMetadata,
+						Status:            "Valid",
+						CredentialType:    row.CredentialType,
+					}
+				}
+
+				// A failed transaction writes nothing, every row of the chunk is reported as failed
+				result, err := fs.CreateCredentialsBatch(creds)
+				if err != nil {
+					for _, i := range chunk {
+						report.Rows[i].Error = "batch transaction failed: " + err.Error()
+					}
+					continue
+				}
+
+				for _, rowResult := range result.Rows {
+					if rowResult.Row < 0 || rowResult.Row >= len(chunk) {
+						continue
+					}
+					i := chunk[rowResult.Row]
+					report.Rows[i].CredentialID = rowResult.CredentialID
+					report.Rows[i].Error = rowResult.Error
+				}
+			}
+		}
+
+		for _, rowResult := range report.Rows {
+			if rowResult.CredentialID != "" {
+				report.Created++
+			} else {
+				if rowResult.Error == "" {
+					rowResult.Error = "no result returned for row"
+				}
+				report.Failed++
+			}
+		}
+
+		status := http.StatusCreated
+		switch {
+		case report.Created == 0:
+			status = http.StatusUnprocessableEntity
+		case report.Failed > 0:
+			status = http.StatusMultiStatus
+		}
+		c.JSON(status, report)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Hash schemes of Credential.DiplomaHash, see the chaincode
+const (
+	hashSchemeSHA256     = "sha256"
+	hashSchemeHMACSHA256 = "hmac-sha256"
+)
+
+const diplomaSaltBytes = 32
+
+// CommitmentRequest for POST /commitments
+type CommitmentRequest struct {
+	DiplomaHash string `json:"diplomaHash" binding:"required"` // Hex SHA-256 of the diploma file
+}
+
+// DiplomaCommitment returns hex(HMAC-SHA256(salt, lowercase hex file hash)).
+// Equivalent to: echo -n $HASH | openssl dgst -sha256 -mac HMAC -macopt hexkey:$SALT
+func DiplomaCommitment(diplomaHash string, salt string) (string, error) {
+	key, err := hex.DecodeString(salt)
+	if err != nil || len(key) == 0 {
+		return "", fmt.Errorf("salt must be hex encoded")
+	}
+	fileHash := strings.ToLower(diplomaHash)
+	if digest, err := hex.DecodeString(fileHash); err != nil || len(digest) != sha256.Size {
+		return "", fmt.Errorf("diplomaHash must be a hex encoded SHA-256")
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(fileHash))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// isSaltedCredential reports whether a credential stores a salted commitment instead of the plain file hash
+func isSaltedCredential(credential *Credential) bool {
+	return credential.HashScheme == hashSchemeHMACSHA256
+}
+
+// commitmentHandler serves POST /commitments. It draws a fresh salt for the issuer, who signs the
+// returned commitment, creates the credential with hashScheme hmac-sha256 and hands the salt to the graduate.
+func commitmentHandler(c *gin.Context) {
+	var req CommitmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+
+	salt, err := randomHex(diplomaSaltBytes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate salt", "details": err.Error()})
+		return
+	}
+
+	commitment, err := DiplomaCommitment(req.DiplomaHash, salt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid diploma hash", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"hashScheme":  hashSchemeHMACSHA256,
+		"salt":        salt,
+		"diplomaHash": commitment,
+	})
+}
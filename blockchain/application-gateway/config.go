@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Environment variable naming the configuration file, the -config flag takes precedence
+const configFileEnv = "GATEWAY_CONFIG"
+
+// Config holds the gateway settings. Values are layered: defaults, then the YAML or JSON
+// configuration file, then GATEWAY_* environment variables, then command line flags.
+type Config struct {
+	MSPID string `json:"mspId" yaml:"mspId"`
+	// Relative certPath, keyPath and tlsCertPath are resolved against cryptoPath
+	CryptoPath    string `json:"cryptoPath" yaml:"cryptoPath"`
+	CertPath      string `json:"certPath" yaml:"certPath"`       // Directory holding the client certificate
+	KeyPath       string `json:"keyPath" yaml:"keyPath"`         // Directory holding the client private key
+	TLSCertPath   string `json:"tlsCertPath" yaml:"tlsCertPath"` // Peer TLS CA certificate
+	PeerEndpoint  string `json:"peerEndpoint" yaml:"peerEndpoint"`
+	GatewayPeer   string `json:"gatewayPeer" yaml:"gatewayPeer"` // TLS server name of the peer
+	ChannelName   string `json:"channelName" yaml:"channelName"`
+	ChaincodeName string `json:"chaincodeName" yaml:"chaincodeName"`
+	LedgerBackend string `json:"ledgerBackend" yaml:"ledgerBackend"` // fabric or memory
+	// Peers taking over when the peer above is unreachable, only settable in the configuration file
+	FailoverPeers []PeerConfig `json:"failoverPeers" yaml:"failoverPeers"`
+
+	IssuersFile         string `json:"issuersFile" yaml:"issuersFile"`
+	UsersFile           string `json:"usersFile" yaml:"usersFile"`
+	ListenAddress       string `json:"listenAddress" yaml:"listenAddress"`
+	EventCheckpointFile string `json:"eventCheckpointFile" yaml:"eventCheckpointFile"`
+	WebhookStoreFile    string `json:"webhookStoreFile" yaml:"webhookStoreFile"`
+	APIKeyStoreFile     string `json:"apiKeyStoreFile" yaml:"apiKeyStoreFile"`
+	// Commit states of transactions submitted without waiting
+	TransactionStoreFile string `json:"transactionStoreFile" yaml:"transactionStoreFile"`
+	// HMAC key signing session tokens, random per process when empty
+	JWTSecret string `json:"jwtSecret" yaml:"jwtSecret"`
+	// Base URL of the gateway in verifiable credentials, which are not exported or verified when empty
+	PublicURL string `json:"publicUrl" yaml:"publicUrl"`
+	// Directory holding <issuerId>.pem private keys that sign verifiable credentials
+	VCKeysDir string `json:"vcKeysDir" yaml:"vcKeysDir"`
+}
+
+// PeerConfig is a failover gateway peer. An empty gatewayPeer or tlsCertPath falls back to the
+// values of the primary peer, a relative tlsCertPath is resolved against cryptoPath.
+type PeerConfig struct {
+	Endpoint    string `json:"endpoint" yaml:"endpoint"`
+	GatewayPeer string `json:"gatewayPeer" yaml:"gatewayPeer"` // TLS server name override
+	TLSCertPath string `json:"tlsCertPath" yaml:"tlsCertPath"`
+}
+
+// Peers returns the primary peer followed by the failover peers, with defaults filled in
+func (c *Config) Peers() []PeerConfig {
+	peers := []PeerConfig{{Endpoint: c.PeerEndpoint, GatewayPeer: c.GatewayPeer, TLSCertPath: c.TLSCertPath}}
+	for _, peer := range c.FailoverPeers {
+		if peer.GatewayPeer == "" {
+			peer.GatewayPeer = c.GatewayPeer
+		}
+		if peer.TLSCertPath == "" {
+			peer.TLSCertPath = c.TLSCertPath
+		}
+		peers = append(peers, peer)
+	}
+	return peers
+}
+
+// DefaultConfig targets Org1 of the Fabric test network next to this directory
+func DefaultConfig() *Config {
+	return &Config{
+		MSPID:                "Org1MSP",
+		CryptoPath:           "../test-network/organizations/peerOrganizations/org1.example.com",
+		CertPath:             "users/User1@org1.example.com/msp/signcerts",
+		KeyPath:              "users/User1@org1.example.com/msp/keystore",
+		TLSCertPath:          "peers/peer0.org1.example.com/tls/ca.crt",
+		PeerEndpoint:         "localhost:7051",
+		GatewayPeer:          "peer0.org1.example.com",
+		ChannelName:          "mychannel",
+		ChaincodeName:        "diploma",
+		LedgerBackend:        ledgerBackendFabric,
+		IssuersFile:          "../../backend/issuers.json",
+		UsersFile:            "../../backend/users.json",
+		ListenAddress:        "0.0.0.0:8080",
+		EventCheckpointFile:  "chaincode-events.checkpoint",
+		WebhookStoreFile:     "webhooks.json",
+		APIKeyStoreFile:      "api-keys.json",
+		TransactionStoreFile: "transactions.json",
+		VCKeysDir:            "vc-keys",
+	}
+}
+
+// configOption binds a setting to its flag and environment variable
+type configOption struct {
+	flag  string
+	env   string
+	usage string
+	value func(cfg *Config) *string
+}
+
+var configOptions = []configOption{
+	{"msp-id", "GATEWAY_MSP_ID", "MSP ID of the client identity", func(c *Config) *string { return &c.MSPID }},
+	{"crypto-path", "GATEWAY_CRYPTO_PATH", "base directory of the organization's crypto material", func(c *Config) *string { return &c.CryptoPath }},
+	{"cert-path", "GATEWAY_CERT_PATH", "directory holding the client certificate", func(c *Config) *string { return &c.CertPath }},
+	{"key-path", "GATEWAY_KEY_PATH", "directory holding the client private key", func(c *Config) *string { return &c.KeyPath }},
+	{"tls-cert-path", "GATEWAY_TLS_CERT_PATH", "peer TLS CA certificate", func(c *Config) *string { return &c.TLSCertPath }},
+	{"peer-endpoint", "GATEWAY_PEER_ENDPOINT", "host:port of the gateway peer", func(c *Config) *string { return &c.PeerEndpoint }},
+	{"gateway-peer", "GATEWAY_PEER_NAME", "TLS server name of the gateway peer", func(c *Config) *string { return &c.GatewayPeer }},
+	{"channel", "GATEWAY_CHANNEL", "channel name", func(c *Config) *string { return &c.ChannelName }},
+	{"chaincode", "GATEWAY_CHAINCODE", "chaincode name", func(c *Config) *string { return &c.ChaincodeName }},
+	{"ledger-backend", "GATEWAY_LEDGER_BACKEND", "ledger backend, fabric or memory", func(c *Config) *string { return &c.LedgerBackend }},
+	{"issuers-file", "GATEWAY_ISSUERS_FILE", "issuers JSON file", func(c *Config) *string { return &c.IssuersFile }},
+	{"users-file", "GATEWAY_USERS_FILE", "users JSON file", func(c *Config) *string { return &c.UsersFile }},
+	{"listen", "GATEWAY_LISTEN_ADDRESS", "HTTP listen address", func(c *Config) *string { return &c.ListenAddress }},
+	{"event-checkpoint-file", "GATEWAY_EVENT_CHECKPOINT_FILE", "chaincode event checkpoint file", func(c *Config) *string { return &c.EventCheckpointFile }},
+	{"webhook-store-file", "GATEWAY_WEBHOOK_STORE_FILE", "webhook subscriptions file", func(c *Config) *string { return &c.WebhookStoreFile }},
+	{"api-key-store-file", "GATEWAY_API_KEY_STORE_FILE", "issuer API keys file", func(c *Config) *string { return &c.APIKeyStoreFile }},
+	{"transaction-store-file", "GATEWAY_TRANSACTION_STORE_FILE", "file of asynchronously submitted transactions", func(c *Config) *string { return &c.TransactionStoreFile }},
+	{"jwt-secret", "GATEWAY_JWT_SECRET", "secret signing session tokens, at least 32 bytes", func(c *Config) *string { return &c.JWTSecret }},
+	{"public-url", "GATEWAY_PUBLIC_URL", "base URL of the gateway in verifiable credentials", func(c *Config) *string { return &c.PublicURL }},
+	{"vc-keys-dir", "GATEWAY_VC_KEYS_DIR", "directory of issuer assertion keys signing verifiable credentials", func(c *Config) *string { return &c.VCKeysDir }},
+}
+
+// LoadConfig builds the configuration from defaults, the configuration file, the environment and args
+func LoadConfig(args []string) (*Config, error) {
+	flags := flag.NewFlagSet("gateway", flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv(configFileEnv), "YAML or JSON configuration file")
+	flagValues := make(map[string]*string, len(configOptions))
+	for _, option := range configOptions {
+		flagValues[option.flag] = flags.String(option.flag, "", option.usage+" (env "+option.env+")")
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := DefaultConfig()
+
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, option := range configOptions {
+		if value, ok := os.LookupEnv(option.env); ok {
+			*option.value(cfg) = value
+		}
+	}
+
+	// Only flags given on the command line override, unset flags keep the layers below
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, option := range configOptions {
+		if set[option.flag] {
+			*option.value(cfg) = *flagValues[option.flag]
+		}
+	}
+
+	cfg.resolvePaths()
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile overlays the settings present in a .yaml, .yml or .json file
+func (c *Config) loadFile(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, c)
+	case ".json":
+		err = json.Unmarshal(data, c)
+	default:
+		return fmt.Errorf("config file %s must end in .yaml, .yml or .json", file)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %v", file, err)
+	}
+	return nil
+}
+
+// resolvePaths makes relative crypto paths relative to CryptoPath
+func (c *Config) resolvePaths() {
+	paths := []*string{&c.CertPath, &c.KeyPath, &c.TLSCertPath}
+	for i := range c.FailoverPeers {
+		paths = append(paths, &c.FailoverPeers[i].TLSCertPath)
+	}
+	for _, p := range paths {
+		if *p != "" && !filepath.IsAbs(*p) && c.CryptoPath != "" {
+			*p = filepath.Join(c.CryptoPath, *p)
+		}
+	}
+}
+
+// Validate reports every invalid setting at once, so a deployment can be fixed in one pass
+func (c *Config) Validate() error {
+	var errs []error
+	required := func(name string, value string) {
+		if strings.TrimSpace(value) == "" {
+			errs = append(errs, fmt.Errorf("%s is required", name))
+		}
+	}
+	exists := func(name string, file string, dir bool) {
+		if file == "" {
+			return
+		}
+		info, err := os.Stat(file)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("%s: %v", name, err))
+		case dir && !info.IsDir():
+			errs = append(errs, fmt.Errorf("%s: %s is not a directory", name, file))
+		case !dir && info.IsDir():
+			errs = append(errs, fmt.Errorf("%s: %s is a directory", name, file))
+		}
+	}
+
+	required("issuersFile", c.IssuersFile)
+	exists("issuersFile", c.IssuersFile, false)
+	required("usersFile", c.UsersFile)
+	exists("usersFile", c.UsersFile, false)
+	required("webhookStoreFile", c.WebhookStoreFile)
+	required("apiKeyStoreFile", c.APIKeyStoreFile)
+	required("transactionStoreFile", c.TransactionStoreFile)
+	if c.JWTSecret != "" && len(c.JWTSecret) < minJWTSecretLength {
+		errs = append(errs, fmt.Errorf("jwtSecret must be at least %d bytes", minJWTSecretLength))
+	}
+
+	if c.PublicURL != "" {
+		if u, err := url.Parse(c.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("publicUrl must be an absolute http or https URL, got %q", c.PublicURL))
+		}
+	}
+
+	required("listenAddress", c.ListenAddress)
+	if c.ListenAddress != "" {
+		if _, _, err := net.SplitHostPort(c.ListenAddress); err != nil {
+			errs = append(errs, fmt.Errorf("listenAddress: %v", err))
+		}
+	}
+
+	switch c.LedgerBackend {
+	case ledgerBackendFabric:
+		required("mspId", c.MSPID)
+		required("certPath", c.CertPath)
+		exists("certPath", c.CertPath, true)
+		required("keyPath", c.KeyPath)
+		exists("keyPath", c.KeyPath, true)
+		required("tlsCertPath", c.TLSCertPath)
+		exists("tlsCertPath", c.TLSCertPath, false)
+		required("gatewayPeer", c.GatewayPeer)
+		required("channelName", c.ChannelName)
+		required("chaincodeName", c.ChaincodeName)
+		required("eventCheckpointFile", c.EventCheckpointFile)
+		required("peerEndpoint", c.PeerEndpoint)
+		if c.PeerEndpoint != "" {
+			if _, _, err := net.SplitHostPort(c.PeerEndpoint); err != nil {
+				errs = append(errs, fmt.Errorf("peerEndpoint: %v", err))
+			}
+		}
+		for i, peer := range c.FailoverPeers {
+			name := fmt.Sprintf("failoverPeers[%d]", i)
+			required(name+".endpoint", peer.Endpoint)
+			if peer.Endpoint != "" {
+				if _, _, err := net.SplitHostPort(peer.Endpoint); err != nil {
+					errs = append(errs, fmt.Errorf("%s.endpoint: %v", name, err))
+				}
+			}
+			exists(name+".tlsCertPath", peer.TLSCertPath, false)
+		}
+	case ledgerBackendMemory:
+	default:
+		errs = append(errs, fmt.Errorf("ledgerBackend must be %s or %s, got %q", ledgerBackendFabric, ledgerBackendMemory, c.LedgerBackend))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExampleConfigFailoverPeerIsRelativeToCryptoPath(t *testing.T) {
+	example, err := os.ReadFile("gateway.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Uncomment the failoverPeers example, it ends at the first blank line
+	var lines []string
+	inExample := false
+	for _, line := range strings.Split(string(example), "\n") {
+		if strings.HasPrefix(line, "# failoverPeers:") {
+			inExample = true
+		}
+		if inExample && strings.TrimSpace(line) == "" {
+			inExample = false
+		}
+		if inExample {
+			line = strings.TrimPrefix(line, "# ")
+		}
+		lines = append(lines, line)
+	}
+	file := filepath.Join(t.TempDir(), "gateway.yaml")
+	if err := os.WriteFile(file, []byte(strings.Join(lines, "\n")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := DefaultConfig()
+	if err := cfg.loadFile(file); err != nil {
+		t.Fatal(err)
+	}
+	cfg.resolvePaths()
+
+	peers := cfg.Peers()
+	if len(peers) != 2 {
+		t.Fatalf("example has %d peers, want the primary and one failover peer", len(peers))
+	}
+	want := "../test-network/organizations/peerOrganizations/org2.example.com/peers/peer0.org2.example.com/tls/ca.crt"
+	if peers[1].TLSCertPath != want {
+		t.Errorf("failover tlsCertPath = %q, want %q", peers[1].TLSCertPath, want)
+	}
+	if want := "../test-network/organizations/peerOrganizations/org1.example.com/peers/peer0.org1.example.com/tls/ca.crt"; peers[0].TLSCertPath != want {
+		t.Errorf("tlsCertPath = %q, want %q", peers[0].TLSCertPath, want)
+	}
+}
+
+func TestLoadConfigResolvesPathsAgainstCryptoPath(t *testing.T) {
+	dir := t.TempDir()
+	touch := func(path string) string {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	for _, path := range []string{"org1/signcerts", "org1/keystore"} {
+		if err := os.MkdirAll(filepath.Join(dir, path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	touch("org1/tls/ca.crt")
+	touch("org2/tls/ca.crt")
+	otherCA := touch("elsewhere/ca.crt")
+
+	config := `
+cryptoPath: ` + filepath.Join(dir, "org1") + `
+certPath: signcerts
+keyPath: keystore
+tlsCertPath: tls/ca.crt
+failoverPeers:
+  - endpoint: localhost:9051
+    tlsCertPath: ../org2/tls/ca.crt
+  - endpoint: localhost:10051
+    tlsCertPath: ` + otherCA + `
+  - endpoint: localhost:11051
+issuersFile: ` + touch("issuers.json") + `
+usersFile: ` + touch("users.json") + `
+`
+	file := filepath.Join(dir, "gateway.yaml")
+	if err := os.WriteFile(file, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig([]string{"-config", file})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wants := []string{
+		filepath.Join(dir, "org1/tls/ca.crt"),
+		filepath.Join(dir, "org2/tls/ca.crt"),
+		otherCA,
+		filepath.Join(dir, "org1/tls/ca.crt"), // defaults to the primary peer's CA
+	}
+	peers := cfg.Peers()
+	for i, want := range wants {
+		if peers[i].TLSCertPath != want {
+			t.Errorf("peer %d tlsCertPath = %q, want %q", i, peers[i].TLSCertPath, want)
+		}
+	}
+
+	// A failover CA missing under cryptoPath is reported with its resolved path
+	if err := os.WriteFile(file, []byte(strings.Replace(config, "../org2/tls/ca.crt", "../org3/tls/ca.crt", 1)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadConfig([]string{"-config", file})
+	if err == nil || !strings.Contains(err.Error(), "failoverPeers[0].tlsCertPath") || !strings.Contains(err.Error(), filepath.Join(dir, "org3/tls/ca.crt")) {
+		t.Errorf("missing failover CA: %v", err)
+	}
+}
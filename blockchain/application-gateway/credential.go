@@ -1,49 +1,70 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
 	"crypto/x509"
+	"encoding/asn1"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"path"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
 	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	"github.com/gin-gonic/gin"
-	"github.com/hyperledger/fabric-gateway/pkg/client"
-	"github.com/hyperledger/fabric-gateway/pkg/hash"
 	"github.com/hyperledger/fabric-gateway/pkg/identity"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-const (
-	mspID         = "Org1MSP"
-	cryptoPath    = "../test-network/organizations/peerOrganizations/org1.example.com"
-	certPath      = cryptoPath + "/users/User1@org1.example.com/msp/signcerts"
-	keyPath       = cryptoPath + "/users/User1@org1.example.com/msp/keystore"
-	tlsCertPath   = cryptoPath + "/peers/peer0.org1.example.com/tls/ca.crt"
-	peerEndpoint  = "localhost:7051"
-	gatewayPeer   = "peer0.org1.example.com"
-	channelName   = "mychannel"
-	chaincodeName = "diploma"
-)
+// Ledger key prefix the chaincode puts in front of stored credential IDs
+const credentialKeyPrefix = "CREDENTIAL_"
+
+// Certificate attribute naming the issuer a client acts for, checked by the chaincode
+const issuerIDAttribute = "issuerId"
+
+// Certificate extension Fabric CA stores enrollment attributes in, as JSON
+var fabricAttributesOID = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}
 
 // Credential struct mirrors chaincode
 type Credential struct {
 	ID                string          `json:"id"`
 	DiplomaHash       string          `json:"diplomaHash"`
+	HashScheme        string          `json:"hashScheme,omitempty"`
 	GraduatePublicKey string          `json:"graduatePublicKey"`
 	IssuerID          string          `json:"issuerId"`
 	IssuerSignature   string          `json:"issuerSignature"`
 	DiplomaMetadata   DiplomaMetadata `json:"diplomaMetadata"`
 	Status            string          `json:"status"`
 	CredentialType    string          `json:"credentialType"`
+	Revocation        *Revocation     `json:"revocation,omitempty"`
+	Suspension        *Suspension     `json:"suspension,omitempty"`
+	// Salted hash of the graduate details held in the issuer's private data collection
+	GraduateDetailsHash string `json:"graduateDetailsHash,omitempty"`
+	// ID the credential had before it was migrated to an issuer namespaced ID
+	LegacyID string `json:"legacyId,omitempty"`
+	// When the issuer signature was accepted, its key is the one valid at that time
+	SignedAt string `json:"signedAt,omitempty"`
+}
+
+// Revocation mirrors the chaincode revocation record
+type Revocation struct {
+	Reason        string `json:"reason"`
+	Note          string `json:"note"`
+	RevokedAt     string `json:"revokedAt"`
+	RevokedBy     string `json:"revokedBy"`
+	RevokerMSPID  string `json:"revokerMspId"`
+	RevokerIssuer string `json:"revokerIssuer"`
+	TxID          string `json:"txId"`
 }
 
 type DiplomaMetadata struct {
@@ -57,23 +78,36 @@ type DiplomaMetadata struct {
 type Issuer struct {
 	ID        string `json:"id"`
 	Name      string `json:"name"`
-	APIKey    string `json:"apiKey"`
 	Signature string `json:"signature"`
+	Status    string `json:"status,omitempty"` // Active when empty, only the memory backend reads it
+}
+
+// Suspension mirrors the chaincode suspension record
+type Suspension struct {
+	Note           string `json:"note"`
+	SuspendedAt    string `json:"suspendedAt"`
+	SuspendedBy    string `json:"suspendedBy"`
+	SuspenderMSPID string `json:"suspenderMspId"`
+	TxID           string `json:"txId"`
 }
 
 // CreateCredentialRequest for POST /credential
 type CreateCredentialRequest struct {
 	DiplomaHash       string          `json:"diplomaHash" binding:"required"`
+	HashScheme        string          `json:"hashScheme" binding:"omitempty,oneof=sha256 hmac-sha256"` // hmac-sha256 when diplomaHash comes from POST /commitments
 	GraduatePublicKey string          `json:"graduatePublicKey" binding:"required"`
 	IssuerID          string          `json:"issuerId" binding:"required"`
 	IssuerSignature   string          `json:"issuerSignature" binding:"required"`
 	DiplomaMetadata   DiplomaMetadata `json:"diplomaMetadata" binding:"required"`
 	CredentialType    string          `json:"credentialType" binding:"required"`
+	// Optional sensitive details, stored off the public ledger
+	GraduateDetails *GraduateDetails `json:"graduateDetails"`
 }
 
 // VerifyHashRequest for POST /verify/hash
 type VerifyHashRequest struct {
 	DiplomaHash string `json:"diplomaHash" binding:"required"`
+	Salt        string `json:"salt"` // Required for credentials issued with a salted commitment
 }
 
 // VerifySignatureRequest for POST /verify/signature
@@ -82,82 +116,146 @@ type VerifySignatureRequest struct {
 	GraduateSignature string `json:"graduateSignature" binding:"required"`
 }
 
+// CredentialFilter mirrors the chaincode filter of QueryCredentialsPaginated
+type CredentialFilter struct {
+	IssuerID       string `json:"issuerId,omitempty"`
+	Status         string `json:"status,omitempty"`
+	CredentialType string `json:"credentialType,omitempty"`
+	IssuedFrom     string `json:"issuedFrom,omitempty"`
+	IssuedTo       string `json:"issuedTo,omitempty"`
+}
+
+// CredentialPage mirrors a page returned by QueryCredentialsPaginated
+type CredentialPage struct {
+	Credentials []*Credential `json:"credentials"`
+	Count       int32         `json:"count"`
+	Bookmark    string        `json:"bookmark"`
+}
+
+// RevokeCredentialRequest for PATCH /credential/:id/revoke
+type RevokeCredentialRequest struct {
+	Reason string `json:"reason" binding:"required,oneof=fraud error superseded withdrawn"`
+	Note   string `json:"note"`
+}
+
+// SuspendCredentialRequest for PATCH /credential/:id/suspend
+type SuspendCredentialRequest struct {
+	Note string `json:"note"`
+}
+
+// SignatureVerification mirrors the chaincode result of VerifyCredentialSignature
+type SignatureVerification struct {
+	CredentialID string `json:"credentialId"`
+	IssuerID     string `json:"issuerId"`
+	Valid        bool   `json:"valid"`
+	IssuerStatus string `json:"issuerStatus"`
+	KeyID        string `json:"keyId,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// CredentialHistoryEntry mirrors a single version returned by GetCredentialHistory
+type CredentialHistoryEntry struct {
+	TxID       string      `json:"txId"`
+	Timestamp  string      `json:"timestamp"`
+	IsDelete   bool        `json:"isDelete"`
+	Credential *Credential `json:"credential,omitempty"`
+}
+
 var issuers = []Issuer{}
 
 type User struct {
 	IssuerID     string `json:"issuerId"`
 	Username     string `json:"username"`
 	PasswordHash string `json:"passwordHash"`
+	Role         string `json:"role,omitempty"` // admin, or empty for issuer users
 	FirstName    string `json:"firstName"`
 	LastName     string `json:"lastName"`
 }
 
 // FabricService wraps gateway connection
 type FabricService struct {
-	gw       *client.Gateway
-	network  *client.Network
-	contract *client.Contract
+	mu            sync.RWMutex
+	peers         []*peerConnection // The configured peer first, then the failover peers
+	active        int               // Index of the peer calls are sent to
+	mspID         string
+	issuerID      string // issuerId attribute of the client certificate, the only issuer it acts for
+	chaincodeName string
 }
 
-// ConnectGateway initializes Fabric gateway connection
-func ConnectGateway() (*FabricService, error) {
-	ccp := &FabricService{}
-
-	// gRPC connection
-	certificatePEM, err := os.ReadFile(tlsCertPath)
-	if err != nil {
-		return nil, err
-	}
-
-	certPool := x509.NewCertPool()
-	if ok := certPool.AppendCertsFromPEM(certificatePEM); !ok {
-		panic("failed to add peer TLS certificate to cert pool")
-	}
+// ConnectGateway creates connections to the configured gateway peers. Calls go to the first
+// peer and fail over to the others when it becomes unreachable.
+func ConnectGateway(cfg *Config) (*FabricService, error) {
+	ccp := &FabricService{mspID: cfg.MSPID, chaincodeName: cfg.ChaincodeName}
 
-	tlsCreds := credentials.NewClientTLSFromCert(certPool, gatewayPeer)
-	grpcConn, err := grpc.Dial(peerEndpoint, grpc.WithTransportCredentials(tlsCreds))
+	id, cert, err := newIdentity(cfg)
 	if err != nil {
 		return nil, err
 	}
-
-	id, err := newIdentity()
-	if err != nil {
+	if ccp.issuerID, err = certificateAttribute(cert, issuerIDAttribute); err != nil {
 		return nil, err
 	}
-	sign, err := newSign()
+	sign, err := newSign(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	ccp.gw, err = client.Connect(id,
-		client.WithSign(sign),
-		client.WithClientConnection(grpcConn),
-		client.WithHash(hash.SHA256),
-	)
-	if err != nil {
-		return nil, err
+	for _, peerConfig := range cfg.Peers() {
+		peer, err := dialPeer(peerConfig, cfg, id, sign)
+		if err != nil {
+			ccp.Close()
+			return nil, err
+		}
+		ccp.peers = append(ccp.peers, peer)
 	}
 
-	ccp.network = ccp.gw.GetNetwork(channelName)
-	ccp.contract = ccp.network.GetContract(chaincodeName)
-
 	return ccp, nil
 }
 
-func newIdentity() (*identity.X509Identity, error) {
-	certPEM, err := readFirstFile(certPath)
+// Close closes the connections to all peers
+func (f *FabricService) Close() {
+	for _, peer := range f.peers {
+		peer.gw.Close()
+		peer.conn.Close()
+	}
+}
+
+// IssuerID returns the issuer the gateway's identity acts for, or "" when it acts for none
+func (f *FabricService) IssuerID() string {
+	return f.issuerID
+}
+
+func newIdentity(cfg *Config) (*identity.X509Identity, *x509.Certificate, error) {
+	certPEM, err := readFirstFile(cfg.CertPath)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	cert, err := identity.CertificateFromPEM(certPEM)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return identity.NewX509Identity(mspID, cert)
+	id, err := identity.NewX509Identity(cfg.MSPID, cert)
+	return id, cert, err
 }
 
-func newSign() (identity.Sign, error) {
-	keyPEM, err := readFirstFile(keyPath)
+// certificateAttribute reads an attribute Fabric CA enrolled into a certificate, "" when it has none
+func certificateAttribute(cert *x509.Certificate, name string) (string, error) {
+	for _, extension := range cert.Extensions {
+		if !extension.Id.Equal(fabricAttributesOID) {
+			continue
+		}
+		var attributes struct {
+			Attrs map[string]string `json:"attrs"`
+		}
+		if err := json.Unmarshal(extension.Value, &attributes); err != nil {
+			return "", fmt.Errorf("failed to parse certificate attributes: %v", err)
+		}
+		return attributes.Attrs[name], nil
+	}
+	return "", nil
+}
+
+func newSign(cfg *Config) (identity.Sign, error) {
+	keyPEM, err := readFirstFile(cfg.KeyPath)
 	if err != nil {
 		return nil, err
 	}
@@ -181,7 +279,7 @@ func readFirstFile(dir string) ([]byte, error) {
 
 // ReadCredential queries the chaincode for a credential by ID
 func (f *FabricService) ReadCredential(id string) (*Credential, error) {
-	result, err := f.contract.EvaluateTransaction("ReadCredential", id)
+	result, err := f.evaluateTransaction("ReadCredential", id)
 	if err != nil {
 		return nil, err
 	}
@@ -194,10 +292,56 @@ func (f *FabricService) ReadCredential(id string) (*Credential, error) {
 
 // GetAllCredentials queries all credentials from the chaincode
 func (f *FabricService) GetAllCredentials() ([]*Credential, error) {
-	result, err := f.contract.EvaluateTransaction("GetAllCredentials")
+	result, err := f.evaluateTransaction("GetAllCredentials")
+	if err != nil {
+		return nil, err
+	}
+	var creds []*Credential
+	if err := json.Unmarshal(result, &creds); err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+// GetCredentialHistory queries every committed version of a credential
+func (f *FabricService) GetCredentialHistory(id string) ([]*CredentialHistoryEntry, error) {
+	result, err := f.evaluateTransaction("GetCredentialHistory", id)
+	if err != nil {
+		return nil, err
+	}
+	var history []*CredentialHistoryEntry
+	if err := json.Unmarshal(result, &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// QueryCredentialsPaginated queries one page of credentials matching the filter
+func (f *FabricService) QueryCredentialsPaginated(filter CredentialFilter, pageSize int32, bookmark string) (*CredentialPage, error) {
+	filterJSON, err := json.Marshal(filter)
+	if err != nil {
+		return nil, err
+	}
+	result, err := f.evaluateTransaction("QueryCredentialsPaginated", string(filterJSON), strconv.FormatInt(int64(pageSize), 10), bookmark)
 	if err != nil {
 		return nil, err
 	}
+	var page CredentialPage
+	if err := json.Unmarshal(result, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// QueryCredentialsByHash queries the credentials issued for a diploma hash through the chaincode index
+func (f *FabricService) QueryCredentialsByHash(diplomaHash string) ([]*Credential, error) {
+	result, err := f.evaluateTransaction("QueryCredentialsByHash", diplomaHash)
+	if err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, nil
+	}
 	var creds []*Credential
 	if err := json.Unmarshal(result, &creds); err != nil {
 		return nil, err
@@ -205,18 +349,32 @@ func (f *FabricService) GetAllCredentials() ([]*Credential, error) {
 	return creds, nil
 }
 
-// CreateCredential submits a transaction to create a new credential
-func (f *FabricService) CreateCredential(cred *Credential) error {
+// CreateCredential submits a transaction to create a new credential and returns the ID the chaincode assigned
+func (f *FabricService) CreateCredential(cred *Credential) (string, error) {
+	credJSON, err := json.Marshal(cred)
+	if err != nil {
+		return "", err
+	}
+	result, err := f.submitTransaction("CreateCredential", string(credJSON))
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
+
+// UpdateCredential submits a transaction replacing the mutable fields of a credential
+func (f *FabricService) UpdateCredential(cred *Credential) error {
 	credJSON, err := json.Marshal(cred)
 	if err != nil {
 		return err
 	}
-	_, err = f.contract.SubmitTransaction("CreateCredential", string(credJSON))
+	_, err = f.submitTransaction("UpdateCredential", string(credJSON))
 	return err
 }
 
-func (f *FabricService) RevokeCredential(id string) error {
-	_, err := f.contract.SubmitTransaction("RevokeCredential", id)
+// RevokeCredential submits a transaction revoking a credential with a reason code and note
+func (f *FabricService) RevokeCredential(id string, reason string, note string) error {
+	_, err := f.submitTransaction("RevokeCredential", id, reason, note)
 
 	if err != nil {
 		return err
@@ -225,17 +383,42 @@ func (f *FabricService) RevokeCredential(id string) error {
 	return nil
 }
 
+// VerifyCredentialSignature asks the chaincode to check the issuer signature of a stored credential
+func (f *FabricService) VerifyCredentialSignature(id string) (*SignatureVerification, error) {
+	result, err := f.evaluateTransaction("VerifyCredentialSignature", id)
+	if err != nil {
+		return nil, err
+	}
+	var verification SignatureVerification
+	if err := json.Unmarshal(result, &verification); err != nil {
+		return nil, err
+	}
+	return &verification, nil
+}
+
+// SuspendCredential submits a transaction placing a credential on hold
+func (f *FabricService) SuspendCredential(id string, note string) error {
+	_, err := f.submitTransaction("SuspendCredential", id, note)
+	return err
+}
+
+// ReinstateCredential submits a transaction returning a suspended credential to valid
+func (f *FabricService) ReinstateCredential(id string) error {
+	_, err := f.submitTransaction("ReinstateCredential", id)
+	return err
+}
+
 // LoadIssuers loads authorized issuers from JSON file
-func LoadIssuers() error {
-	data, err := os.ReadFile("../../backend/issuers.json")
+func LoadIssuers(file string) error {
+	data, err := os.ReadFile(file)
 	if err != nil {
 		return err
 	}
 	return json.Unmarshal(data, &issuers)
 }
 
-func LoadUsers() ([]User, error) {
-	data, err := os.ReadFile("../../backend/users.json")
+func LoadUsers(file string) ([]User, error) {
+	data, err := os.ReadFile(file)
 	if err != nil {
 		return nil, err
 	}
@@ -246,35 +429,95 @@ func LoadUsers() ([]User, error) {
 	return users, nil
 }
 
-// ValidateAPIKey checks if the provided API key is valid for the issuer
-func ValidateAPIKey(issuerID, apiKey string) bool {
-	for _, issuer := range issuers {
-		if issuer.ID == issuerID && issuer.APIKey == apiKey {
-			return true
-		}
-	}
-	return false
-}
-
-// GenerateCredentialID creates a unique ID for the credential
-func GenerateCredentialID(diplomaHash string) string {
+// LegacyCredentialID is the truncated hash ID credentials were created with before the
+// chaincode started deriving issuer namespaced IDs. Such IDs stay readable as aliases.
+func LegacyCredentialID(diplomaHash string) string {
 	h := sha256.New()
 	h.Write([]byte(diplomaHash))
 	return hex.EncodeToString(h.Sum(nil))[:16]
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "hash-password" {
+		if err := runHashPassword(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	cfg, err := LoadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		panic(fmt.Sprintf("Failed to load configuration: %v", err))
+	}
+
 	// Load authorized issuers
-	if err := LoadIssuers(); err != nil {
+	if err := LoadIssuers(cfg.IssuersFile); err != nil {
 		panic(fmt.Sprintf("Failed to load issuers: %v", err))
 	}
 
-	fs, err := ConnectGateway()
+	users, err := LoadUsers(cfg.UsersFile)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to load users: %v", err))
+	}
+	apiKeys, err := LoadAPIKeyStore(cfg.APIKeyStoreFile)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to load API keys: %v", err))
+	}
+	auth, err := NewAuthenticator(cfg.JWTSecret, users, apiKeys)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to load users: %v", err))
+	}
+
+	// fs is nil unless the Fabric backend is selected
+	store, fs, err := NewCredentialStore(cfg)
 	if err != nil {
 		panic(err)
 	}
 
+	// Commit status of transactions submitted without waiting
+	transactions, err := LoadTransactionTracker(cfg.TransactionStoreFile)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to load transactions: %v", err))
+	}
+
+	// Republish chaincode events to gateway subscribers and webhooks
+	hub := NewEventHub()
+
+	webhooks, err := LoadWebhookStore(cfg.WebhookStoreFile)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to load webhooks: %v", err))
+	}
+	go NewWebhookDispatcher(webhooks, apiKeys).Run(hub.Subscribe())
+
+	if fs != nil {
+		// Transactions are endorsed with one identity, which the chaincode only lets act for its own issuer
+		auth.ServeOnlyIssuer(fs.IssuerID())
+		log.Printf("gateway identity acts for issuer %q", fs.IssuerID())
+
+		go fs.MonitorPeers(context.Background())
+		go fs.ResumeIssuerRevocations()
+		go func() {
+			if err := fs.ListenChaincodeEvents(context.Background(), cfg.EventCheckpointFile, hub); err != nil {
+				fmt.Printf("Chaincode event listener stopped: %v\n", err)
+			}
+		}()
+	}
+
+	router := newRouter(cfg, store, fs, auth, apiKeys, transactions, hub, webhooks)
+
+	fmt.Printf("Gateway running on http://%s\n", cfg.ListenAddress)
+	router.Run(cfg.ListenAddress)
+}
+
+// newRouter registers every gateway route. fs is nil unless the Fabric backend is selected.
+func newRouter(cfg *Config, store CredentialStore, fs *FabricService, auth *Authenticator, apiKeys *APIKeyStore,
+	transactions *TransactionTracker, hub *EventHub, webhooks *WebhookStore) *gin.Engine {
 	router := gin.Default()
+	router.Use(metricsMiddleware())
 
 	// Enable CORS
 	router.Use(func(c *gin.Context) {
@@ -288,59 +531,37 @@ func main() {
 		c.Next()
 	})
 
-	router.POST("/auth/login", func(c *gin.Context) {
-		var req struct {
-			Username string `json:"username"`
-			Password string `json:"password"`
-		}
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
-			return
-		}
+	// Operational endpoints
+	router.GET("/healthz", healthzHandler)
+	router.GET("/readyz", readyzHandler(fs, cfg.LedgerBackend))
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
-		users, err := LoadUsers()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load users"})
-			return
-		}
+	// Routes relying on chaincode features beyond CredentialStore
+	fabricRoutes := router.Group("/", requireFabric(fs, cfg.LedgerBackend))
 
-		// Hash the input password
-		h := sha256.New()
-		h.Write([]byte(req.Password))
-		passwordHash := hex.EncodeToString(h.Sum(nil))
+	// /auth/login, /auth/refresh and /auth/logout - Issuer user sessions
+	registerAuthRoutes(router, auth)
 
-		for _, user := range users {
-			if user.Username == req.Username && user.PasswordHash == passwordHash {
-				var issuer Issuer
-				for _, iss := range issuers {
-					if iss.ID == user.IssuerID {
-						issuer = iss
-						break
-					}
-				}
-				var userResponse = struct {
-					Issuer    Issuer `json:"issuer"`
-					Username  string `json:"username"`
-					FirstName string `json:"firstName"`
-					LastName  string `json:"lastName"`
-				}{
-					Issuer:    issuer,
-					Username:  user.Username,
-					FirstName: user.FirstName,
-					LastName:  user.LastName,
-				}
-				c.JSON(http.StatusOK, userResponse)
-				return
-			}
-		}
+	// /admin/issuers/:issuerId/api-keys and /admin/api-keys/:id - Issuer API keys, for admin users
+	registerAPIKeyRoutes(router.Group("/", auth.requireAdmin()), apiKeys)
 
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
-	})
+	// Routes submitting with the gateway's identity for admin users
+	adminFabricRoutes := fabricRoutes.Group("/", auth.requireAdmin())
+
+	// /governance and /issuer-proposals - Issuer onboarding, voted on by admin users
+	registerGovernanceRoutes(fabricRoutes, adminFabricRoutes, fs)
+
+	// /admin/issuers/:issuerId/revoke and /issuers/:issuerId/revocation - Issuer revocation and its progress
+	registerIssuerRevocationRoutes(fabricRoutes, adminFabricRoutes, fs)
+
+	// Routes acting for an issuer, with a bearer access token or an API key
+	issuerRoutes := router.Group("/", auth.requireIssuer())
+	issuerFabricRoutes := fabricRoutes.Group("/", auth.requireIssuer())
 
 	// GET /credential/:id - Read credential by ID
 	router.GET("/credential/:id", func(c *gin.Context) {
 		id := c.Param("id")
-		cred, err := fs.ReadCredential(id)
+		cred, err := store.ReadCredential(id)
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Credential not found", "details": err.Error()})
 			return
@@ -348,33 +569,38 @@ func main() {
 		c.JSON(http.StatusOK, cred)
 	})
 
-	// POST /credential - Create new credential (with API key validation)
-	router.POST("/credential", func(c *gin.Context) {
-		apiKey := c.GetHeader("X-API-Key")
-		if apiKey == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "API key is required"})
+	// GET /credential/:id/history - Audit trail of a credential
+	fabricRoutes.GET("/credential/:id/history", func(c *gin.Context) {
+		id := c.Param("id")
+		history, err := fs.GetCredentialHistory(id)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Credential history not found", "details": err.Error()})
 			return
 		}
+		c.JSON(http.StatusOK, gin.H{
+			"credentialId": id,
+			"history":      history,
+			"count":        len(history),
+		})
+	})
 
+	// POST /credential - Create new credential for the authenticated issuer
+	issuerRoutes.POST("/credential", requireScope(scopeIssue), func(c *gin.Context) {
 		var req CreateCredentialRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
 			return
 		}
 
-		// Validate API key
-		if !ValidateAPIKey(req.IssuerID, apiKey) {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key for issuer"})
+		if !issuerAuthorized(c, req.IssuerID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized for issuer " + req.IssuerID})
 			return
 		}
 
-		// Generate unique credential ID
-		credentialID := GenerateCredentialID(req.DiplomaHash)
-
-		// Create credential object
+		// Create credential object, the chaincode derives its ID
 		credential := &Credential{
-			ID:                credentialID,
 			DiplomaHash:       req.DiplomaHash,
+			HashScheme:        req.HashScheme,
 			GraduatePublicKey: req.GraduatePublicKey,
 			IssuerID:          req.IssuerID,
 			IssuerSignature:   req.IssuerSignature,
@@ -383,51 +609,154 @@ func main() {
 			CredentialType:    req.CredentialType,
 		}
 
-		// Submit to blockchain
-		if err := fs.CreateCredential(credential); err != nil {
+		// Rejected here with the chaincode's message, whichever backend is selected
+		if _, err := validateNewCredential(credential); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid credential", "details": err.Error()})
+			return
+		}
+
+		// Only the salted hash of graduate details goes on the public ledger
+		if req.GraduateDetails != nil {
+			detailsHash, err := SaltGraduateDetails(req.GraduateDetails)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash graduate details", "details": err.Error()})
+				return
+			}
+			credential.GraduateDetailsHash = detailsHash
+		}
+
+		async, ok := store.(asyncCredentialCreator)
+		if !ok {
+			if req.GraduateDetails != nil {
+				c.JSON(http.StatusNotImplemented, gin.H{"error": "graduateDetails require the Fabric ledger backend"})
+				return
+			}
+
+			credentialID, err := store.CreateCredential(credential)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to create credential", "details": err.Error()})
+				return
+			}
+			credential.ID = credentialID
+
+			c.JSON(http.StatusCreated, gin.H{
+				"message":      "Credential created successfully",
+				"credentialId": credentialID,
+				"credential":   credential,
+			})
+			return
+		}
+
+		// Submit to blockchain, endorsement errors are reported right away and the commit is tracked in the background
+		credentialID, commit, err := async.CreateCredentialAsync(credential)
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create credential", "details": err.Error()})
 			return
 		}
+		credential.ID = credentialID
+
+		// Graduate details are checked against the committed credential, so they are stored after the commit
+		var onCommit func() error
+		if details := req.GraduateDetails; details != nil {
+			onCommit = func() error {
+				if err := fs.PutGraduateDetails(credentialID, credential.IssuerID, details); err != nil {
+					return fmt.Errorf("credential created but graduate details could not be stored, retry with PUT /credential/:id/private: %v", err)
+				}
+				return nil
+			}
+		}
 
-		c.JSON(http.StatusCreated, gin.H{
-			"message":      "Credential created successfully",
+		// The details themselves are not written to disk, after a restart they have to be sent again
+		status := transactions.Track("CreateCredential", []byte(credentialID), commit,
+			"graduate details were not stored, send them with PUT /credential/:id/private once the credential exists", onCommit)
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"message":      "Credential submitted",
 			"credentialId": credentialID,
+			"txId":         status.TxID,
+			"status":       status.Status,
+			"statusUrl":    "/transactions/" + status.TxID,
 			"credential":   credential,
 		})
 	})
 
+	// POST /commitments - Salted commitment of a diploma hash for issuing without a guessable hash
+	issuerRoutes.POST("/commitments", requireScope(scopeIssue), commitmentHandler)
+
 	// POST /verify/hash - Verify diploma hash exists
-	router.POST("/verify/hash", func(c *gin.Context) {
+	fabricRoutes.POST("/verify/hash", func(c *gin.Context) {
 		var req VerifyHashRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
 			return
 		}
 
-		// Generate credential ID from hash
-		credentialID := GenerateCredentialID(req.DiplomaHash)
+		// With a salt the ledger holds the commitment, not the file hash
+		lookupHash := req.DiplomaHash
+		if req.Salt != "" {
+			commitment, err := DiplomaCommitment(req.DiplomaHash, req.Salt)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"verified": false, "error": "Invalid diploma hash or salt", "details": err.Error()})
+				return
+			}
+			lookupHash = commitment
+		}
 
-		// Try to read the credential
-		credential, err := fs.ReadCredential(credentialID)
+		// Look the hash up in the chaincode hash~credential index
+		matches, err := fs.QueryCredentialsByHash(lookupHash)
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{
+			verifications.WithLabelValues("hash", verificationError).Inc()
+			c.JSON(http.StatusInternalServerError, gin.H{
 				"verified": false,
-				"message":  "Diploma hash not found in blockchain",
+				"error":    "Failed to look up diploma hash",
+				"details":  err.Error(),
 			})
 			return
 		}
 
+		// A plain hash only verifies unsalted credentials, so a commitment read off the ledger does not act as a file hash
+		var credentials []*Credential
+		for _, match := range matches {
+			if isSaltedCredential(match) == (req.Salt != "") {
+				credentials = append(credentials, match)
+			}
+		}
+
+		var credential *Credential
+		var credentialID string
+		if len(credentials) > 0 {
+			credential = credentials[0]
+			credentialID = strings.TrimPrefix(credential.ID, credentialKeyPrefix)
+		} else {
+			// Credentials written before the index existed are found by their legacy ID
+			credential, err = fs.ReadCredential(LegacyCredentialID(lookupHash))
+			if err != nil || isSaltedCredential(credential) != (req.Salt != "") {
+				verifications.WithLabelValues("hash", verificationNotFound).Inc()
+				c.JSON(http.StatusNotFound, gin.H{
+					"verified": false,
+					"message":  "Diploma hash not found in blockchain",
+				})
+				return
+			}
+			credentialID = strings.TrimPrefix(credential.ID, credentialKeyPrefix)
+		}
+
+		issuer := fs.IssuerStanding(credential.IssuerID)
+		recordVerification("hash", credential, issuer)
 		c.JSON(http.StatusOK, gin.H{
 			"verified":     true,
 			"message":      "Diploma hash verified",
 			"credentialId": credentialID,
 			"status":       credential.Status,
 			"issuerId":     credential.IssuerID,
+			"issuer":       issuer,
+			"revocation":   credential.Revocation,
+			"suspension":   credential.Suspension,
 		})
 	})
 
 	// POST /verify/signature - Verify graduate signature and return full diploma data
-	router.POST("/verify/signature", func(c *gin.Context) {
+	fabricRoutes.POST("/verify/signature", func(c *gin.Context) {
 		var req VerifySignatureRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
@@ -437,6 +766,7 @@ func main() {
 		// Read credential from blockchain
 		credential, err := fs.ReadCredential(req.CredentialID)
 		if err != nil {
+			verifications.WithLabelValues("signature", verificationNotFound).Inc()
 			c.JSON(http.StatusNotFound, gin.H{
 				"verified": false,
 				"error":    "Credential not found",
@@ -447,8 +777,6 @@ func main() {
 		// Verify PGP signature
 		publicKeyArmored := credential.GraduatePublicKey
 
-		fmt.Println(publicKeyArmored)
-
 		// Decode public key
 		keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(publicKeyArmored))
 		if err != nil {
@@ -477,33 +805,136 @@ func main() {
 			cfg,
 		)
 		if err != nil {
+			verifications.WithLabelValues("signature", verificationBadSignature).Inc()
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error": "signature verification failed",
 			})
 			return
 		}
 
+		// Issuer signature is checked by the chaincode against the ledger key
+		issuerSignature, err := fs.VerifyCredentialSignature(req.CredentialID)
+		if err != nil {
+			verifications.WithLabelValues("signature", verificationError).Inc()
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"verified": false,
+				"error":    "Failed to verify issuer signature",
+				"details":  err.Error(),
+			})
+			return
+		}
+
+		if !issuerSignature.Valid {
+			verifications.WithLabelValues("signature", verificationBadSignature).Inc()
+			c.JSON(http.StatusOK, gin.H{
+				"verified":        false,
+				"message":         "Issuer signature is not valid",
+				"issuerSignature": issuerSignature,
+				"status":          credential.Status,
+				"revocation":      credential.Revocation,
+				"credential":      credential,
+			})
+			return
+		}
+
+		issuer := fs.IssuerStanding(credential.IssuerID)
+		recordVerification("signature", credential, issuer)
 		c.JSON(http.StatusOK, gin.H{
-			"verified":   true,
-			"message":    "Graduate signature verified",
-			"credential": credential,
+			"verified":        true,
+			"message":         "Graduate signature verified",
+			"issuerSignature": issuerSignature,
+			"issuer":          issuer,
+			"status":          credential.Status,
+			"revocation":      credential.Revocation,
+			"credential":      credential,
 		})
 	})
 
-	// GET /credentials - Get all credentials with optional university filter
-	router.GET("/credentials", func(c *gin.Context) {
-		universityFilter := c.Query("university")
-
-		// University ID is required for security
+	// GET /credentials - List an issuer's credentials, filtered and paginated in the chaincode
+	issuerRoutes.GET("/credentials", requireScope(scopeList), func(c *gin.Context) {
+		// A logged in user lists their own issuer unless a university is given
+		universityFilter := c.DefaultQuery("university", authenticatedIssuer(c))
 		if universityFilter == "" {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error": "University ID is required",
 			})
 			return
 		}
+		if !issuerAuthorized(c, universityFilter) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized for issuer " + universityFilter})
+			return
+		}
+
+		filter := CredentialFilter{
+			IssuerID:       universityFilter,
+			Status:         c.Query("status"),
+			CredentialType: c.Query("credentialType"),
+			IssuedFrom:     c.Query("issuedFrom"),
+			IssuedTo:       c.Query("issuedTo"),
+		}
+
+		// Backends without chaincode queries filter the full list in the gateway
+		if fs == nil {
+			all, err := store.GetAllCredentials()
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   "Failed to retrieve credentials",
+					"details": err.Error(),
+				})
+				return
+			}
+
+			credentials := []*Credential{}
+			for _, credential := range all {
+				if filter.Matches(credential) {
+					credentials = append(credentials, credential)
+				}
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"credentials": credentials,
+				"count":       len(credentials),
+			})
+			return
+		}
+
+		// Without pageSize the whole filtered list is returned, following bookmarks in the gateway
+		pageSizeParam := c.Query("pageSize")
+		if pageSizeParam == "" {
+			var credentials []*Credential
+			bookmark := ""
+			for {
+				page, err := fs.QueryCredentialsPaginated(filter, 0, bookmark)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"error":   "Failed to retrieve credentials",
+						"details": err.Error(),
+					})
+					return
+				}
+				credentials = append(credentials, page.Credentials...)
+				bookmark = page.Bookmark
+				if bookmark == "" {
+					break
+				}
+			}
 
-		// Get all credentials from blockchain
-		credentials, err := fs.GetAllCredentials()
+			c.JSON(http.StatusOK, gin.H{
+				"credentials": credentials,
+				"count":       len(credentials),
+			})
+			return
+		}
+
+		pageSize, err := strconv.ParseInt(pageSizeParam, 10, 32)
+		if err != nil || pageSize <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "pageSize must be a positive integer",
+			})
+			return
+		}
+
+		page, err := fs.QueryCredentialsPaginated(filter, int32(pageSize), c.Query("bookmark"))
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "Failed to retrieve credentials",
@@ -512,25 +943,36 @@ func main() {
 			return
 		}
 
-		// Filter by university ID
-		var filtered []*Credential
-		for _, cred := range credentials {
-			if cred.IssuerID == universityFilter {
-				filtered = append(filtered, cred)
-			}
-		}
-
 		c.JSON(http.StatusOK, gin.H{
-			"credentials": filtered,
-			"count":       len(filtered),
+			"credentials": page.Credentials,
+			"count":       page.Count,
+			"bookmark":    page.Bookmark,
 		})
 	})
 
-	// PATCH /credential/:id/revoke - Revoke credential by ID
-	router.PATCH("/credential/:id/revoke", func(c *gin.Context) {
+	// GET /transactions/:txId - Commit status of a transaction submitted asynchronously
+	fabricRoutes.GET("/transactions/:txId", transactionStatusHandler(transactions))
+
+	// POST /credentials/batch - Issue many credentials from a JSON array or CSV upload
+	issuerFabricRoutes.POST("/credentials/batch", requireScope(scopeIssue), batchCredentialsHandler(fs))
+
+	// POST /credentials/search - Search credentials with a filter DSL translated to a CouchDB selector
+	fabricRoutes.POST("/credentials/search", auth.requireIssuerOrAdmin(), requireScope(scopeList), searchCredentialsHandler(fs))
+
+	// PATCH /credential/:id/revoke - Revoke credential by ID with a reason code
+	issuerRoutes.PATCH("/credential/:id/revoke", requireScope(scopeRevoke), func(c *gin.Context) {
 		id := c.Param("id")
+		if authorizeCredentialIssuer(c, store, id) == nil {
+			return
+		}
+
+		var req RevokeCredentialRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+			return
+		}
 
-		err := fs.RevokeCredential(id)
+		err := store.RevokeCredential(id, req.Reason, req.Note)
 		if err != nil {
 			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Credential not found or could not be processed", "details": err.Error()})
 			return
@@ -539,6 +981,58 @@ func main() {
 		c.Status(http.StatusNoContent)
 	})
 
-	fmt.Println("Gateway running on http://0.0.0.0:8080")
-	router.Run("0.0.0.0:8080")
+	// PATCH /credential/:id/suspend - Temporarily suspend a valid credential
+	issuerFabricRoutes.PATCH("/credential/:id/suspend", requireScope(scopeRevoke), func(c *gin.Context) {
+		id := c.Param("id")
+		if authorizeCredentialIssuer(c, fs, id) == nil {
+			return
+		}
+
+		var req SuspendCredentialRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+				return
+			}
+		}
+
+		if err := fs.SuspendCredential(id, req.Note); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Credential not found or could not be suspended", "details": err.Error()})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	})
+
+	// PATCH /credential/:id/reinstate - Reinstate a suspended credential
+	issuerFabricRoutes.PATCH("/credential/:id/reinstate", requireScope(scopeRevoke), func(c *gin.Context) {
+		id := c.Param("id")
+		if authorizeCredentialIssuer(c, fs, id) == nil {
+			return
+		}
+
+		if err := fs.ReinstateCredential(id); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Credential not found or could not be reinstated", "details": err.Error()})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	})
+
+	// /credential/:id/private - Graduate details from the issuer's private data collection
+	registerPrivateDetailsRoutes(issuerFabricRoutes, fs)
+
+	// /issuers/:issuerId/keys - Issuer signing keys and their rotation
+	registerIssuerKeyRoutes(fabricRoutes, issuerFabricRoutes, fs)
+
+	// /credential/:id/vc and /verify/vc - W3C verifiable credentials signed with the issuer key
+	registerVCRoutes(fabricRoutes, fs, cfg)
+
+	// GET /events - Server-Sent Events stream of credential and issuer changes
+	router.GET("/events", eventStreamHandler(hub))
+
+	// /webhooks - Callback subscriptions for credential status changes of the caller's issuer
+	registerWebhookRoutes(issuerRoutes.Group("/", requireScope(scopeWebhooks)), webhooks, store)
+
+	return router
 }
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// memoryGateway is the gateway on the memory backend with an API key for issuer lu carrying
+// every scope and one for issuer rtu with the list scope only
+type memoryGateway struct {
+	router   *gin.Engine
+	store    *MemoryCredentialStore
+	apiKeys  *APIKeyStore
+	webhooks *WebhookStore
+	luKey    string
+	rtuKey   string
+}
+
+func newMemoryGateway(t *testing.T) *memoryGateway {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	apiKeys, err := LoadAPIKeyStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, luKey, err := apiKeys.Create("lu", CreateAPIKeyRequest{Name: "test", Scopes: []string{scopeIssue, scopeRevoke, scopeList}}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, rtuKey, err := apiKeys.Create("rtu", CreateAPIKeyRequest{Name: "test", Scopes: []string{scopeList}}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth, err := NewAuthenticator("", nil, apiKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	webhooks, _ := LoadWebhookStore("")
+	transactions, _ := LoadTransactionTracker("")
+
+	store := NewMemoryCredentialStore([]Issuer{
+		{ID: "lu", Name: "University of Latvia"},
+		{ID: "rtu", Name: "Riga Technical University", Status: "Active"},
+		{ID: "old", Name: "Closed College", Status: "Revoked"},
+	}, "Org1MSP")
+	cfg := &Config{LedgerBackend: ledgerBackendMemory}
+	router := newRouter(cfg, store, nil, auth, apiKeys, transactions, NewEventHub(), webhooks)
+
+	return &memoryGateway{router: router, store: store, apiKeys: apiKeys, webhooks: webhooks, luKey: luKey, rtuKey: rtuKey}
+}
+
+func (g *memoryGateway) call(apiKey string, method string, path string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	w := httptest.NewRecorder()
+	g.router.ServeHTTP(w, req)
+	return w
+}
+
+const (
+	testDiplomaHash = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	testPublicKey   = "-----BEGIN PGP PUBLIC KEY BLOCK-----\ngraduate\n-----END PGP PUBLIC KEY BLOCK-----"
+)
+
+func credentialBody(issuerID string, diplomaHash string, hashScheme string) string {
+	body, _ := json.Marshal(map[string]any{
+		"diplomaHash":       diplomaHash,
+		"hashScheme":        hashScheme,
+		"graduatePublicKey": testPublicKey,
+		"issuerId":          issuerID,
+		"issuerSignature":   "signature",
+		"credentialType":    "Bachelor",
+		"diplomaMetadata": map[string]string{
+			"universityName": "University of Latvia",
+			"degreeName":     "Computer Science",
+			"issueDate":      "2025-06-20",
+		},
+	})
+	return string(body)
+}
+
+func TestMemoryBackendCredentialLifecycle(t *testing.T) {
+	g := newMemoryGateway(t)
+
+	w := g.call(g.luKey, http.MethodPost, "/credential", credentialBody("lu", testDiplomaHash, ""))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", w.Code, w.Body)
+	}
+	var created struct {
+		CredentialID string `json:"credentialId"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if want := NewCredentialID("lu", testDiplomaHash, testPublicKey); created.CredentialID != want {
+		t.Fatalf("credentialId = %q, want the ledger ID %q", created.CredentialID, want)
+	}
+
+	w = g.call("", http.MethodGet, "/credential/"+created.CredentialID, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("read: %d %s", w.Code, w.Body)
+	}
+	var credential Credential
+	json.Unmarshal(w.Body.Bytes(), &credential)
+	if credential.Status != "Valid" || credential.IssuerID != "lu" || credential.SignedAt == "" {
+		t.Errorf("unexpected credential %+v", credential)
+	}
+
+	if w := g.call(g.luKey, http.MethodPost, "/credential", credentialBody("lu", testDiplomaHash, "")); w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "already exists") {
+		t.Errorf("duplicate: %d %s", w.Code, w.Body)
+	}
+
+	revoke := "/credential/" + created.CredentialID + "/revoke"
+	if w := g.call(g.luKey, http.MethodPatch, revoke, `{"reason":"forgotten"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown reason: %d, want 400", w.Code)
+	}
+	if w := g.call(g.rtuKey, http.MethodPatch, revoke, `{"reason":"error"}`); w.Code != http.StatusForbidden {
+		t.Errorf("revoke by another issuer: %d, want 403", w.Code)
+	}
+	if w := g.call(g.luKey, http.MethodPatch, revoke, `{"reason":"error","note":"wrong degree"}`); w.Code != http.StatusNoContent {
+		t.Fatalf("revoke: %d %s", w.Code, w.Body)
+	}
+	if w := g.call(g.luKey, http.MethodPatch, revoke, `{"reason":"error"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("second revoke: %d, want 422", w.Code)
+	}
+
+	stored, err := g.store.ReadCredential(created.CredentialID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != "Revoked" || stored.Revocation == nil || stored.Revocation.Reason != "error" || stored.Revocation.Note != "wrong degree" {
+		t.Errorf("unexpected revoked credential %+v", stored)
+	}
+}
+
+func TestMemoryBackendRejectsWhatTheChaincodeRejects(t *testing.T) {
+	g := newMemoryGateway(t)
+
+	tests := []struct {
+		name   string
+		apiKey string
+		body   string
+		status int
+		error  string
+	}{
+		{"no credentials", "", credentialBody("lu", testDiplomaHash, ""), http.StatusUnauthorized, ""},
+		{"missing scope", g.rtuKey, credentialBody("rtu", testDiplomaHash, ""), http.StatusForbidden, ""},
+		{"other issuer", g.luKey, credentialBody("rtu", testDiplomaHash, ""), http.StatusForbidden, ""},
+		{"unknown hash scheme", g.luKey, credentialBody("lu", testDiplomaHash, "md5"), http.StatusBadRequest, ""},
+		{"commitment is not hex", g.luKey, credentialBody("lu", "not a commitment", hashSchemeHMACSHA256), http.StatusBadRequest, "HMAC-SHA256 commitment"},
+		{"commitment is short", g.luKey, credentialBody("lu", "abcd", hashSchemeHMACSHA256), http.StatusBadRequest, "HMAC-SHA256 commitment"},
+	}
+	for _, tt := range tests {
+		w := g.call(tt.apiKey, http.MethodPost, "/credential", tt.body)
+		if w.Code != tt.status || !strings.Contains(w.Body.String(), tt.error) {
+			t.Errorf("%s: %d %s, want %d %q", tt.name, w.Code, w.Body, tt.status, tt.error)
+		}
+	}
+
+	if credentials, _ := g.store.GetAllCredentials(); len(credentials) != 0 {
+		t.Errorf("rejected requests stored %d credentials", len(credentials))
+	}
+}
+
+func TestMemoryStoreRequiresActiveIssuer(t *testing.T) {
+	store := NewMemoryCredentialStore([]Issuer{
+		{ID: "lu"},
+		{ID: "old", Status: "Revoked"},
+		{ID: "new", Status: "Proposed"},
+	}, "Org1MSP")
+
+	credential := func(issuerID string) *Credential {
+		return &Credential{IssuerID: issuerID, DiplomaHash: testDiplomaHash, GraduatePublicKey: testPublicKey}
+	}
+
+	if _, err := store.CreateCredential(credential("lu")); err != nil {
+		t.Errorf("issuer without status: %v", err)
+	}
+	for _, issuerID := range []string{"old", "new"} {
+		if _, err := store.CreateCredential(credential(issuerID)); err == nil || !strings.Contains(err.Error(), "is not active") {
+			t.Errorf("issuer %s: %v, want not active", issuerID, err)
+		}
+	}
+	if _, err := store.CreateCredential(credential("unknown")); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("unknown issuer: %v, want does not exist", err)
+	}
+}
+
+func TestMemoryBackendListsOwnCredentials(t *testing.T) {
+	g := newMemoryGateway(t)
+	for _, hash := range []string{testDiplomaHash, strings.Repeat("a", 64)} {
+		if w := g.call(g.luKey, http.MethodPost, "/credential", credentialBody("lu", hash, "")); w.Code != http.StatusCreated {
+			t.Fatalf("create: %d %s", w.Code, w.Body)
+		}
+	}
+
+	var list struct {
+		Credentials []Credential `json:"credentials"`
+		Count       int          `json:"count"`
+	}
+	w := g.call(g.luKey, http.MethodGet, "/credentials?credentialType=Bachelor", "")
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if w.Code != http.StatusOK || list.Count != 2 {
+		t.Errorf("list: %d %s, want 2 credentials", w.Code, w.Body)
+	}
+
+	w = g.call(g.luKey, http.MethodGet, "/credentials?credentialType=Master", "")
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if w.Code != http.StatusOK || list.Count != 0 {
+		t.Errorf("filtered list: %d %s, want no credentials", w.Code, w.Body)
+	}
+
+	if w := g.call(g.rtuKey, http.MethodGet, "/credentials?university=lu", ""); w.Code != http.StatusForbidden {
+		t.Errorf("list of another issuer: %d, want 403", w.Code)
+	}
+}
+
+func TestMemoryBackendRefusesFabricRoutes(t *testing.T) {
+	g := newMemoryGateway(t)
+	for _, path := range []string{"/credential/lu-1/history", "/governance", "/issuers/lu/keys"} {
+		if w := g.call("", http.MethodGet, path, ""); w.Code != http.StatusNotImplemented {
+			t.Errorf("GET %s: %d, want 501", path, w.Code)
+		}
+	}
+}
+
+// publicRoutes answer without an access token or API key, every other route must refuse such callers
+var publicRoutes = map[string]bool{
+	"GET /healthz":                       true,
+	"GET /readyz":                        true,
+	"GET /metrics":                       true,
+	"POST /auth/login":                   true,
+	"POST /auth/refresh":                 true,
+	"POST /auth/logout":                  true,
+	"GET /events":                        true,
+	"GET /credential/:id":                true,
+	"GET /credential/:id/history":        true,
+	"GET /credential/:id/status":         true,
+	"GET /credential/:id/vc":             true,
+	"GET /transactions/:txId":            true,
+	"POST /verify/hash":                  true,
+	"POST /verify/signature":             true,
+	"POST /verify/vc":                    true,
+	"GET /governance":                    true,
+	"GET /issuer-proposals":              true,
+	"GET /issuer-proposals/:id":          true,
+	"GET /issuers/:issuerId/keys":        true,
+	"GET /issuers/:issuerId/keys/:keyId": true,
+	"GET /issuers/:issuerId/revocation":  true,
+}
+
+func TestOnlyPublicRoutesAnswerUnauthenticatedCallers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	apiKeys, _ := LoadAPIKeyStore("")
+	auth, err := NewAuthenticator("", nil, apiKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	webhooks, _ := LoadWebhookStore("")
+	transactions, _ := LoadTransactionTracker("")
+	// A Fabric service that is never connected, requests must be refused before reaching it
+	cfg := &Config{LedgerBackend: ledgerBackendFabric}
+	router := newRouter(cfg, NewMemoryCredentialStore(nil, "Org1MSP"), &FabricService{}, auth, apiKeys, transactions, NewEventHub(), webhooks)
+
+	params := regexp.MustCompile(`[:*][^/]+`)
+	registered := make(map[string]bool)
+	for _, route := range router.Routes() {
+		name := route.Method + " " + route.Path
+		registered[name] = true
+		if publicRoutes[name] {
+			continue
+		}
+
+		for _, header := range []string{"", "Authorization: Bearer not-a-token", "X-API-Key: not-a-key"} {
+			req := httptest.NewRequest(route.Method, params.ReplaceAllString(route.Path, "x"), strings.NewReader("{}"))
+			req.Header.Set("Content-Type", "application/json")
+			if key, value, ok := strings.Cut(header, ": "); ok {
+				req.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("%s with %q: %d, want 401 or an entry in publicRoutes", name, header, w.Code)
+			}
+		}
+	}
+
+	for name := range publicRoutes {
+		if !registered[name] {
+			t.Errorf("public route %s is not registered", name)
+		}
+	}
+}
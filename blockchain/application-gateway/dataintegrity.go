@@ -0,0 +1,412 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Data Integrity cryptosuites, both sign the JCS canonical form of the document
+const (
+	cryptosuiteECDSA = "ecdsa-jcs-2019" // ECDSA P-256
+	cryptosuiteEdDSA = "eddsa-jcs-2022" // Ed25519
+)
+
+// Multicodec prefixes of Multikey public keys
+var (
+	multicodecP256    = []byte{0x80, 0x24}
+	multicodecEd25519 = []byte{0xed, 0x01}
+)
+
+// vcSigner is the private half of an issuer's assertion key, used for Data Integrity proofs
+type vcSigner struct {
+	key   crypto.Signer
+	keyID string // Same as the chaincode ID of the matching public key
+}
+
+// loadVCSigner reads <issuerId>.pem from dir, a PKCS #8 or SEC 1 private key. It must be the
+// issuer's assertion key, never the key signing its credentials onto the ledger.
+func loadVCSigner(dir string, issuerID string) (*vcSigner, error) {
+	data, err := os.ReadFile(filepath.Join(dir, filepath.Base(issuerID)+".pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block in assertion key of issuer %s", issuerID)
+	}
+
+	var key any
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse assertion key of issuer %s: %v", issuerID, err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported signing key type %T", key)
+	}
+	if _, err := cryptosuiteFor(signer.Public()); err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &vcSigner{key: signer, keyID: fingerprintDER(der)}, nil
+}
+
+// cryptosuiteFor returns the cryptosuite signing with a public key's algorithm
+func cryptosuiteFor(key crypto.PublicKey) (string, error) {
+	switch pub := key.(type) {
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return "", fmt.Errorf("unsupported ECDSA curve %s, only P-256 is accepted", pub.Curve.Params().Name)
+		}
+		return cryptosuiteECDSA, nil
+	case ed25519.PublicKey:
+		return cryptosuiteEdDSA, nil
+	default:
+		return "", fmt.Errorf("unsupported key type %T, Data Integrity proofs need ECDSA P-256 or Ed25519", key)
+	}
+}
+
+// proofHashData is SHA-256(JCS(proof options)) || SHA-256(JCS(document)), what both cryptosuites sign
+func proofHashData(options map[string]any, document map[string]any) ([]byte, error) {
+	canonicalOptions, err := canonicalJSON(options)
+	if err != nil {
+		return nil, err
+	}
+	canonicalDocument, err := canonicalJSON(document)
+	if err != nil {
+		return nil, err
+	}
+
+	optionsHash := sha256.Sum256(canonicalOptions)
+	documentHash := sha256.Sum256(canonicalDocument)
+	return append(optionsHash[:], documentHash[:]...), nil
+}
+
+// sign returns the multibase proofValue over hashData. ECDSA signatures are r || s.
+func (s *vcSigner) sign(hashData []byte) (string, error) {
+	var signature []byte
+	switch key := s.key.(type) {
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256(hashData)
+		r, sv, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			return "", err
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		sv.FillBytes(signature[32:])
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(key, hashData)
+	default:
+		return "", fmt.Errorf("unsupported signing key type %T", s.key)
+	}
+	return "z" + base58Encode(signature), nil
+}
+
+// verifyProofValue checks a multibase proofValue over hashData with a ledger public key
+func verifyProofValue(publicKey string, cryptosuite string, hashData []byte, proofValue string) error {
+	key, err := parsePublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+	expected, err := cryptosuiteFor(key)
+	if err != nil {
+		return err
+	}
+	if expected != cryptosuite {
+		return fmt.Errorf("cryptosuite %s does not match the %s issuer key", cryptosuite, expected)
+	}
+
+	if !strings.HasPrefix(proofValue, "z") {
+		return errors.New("proofValue must be base58btc multibase")
+	}
+	signature, err := base58Decode(proofValue[1:])
+	if err != nil {
+		return err
+	}
+
+	switch pub := key.(type) {
+	case *ecdsa.PublicKey:
+		if len(signature) != 64 {
+			return errors.New("ECDSA proofValue must be 64 bytes")
+		}
+		digest := sha256.Sum256(hashData)
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return errors.New("proof signature does not match")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(pub, hashData, signature) {
+			return errors.New("proof signature does not match")
+		}
+	}
+	return nil
+}
+
+// parsePublicKey accepts a PKIX public key PEM armored or as base64 DER, like the chaincode
+func parsePublicKey(publicKey string) (crypto.PublicKey, error) {
+	der, err := decodePublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParsePKIXPublicKey(der)
+}
+
+func decodePublicKey(publicKey string) ([]byte, error) {
+	if block, _ := pem.Decode([]byte(publicKey)); block != nil {
+		return block.Bytes, nil
+	}
+	der, err := base64.StdEncoding.DecodeString(strings.TrimSpace(publicKey))
+	if err != nil {
+		return nil, fmt.Errorf("public key is neither PEM nor base64 DER: %v", err)
+	}
+	return der, nil
+}
+
+// keyFingerprint computes the ID the chaincode gives an issuer key
+func keyFingerprint(publicKey string) string {
+	der, err := decodePublicKey(publicKey)
+	if err != nil {
+		der = []byte(publicKey)
+	}
+	return fingerprintDER(der)
+}
+
+func fingerprintDER(der []byte) string {
+	h := sha256.Sum256(der)
+	return hex.EncodeToString(h[:8])
+}
+
+// multikey encodes a ledger public key as a Multikey publicKeyMultibase value
+func multikey(publicKey string) (string, error) {
+	key, err := parsePublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	if _, err := cryptosuiteFor(key); err != nil {
+		return "", err
+	}
+
+	var encoded []byte
+	switch pub := key.(type) {
+	case *ecdsa.PublicKey:
+		point, err := pub.ECDH()
+		if err != nil {
+			return "", err
+		}
+		// Compressed point: 0x02 or 0x03 by the parity of y, followed by x
+		uncompressed := point.Bytes()
+		compressed := append([]byte{0x02 | uncompressed[64]&1}, uncompressed[1:33]...)
+		encoded = append(append([]byte{}, multicodecP256...), compressed...)
+	case ed25519.PublicKey:
+		encoded = append(append([]byte{}, multicodecEd25519...), pub...)
+	}
+	return "z" + base58Encode(encoded), nil
+}
+
+// canonicalJSON serializes a value with the JSON Canonicalization Scheme (RFC 8785)
+func canonicalJSON(v any) ([]byte, error) {
+	// Round trip through encoding/json so structs and maps become plain JSON values
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := writeCanonical(&buf, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeCanonical(buf *bytes.Buffer, value any) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		buf.WriteString(canonicalNumber(f))
+	case string:
+		writeCanonicalString(buf, v)
+	case []any:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonical(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]any:
+		// Members are sorted by the UTF-16 code units of their names
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return lessUTF16(keys[i], keys[j]) })
+
+		buf.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeCanonicalString(buf, key)
+			buf.WriteByte(':')
+			if err := writeCanonical(buf, v[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("cannot canonicalize %T", value)
+	}
+	return nil
+}
+
+// canonicalNumber formats a number like ECMAScript Number.prototype.toString
+func canonicalNumber(f float64) string {
+	if f == 0 {
+		return "0"
+	}
+	abs := f
+	if abs < 0 {
+		abs = -abs
+	}
+	if abs >= 1e-6 && abs < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	s := strconv.FormatFloat(f, 'e', -1, 64)
+	mantissa, exponent, _ := strings.Cut(s, "e")
+	sign := exponent[0]
+	exponent = strings.TrimLeft(exponent[1:], "0")
+	return mantissa + "e" + string(sign) + exponent
+}
+
+// writeCanonicalString escapes only quotes, backslashes and control characters
+func writeCanonicalString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buf, `\u%04x`, r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+func lessUTF16(a string, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// base58Encode encodes with the Bitcoin alphabet used by multibase base58btc
+func base58Encode(data []byte) string {
+	n := new(big.Int).SetBytes(data)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		out = append(out, base58Alphabet[0])
+	}
+
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+func base58Decode(s string) ([]byte, error) {
+	n := new(big.Int)
+	radix := big.NewInt(58)
+	for _, r := range s {
+		digit := strings.IndexRune(base58Alphabet, r)
+		if digit < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", r)
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(digit)))
+	}
+
+	zeros := 0
+	for zeros < len(s) && s[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
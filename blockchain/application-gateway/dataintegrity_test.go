@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"math"
+	"math/big"
+	"testing"
+)
+
+// RFC 8785 Appendix B, IEEE 754 bit patterns and their serialization. NaN and Infinity are left
+// out, JSON cannot carry them.
+func TestCanonicalNumberRFC8785(t *testing.T) {
+	tests := []struct {
+		bits string
+		want string
+	}{
+		{"0000000000000000", "0"},
+		{"8000000000000000", "0"},
+		{"0000000000000001", "5e-324"},
+		{"8000000000000001", "-5e-324"},
+		{"7fefffffffffffff", "1.7976931348623157e+308"},
+		{"ffefffffffffffff", "-1.7976931348623157e+308"},
+		{"4340000000000000", "9007199254740992"},
+		{"c340000000000000", "-9007199254740992"},
+		{"4430000000000000", "295147905179352830000"},
+		{"44b52d02c7e14af5", "9.999999999999997e+22"},
+		{"44b52d02c7e14af6", "1e+23"},
+		{"44b52d02c7e14af7", "1.0000000000000001e+23"},
+		{"444b1ae4d6e2ef4e", "999999999999999700000"},
+		{"444b1ae4d6e2ef4f", "999999999999999900000"},
+		{"444b1ae4d6e2ef50", "1e+21"},
+		{"3eb0c6f7a0b5ed8c", "9.999999999999997e-7"},
+		{"3eb0c6f7a0b5ed8d", "0.000001"},
+		{"41b3de4355555553", "333333333.3333332"},
+		{"41b3de4355555554", "333333333.33333325"},
+		{"41b3de4355555555", "333333333.3333333"},
+		{"41b3de4355555556", "333333333.3333334"},
+		{"41b3de4355555557", "333333333.33333343"},
+		{"becbf647612f3696", "-0.0000033333333333333333"},
+		{"43143ff3c1cb0959", "1424953923781206.2"},
+	}
+	for _, tt := range tests {
+		raw, err := hex.DecodeString(tt.bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f := math.Float64frombits(binary.BigEndian.Uint64(raw))
+		if got := canonicalNumber(f); got != tt.want {
+			t.Errorf("canonicalNumber(%s) = %s, want %s", tt.bits, got, tt.want)
+		}
+	}
+}
+
+// RFC 8785 section 3.2.2 and 3.2.3 examples
+func TestCanonicalJSONRFC8785(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			"values",
+			`{"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+			  "string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+			  "literals": [null, true, false]}`,
+			`{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`,
+		},
+		{
+			"member order by UTF-16 code units",
+			`{"\u20ac": "Euro Sign", "\r": "Carriage Return", "\ufb33": "Hebrew Letter Dalet With Dagesh",
+			  "1": "One", "\ud83d\ude00": "Emoji: Grinning Face", "\u0080": "Control",
+			  "\u00f6": "Latin Small Letter O With Diaeresis"}`,
+			"{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\",\"\u00f6\":\"Latin Small Letter O With Diaeresis\"," +
+				"\"\u20ac\":\"Euro Sign\",\"\U0001f600\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}",
+		},
+		{
+			"nesting",
+			`{"b": [{"d": 1, "c": {}}], "a": []}`,
+			`{"a":[],"b":[{"c":{},"d":1}]}`,
+		},
+	}
+	for _, tt := range tests {
+		got, err := canonicalJSON(json.RawMessage(tt.input))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBase58(t *testing.T) {
+	tests := []struct {
+		data    []byte
+		encoded string
+	}{
+		{[]byte{}, ""},
+		{[]byte{0}, "1"},
+		{[]byte{0, 0, 0}, "111"},
+		{[]byte{0, 0, 0x28, 0x7f, 0xb4, 0xcd}, "11233QC4"},
+		{[]byte{0x61}, "2g"},
+		{[]byte("Hello World!"), "2NEpo7TZRRrLZSi2U"},
+		{[]byte("The quick brown fox jumps over the lazy dog."), "USm3fpXnKG5EUBx2ndxBDMPVciP5hGey2Jh4NDv6gmeo1LkMeiKrLJUUBk6Z"},
+	}
+	for _, tt := range tests {
+		if got := base58Encode(tt.data); got != tt.encoded {
+			t.Errorf("base58Encode(%x) = %q, want %q", tt.data, got, tt.encoded)
+		}
+		decoded, err := base58Decode(tt.encoded)
+		if err != nil {
+			t.Errorf("base58Decode(%q): %v", tt.encoded, err)
+			continue
+		}
+		if !bytes.Equal(decoded, tt.data) {
+			t.Errorf("base58Decode(%q) = %x, want %x", tt.encoded, decoded, tt.data)
+		}
+	}
+
+	for _, invalid := range []string{"0", "O", "I", "l", "2g+"} {
+		if _, err := base58Decode(invalid); err == nil {
+			t.Errorf("base58Decode(%q) accepted a character outside the alphabet", invalid)
+		}
+	}
+}
+
+func publicKeyPEM(t *testing.T, key any) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// Key pairs of the test vectors in the W3C Data Integrity EdDSA and ECDSA cryptosuite
+// specifications: the private key seed and the publicKeyMultibase derived from it
+func TestMultikeyVectors(t *testing.T) {
+	edSeed, _ := hex.DecodeString("c96ef9ea10c5e414c471723aff9de72c35fa5b70fae97e8832ecac7d2e2b8ed6")
+	edKey := ed25519.NewKeyFromSeed(edSeed).Public()
+
+	ecScalar, _ := hex.DecodeString("c9afa9d845ba75166b5c215767b1d6934e50c3db36e89b127b8a622b120f6721")
+	ecPrivate, err := ecdh.P256().NewPrivateKey(ecScalar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	point := ecPrivate.PublicKey().Bytes()
+	ecKey := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(point[1:33]), Y: new(big.Int).SetBytes(point[33:])}
+
+	tests := []struct {
+		name string
+		key  any
+		want string
+	}{
+		{"Ed25519", edKey, "z6MkrJVnaZkeFzdQyMZu1cgjg7k1pZZ6pvBQ7XJPt4swbTQ2"},
+		{"P-256", ecKey, "zDnaepBuvsQ8cpsWrVKw8fbpGpvPeNSjVPTWoq6cRqaYzBKVP"},
+	}
+	for _, tt := range tests {
+		got, err := multikey(publicKeyPEM(t, tt.key))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s multikey = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := multikey(publicKeyPEM(t, &p384.PublicKey)); err == nil {
+		t.Error("multikey accepted a P-384 key")
+	}
+}
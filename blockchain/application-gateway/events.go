@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+const (
+	eventReconnectDelay = 5 * time.Second
+	eventHeartbeat      = 30 * time.Second
+	subscriberBuffer    = 64
+)
+
+// LedgerEvent is a chaincode event republished to gateway clients
+type LedgerEvent struct {
+	Name          string          `json:"name"`
+	BlockNumber   uint64          `json:"blockNumber"`
+	TransactionID string          `json:"transactionId"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// eventSubject holds the payload fields shared by credential and issuer events
+type eventSubject struct {
+	CredentialID  string   `json:"credentialId"`
+	CredentialIDs []string `json:"credentialIds"` // Batch events
+	IssuerID      string   `json:"issuerId"`
+	Status        string   `json:"status"`
+}
+
+// RefersTo reports whether the event concerns the given credential
+func (s eventSubject) RefersTo(credentialID string) bool {
+	if s.CredentialID == credentialID {
+		return true
+	}
+	for _, id := range s.CredentialIDs {
+		if id == credentialID {
+			return true
+		}
+	}
+	return false
+}
+
+// Subject decodes the credential and issuer the event refers to
+func (e *LedgerEvent) Subject() eventSubject {
+	var subject eventSubject
+	_ = json.Unmarshal(e.Payload, &subject)
+	return subject
+}
+
+// EventHub fans chaincode events out to gateway subscribers
+type EventHub struct {
+	mu          sync.Mutex
+	subscribers map[chan *LedgerEvent]struct{}
+}
+
+func NewEventHub() *EventHub {
+	return &EventHub{subscribers: make(map[chan *LedgerEvent]struct{})}
+}
+
+// Subscribe registers a new subscriber channel
+func (h *EventHub) Subscribe() chan *LedgerEvent {
+	ch := make(chan *LedgerEvent, subscriberBuffer)
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch
+}
+
+// Unsubscribe removes and closes a subscriber channel
+func (h *EventHub) Unsubscribe(ch chan *LedgerEvent) {
+	h.mu.Lock()
+	if _, ok := h.subscribers[ch]; ok {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+	h.mu.Unlock()
+}
+
+// Publish delivers an event to every subscriber. Subscribers whose buffer is
+// full miss the event instead of stalling the ledger listener.
+func (h *EventHub) Publish(event *LedgerEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- event:
+		default:
+			log.Printf("event subscriber is lagging, dropped %s from tx %s", event.Name, event.TransactionID)
+		}
+	}
+}
+
+// ListenChaincodeEvents republishes chaincode events to the hub until ctx is cancelled.
+// Progress is checkpointed to a file so a restarted gateway resumes where it stopped.
+func (f *FabricService) ListenChaincodeEvents(ctx context.Context, checkpointFile string, hub *EventHub) error {
+	checkpointer, err := client.NewFileCheckpointer(checkpointFile)
+	if err != nil {
+		return err
+	}
+	defer checkpointer.Close()
+
+	for {
+		events, err := f.currentPeer().network.ChaincodeEvents(ctx, f.chaincodeName, client.WithCheckpoint(checkpointer))
+		if err != nil {
+			log.Printf("failed to start chaincode event listener: %v", err)
+		} else {
+			for event := range events {
+				hub.Publish(&LedgerEvent{
+					Name:          event.EventName,
+					BlockNumber:   event.BlockNumber,
+					TransactionID: event.TransactionID,
+					Payload:       event.Payload,
+				})
+				if err := checkpointer.CheckpointChaincodeEvent(event); err != nil {
+					return err
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(eventReconnectDelay):
+		}
+	}
+}
+
+// eventStreamHandler serves GET /events as a Server-Sent Events stream.
+// Optional query parameters: name, issuer and credentialId.
+func eventStreamHandler(hub *EventHub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		nameFilter := c.Query("name")
+		issuerFilter := c.Query("issuer")
+		credentialFilter := c.Query("credentialId")
+
+		ch := hub.Subscribe()
+		defer hub.Unsubscribe(ch)
+
+		heartbeat := time.NewTicker(eventHeartbeat)
+		defer heartbeat.Stop()
+
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Status(http.StatusOK)
+		c.Writer.Flush()
+
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case <-c.Request.Context().Done():
+				return false
+			case <-heartbeat.C:
+				c.SSEvent("ping", time.Now().UTC().Format(time.RFC3339))
+				return true
+			case event, ok := <-ch:
+				if !ok {
+					return false
+				}
+				subject := event.Subject()
+				if nameFilter != "" && event.Name != nameFilter {
+					return true
+				}
+				if issuerFilter != "" && subject.IssuerID != issuerFilter {
+					return true
+				}
+				if credentialFilter != "" && !subject.RefersTo(credentialFilter) {
+					return true
+				}
+				c.SSEvent(event.Name, event)
+				return true
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Issuer proposal states, as set by the chaincode
+var proposalStatuses = []string{"Pending", "Approved", "Rejected", "Withdrawn"}
+
+// LedgerIssuer mirrors the chaincode issuer record
+type LedgerIssuer struct {
+	ID        string `json:"id" binding:"required"`
+	Name      string `json:"name" binding:"required"`
+	MSPID     string `json:"mspId"` // Defaults to the gateway's organization
+	Status    string `json:"status,omitempty"`
+	PublicKey string `json:"publicKey" binding:"required"` // PEM encoded ECDSA P-256, Ed25519 or RSA key
+	// Signing keys with their validity, set by the chaincode
+	Keys []IssuerSigningKey `json:"keys,omitempty"`
+	// Keys of the proofs on exported verifiable credentials, set by the chaincode
+	AssertionKeys []IssuerSigningKey `json:"assertionKeys,omitempty"`
+	// Set by the chaincode once the issuer is revoked
+	Revocation *IssuerRevocation `json:"revocation,omitempty"`
+}
+
+// IssuerProposal mirrors the chaincode proposal to onboard an issuer
+type IssuerProposal struct {
+	ID            string         `json:"id"`
+	Issuer        LedgerIssuer   `json:"issuer"`
+	Status        string         `json:"status"`
+	ProposedBy    string         `json:"proposedBy"`
+	ProposerMSPID string         `json:"proposerMspId"`
+	ProposedAt    string         `json:"proposedAt"`
+	AdminMSPIDs   []string       `json:"adminMspIds"`
+	Threshold     int            `json:"threshold"`
+	Votes         []ProposalVote `json:"votes"`
+	DecidedAt     string         `json:"decidedAt,omitempty"`
+}
+
+// ProposalVote is the vote of one organization
+type ProposalVote struct {
+	AdminID string `json:"adminId"`
+	MSPID   string `json:"mspId"`
+	Approve bool   `json:"approve"`
+	Comment string `json:"comment,omitempty"`
+	VotedAt string `json:"votedAt"`
+	TxID    string `json:"txId"`
+}
+
+// Governance mirrors the chaincode issuer approval settings
+type Governance struct {
+	AdminMSPIDs []string `json:"adminMspIds"`
+	Threshold   int      `json:"threshold"`
+}
+
+// VoteRequest for POST /issuer-proposals/:id/votes
+type VoteRequest struct {
+	Approve *bool  `json:"approve" binding:"required"`
+	Comment string `json:"comment"`
+}
+
+// ProposeIssuer submits a candidate issuer for approval and returns the proposal ID
+func (f *FabricService) ProposeIssuer(issuer *LedgerIssuer) (string, error) {
+	issuerJSON, err := json.Marshal(issuer)
+	if err != nil {
+		return "", err
+	}
+	result, err := f.submitTransaction("ProposeIssuer", string(issuerJSON))
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
+
+// VoteOnIssuerProposal submits the vote of the gateway's organization on a proposal
+func (f *FabricService) VoteOnIssuerProposal(id string, approve bool, comment string) (*IssuerProposal, error) {
+	result, err := f.submitTransaction("VoteOnIssuerProposal", id, strconv.FormatBool(approve), comment)
+	if err != nil {
+		return nil, err
+	}
+	var proposal IssuerProposal
+	if err := json.Unmarshal(result, &proposal); err != nil {
+		return nil, err
+	}
+	return &proposal, nil
+}
+
+// CreateIssuer proposes an issuer and approves it for the gateway's organization in one transaction
+func (f *FabricService) CreateIssuer(issuer *LedgerIssuer) (*IssuerProposal, error) {
+	issuerJSON, err := json.Marshal(issuer)
+	if err != nil {
+		return nil, err
+	}
+	result, err := f.submitTransaction("CreateIssuer", string(issuerJSON))
+	if err != nil {
+		return nil, err
+	}
+	var proposal IssuerProposal
+	if err := json.Unmarshal(result, &proposal); err != nil {
+		return nil, err
+	}
+	return &proposal, nil
+}
+
+// WithdrawIssuerProposal withdraws a pending proposal made through this gateway
+func (f *FabricService) WithdrawIssuerProposal(id string) error {
+	_, err := f.submitTransaction("WithdrawIssuerProposal", id)
+	return err
+}
+
+// ReadIssuerProposal queries a proposal with its votes
+func (f *FabricService) ReadIssuerProposal(id string) (*IssuerProposal, error) {
+	result, err := f.evaluateTransaction("ReadIssuerProposal", id)
+	if err != nil {
+		return nil, err
+	}
+	var proposal IssuerProposal
+	if err := json.Unmarshal(result, &proposal); err != nil {
+		return nil, err
+	}
+	return &proposal, nil
+}
+
+// GetIssuerProposals queries the proposals in a status, or all of them when status is empty
+func (f *FabricService) GetIssuerProposals(status string) ([]*IssuerProposal, error) {
+	result, err := f.evaluateTransaction("GetIssuerProposals", status)
+	if err != nil {
+		return nil, err
+	}
+	var proposals []*IssuerProposal
+	if err := json.Unmarshal(result, &proposals); err != nil {
+		return nil, err
+	}
+	return proposals, nil
+}
+
+// GetGovernance queries which organizations approve issuers and how many approvals are needed
+func (f *FabricService) GetGovernance() (*Governance, error) {
+	result, err := f.evaluateTransaction("GetGovernance")
+	if err != nil {
+		return nil, err
+	}
+	var governance Governance
+	if err := json.Unmarshal(result, &governance); err != nil {
+		return nil, err
+	}
+	return &governance, nil
+}
+
+// registerGovernanceRoutes adds the issuer onboarding routes. Reads are public, proposals and
+// votes are submitted by admin users with the gateway's identity, which votes for its organization.
+func registerGovernanceRoutes(public gin.IRoutes, admin gin.IRoutes, fs *FabricService) {
+	// GET /governance - Organizations voting on issuers and the approvals needed
+	public.GET("/governance", func(c *gin.Context) {
+		governance, err := fs.GetGovernance()
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Governance not found", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, governance)
+	})
+
+	// GET /issuer-proposals?status= - List proposals, optionally only those in one status
+	public.GET("/issuer-proposals", func(c *gin.Context) {
+		status := c.Query("status")
+		if status != "" && !slices.Contains(proposalStatuses, status) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status", "details": fmt.Sprintf("status must be one of %v", proposalStatuses)})
+			return
+		}
+
+		proposals, err := fs.GetIssuerProposals(status)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query proposals", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"proposals": proposals, "count": len(proposals)})
+	})
+
+	// GET /issuer-proposals/:id - Read a proposal with its votes
+	public.GET("/issuer-proposals/:id", func(c *gin.Context) {
+		proposal, err := fs.ReadIssuerProposal(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Proposal not found", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, proposal)
+	})
+
+	// POST /issuer-proposals - Propose a candidate issuer
+	admin.POST("/issuer-proposals", func(c *gin.Context) {
+		var issuer LedgerIssuer
+		if err := c.ShouldBindJSON(&issuer); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+			return
+		}
+		issuer.Status = ""
+		issuer.Keys = nil
+		issuer.AssertionKeys = nil
+
+		id, err := fs.ProposeIssuer(&issuer)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to propose issuer", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"proposalId": id, "issuerId": issuer.ID, "status": "Pending"})
+	})
+
+	// POST /admin/issuers - Propose an issuer and approve it for the gateway's organization,
+	// it is Active right away when one approval is enough
+	admin.POST("/admin/issuers", func(c *gin.Context) {
+		var issuer LedgerIssuer
+		if err := c.ShouldBindJSON(&issuer); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+			return
+		}
+		issuer.Status = ""
+		issuer.Keys = nil
+		issuer.AssertionKeys = nil
+
+		proposal, err := fs.CreateIssuer(&issuer)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to create issuer", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, proposal)
+	})
+
+	// POST /issuer-proposals/:id/votes - Approve or reject a proposal for the gateway's organization
+	admin.POST("/issuer-proposals/:id/votes", func(c *gin.Context) {
+		var req VoteRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+			return
+		}
+
+		proposal, err := fs.VoteOnIssuerProposal(c.Param("id"), *req.Approve, req.Comment)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to vote on proposal", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, proposal)
+	})
+
+	// POST /issuer-proposals/:id/withdraw - Withdraw a pending proposal made through this gateway
+	admin.POST("/issuer-proposals/:id/withdraw", func(c *gin.Context) {
+		id := c.Param("id")
+		if err := fs.WithdrawIssuerProposal(id); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to withdraw proposal", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"proposalId": id, "status": "Withdrawn"})
+	})
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// Contract API system function returning the chaincode metadata, it reads no ledger state
+	metadataTransaction = "org.hyperledger.fabric:GetMetadata"
+	// Deadline of the readiness query
+	readinessTimeout = 5 * time.Second
+)
+
+// Ping evaluates a query on the current peer without retries, so it reports the state of the
+// peer and chaincode as they are now
+func (f *FabricService) Ping(ctx context.Context) error {
+	_, err := f.currentPeer().contract.EvaluateWithContext(ctx, metadataTransaction)
+	return err
+}
+
+// healthzHandler serves GET /healthz, the process is up and serving requests
+func healthzHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// readyzHandler serves GET /readyz. With the Fabric backend the gateway is ready once a
+// chaincode query succeeds.
+func readyzHandler(fs *FabricService, backend string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if fs == nil {
+			c.JSON(http.StatusOK, gin.H{"status": "ready", "ledgerBackend": backend})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+		defer cancel()
+
+		if err := fs.Ping(ctx); err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"status":        "unavailable",
+				"ledgerBackend": backend,
+				"error":         err.Error(),
+				"peers":         fs.PeerStatuses(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"status": "ready", "ledgerBackend": backend, "peers": fs.PeerStatuses()})
+	}
+}
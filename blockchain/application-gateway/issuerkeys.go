@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IssuerSigningKey mirrors a chaincode issuer key with the period it was active
+type IssuerSigningKey struct {
+	ID        string `json:"id"`
+	PublicKey string `json:"publicKey"`
+	Status    string `json:"status"` // "Active", "Retired" or "Compromised"
+	ValidFrom string `json:"validFrom,omitempty"`
+	ValidTo   string `json:"validTo,omitempty"`
+}
+
+// RotateKeyRequest for POST /issuers/:issuerId/keys and POST /issuers/:issuerId/assertion-keys
+type RotateKeyRequest struct {
+	PublicKey   string `json:"publicKey" binding:"required"` // PEM encoded ECDSA P-256, Ed25519 or RSA key
+	Compromised bool   `json:"compromised"`                  // Stop accepting signatures of the replaced key
+}
+
+// ReadIssuer queries an issuer record with its signing keys
+func (f *FabricService) ReadIssuer(id string) (*LedgerIssuer, error) {
+	result, err := f.evaluateTransaction("ReadIssuer", id)
+	if err != nil {
+		return nil, err
+	}
+	var issuer LedgerIssuer
+	if err := json.Unmarshal(result, &issuer); err != nil {
+		return nil, err
+	}
+	return &issuer, nil
+}
+
+// RotateIssuerKey submits a transaction replacing the active signing key of an issuer
+func (f *FabricService) RotateIssuerKey(id string, publicKey string, compromised bool) (*LedgerIssuer, error) {
+	result, err := f.submitTransaction("RotateIssuerKey", id, publicKey, strconv.FormatBool(compromised))
+	if err != nil {
+		return nil, err
+	}
+	var issuer LedgerIssuer
+	if err := json.Unmarshal(result, &issuer); err != nil {
+		return nil, err
+	}
+	return &issuer, nil
+}
+
+// RotateIssuerAssertionKey submits a transaction registering a new key for the proofs of an
+// issuer's exported verifiable credentials
+func (f *FabricService) RotateIssuerAssertionKey(id string, publicKey string, compromised bool) (*LedgerIssuer, error) {
+	result, err := f.submitTransaction("RotateIssuerAssertionKey", id, publicKey, strconv.FormatBool(compromised))
+	if err != nil {
+		return nil, err
+	}
+	var issuer LedgerIssuer
+	if err := json.Unmarshal(result, &issuer); err != nil {
+		return nil, err
+	}
+	return &issuer, nil
+}
+
+// issuerKeys returns the keys of an issuer record, which only carries publicKey before its first rotation
+func issuerKeys(issuer *LedgerIssuer) []IssuerSigningKey {
+	if len(issuer.Keys) > 0 {
+		return issuer.Keys
+	}
+	return []IssuerSigningKey{{ID: keyFingerprint(issuer.PublicKey), PublicKey: issuer.PublicKey, Status: "Active"}}
+}
+
+// registerIssuerKeyRoutes adds the routes listing and rotating issuer signing and assertion keys
+func registerIssuerKeyRoutes(public gin.IRoutes, issuer gin.IRoutes, fs *FabricService) {
+	// GET /issuers/:issuerId/keys - Signing and assertion keys of an issuer, including retired ones
+	public.GET("/issuers/:issuerId/keys", func(c *gin.Context) {
+		ledgerIssuer, err := fs.ReadIssuer(c.Param("issuerId"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Issuer not found", "details": err.Error()})
+			return
+		}
+		keys := issuerKeys(ledgerIssuer)
+		c.JSON(http.StatusOK, gin.H{"issuerId": ledgerIssuer.ID, "keys": keys, "count": len(keys), "assertionKeys": ledgerIssuer.AssertionKeys})
+	})
+
+	// POST /issuers/:issuerId/keys - Rotate the issuer's signing key, only for issuer user sessions
+	issuer.POST("/issuers/:issuerId/keys", func(c *gin.Context) {
+		issuerID := c.Param("issuerId")
+		if c.GetString(contextAPIKeyID) != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API keys cannot rotate signing keys"})
+			return
+		}
+		if !issuerAuthorized(c, issuerID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized for issuer " + issuerID})
+			return
+		}
+
+		var req RotateKeyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+			return
+		}
+
+		ledgerIssuer, err := fs.RotateIssuerKey(issuerID, req.PublicKey, req.Compromised)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to rotate signing key", "details": err.Error()})
+			return
+		}
+
+		keys := issuerKeys(ledgerIssuer)
+		c.JSON(http.StatusOK, gin.H{"issuerId": ledgerIssuer.ID, "activeKey": keys[len(keys)-1], "keys": keys})
+	})
+
+	// POST /issuers/:issuerId/assertion-keys - Register the key signing exported verifiable credentials,
+	// only for issuer user sessions
+	issuer.POST("/issuers/:issuerId/assertion-keys", func(c *gin.Context) {
+		issuerID := c.Param("issuerId")
+		if c.GetString(contextAPIKeyID) != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API keys cannot rotate assertion keys"})
+			return
+		}
+		if !issuerAuthorized(c, issuerID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized for issuer " + issuerID})
+			return
+		}
+
+		var req RotateKeyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+			return
+		}
+
+		ledgerIssuer, err := fs.RotateIssuerAssertionKey(issuerID, req.PublicKey, req.Compromised)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to rotate assertion key", "details": err.Error()})
+			return
+		}
+
+		keys := ledgerIssuer.AssertionKeys
+		c.JSON(http.StatusOK, gin.H{"issuerId": ledgerIssuer.ID, "activeKey": keys[len(keys)-1], "assertionKeys": keys})
+	})
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Credentials the chaincode examines per ContinueIssuerRevocation transaction
+const revocationChunkSize = 50
+
+// Issuers whose credentials are being revoked by this gateway
+var runningRevocations sync.Map
+
+// IssuerRevocation mirrors the chaincode record of an issuer revocation and its progress
+type IssuerRevocation struct {
+	Policy       string `json:"policy"` // "none", "cutoff" or "all"
+	Cutoff       string `json:"cutoff,omitempty"`
+	Note         string `json:"note"`
+	RevokedAt    string `json:"revokedAt"`
+	RevokedBy    string `json:"revokedBy"`
+	RevokerMSPID string `json:"revokerMspId"`
+	TxID         string `json:"txId"`
+	Cursor       string `json:"cursor,omitempty"`
+	Legacy       bool   `json:"legacy,omitempty"`
+	LegacyCursor string `json:"legacyCursor,omitempty"`
+	Examined     int    `json:"examined"`
+	Revoked      int    `json:"revoked"`
+	Complete     bool   `json:"complete"`
+	CompletedAt  string `json:"completedAt,omitempty"`
+}
+
+// IssuerStanding is the state of a credential's issuer, returned with verification results
+type IssuerStanding struct {
+	ID         string            `json:"id"`
+	Status     string            `json:"status"`
+	Revocation *IssuerRevocation `json:"revocation,omitempty"`
+}
+
+// RevokeIssuerRequest for POST /admin/issuers/:issuerId/revoke
+type RevokeIssuerRequest struct {
+	Policy string `json:"policy" binding:"required,oneof=none cutoff all"`
+	Cutoff string `json:"cutoff"` // RFC 3339 time or YYYY-MM-DD date, for the cutoff policy
+	Note   string `json:"note"`
+}
+
+// RevokeIssuer submits a transaction revoking an issuer with a policy for its credentials
+func (f *FabricService) RevokeIssuer(id string, req RevokeIssuerRequest) (*LedgerIssuer, error) {
+	result, err := f.submitTransaction("RevokeIssuer", id, req.Policy, req.Cutoff, req.Note)
+	if err != nil {
+		return nil, err
+	}
+	var issuer LedgerIssuer
+	if err := json.Unmarshal(result, &issuer); err != nil {
+		return nil, err
+	}
+	return &issuer, nil
+}
+
+// ContinueIssuerRevocation submits a transaction revoking the next chunk of a revoked issuer's credentials
+func (f *FabricService) ContinueIssuerRevocation(id string) (*IssuerRevocation, error) {
+	result, err := f.submitTransaction("ContinueIssuerRevocation", id, strconv.Itoa(revocationChunkSize))
+	if err != nil {
+		return nil, err
+	}
+	var revocation IssuerRevocation
+	if err := json.Unmarshal(result, &revocation); err != nil {
+		return nil, err
+	}
+	return &revocation, nil
+}
+
+// GetAllIssuers queries every issuer record
+func (f *FabricService) GetAllIssuers() ([]*LedgerIssuer, error) {
+	result, err := f.evaluateTransaction("GetAllIssuers")
+	if err != nil {
+		return nil, err
+	}
+	var issuers []*LedgerIssuer
+	if err := json.Unmarshal(result, &issuers); err != nil {
+		return nil, err
+	}
+	return issuers, nil
+}
+
+// IssuerStanding reads the state of an issuer, or returns nil when it cannot be read
+func (f *FabricService) IssuerStanding(id string) *IssuerStanding {
+	issuer, err := f.ReadIssuer(id)
+	if err != nil {
+		log.Printf("failed to read issuer %s: %v", id, err)
+		return nil
+	}
+	return &IssuerStanding{ID: issuer.ID, Status: issuer.Status, Revocation: issuer.Revocation}
+}
+
+// RunIssuerRevocation revokes a revoked issuer's credentials chunk by chunk until the chaincode
+// reports the revocation complete. Progress is kept on the ledger, so after an error or a restart
+// it continues where it stopped. It returns at once when a run for the issuer is in progress.
+func (f *FabricService) RunIssuerRevocation(id string) {
+	if _, running := runningRevocations.LoadOrStore(id, struct{}{}); running {
+		return
+	}
+	defer runningRevocations.Delete(id)
+
+	for {
+		revocation, err := f.ContinueIssuerRevocation(id)
+		if err != nil {
+			log.Printf("revoking credentials of issuer %s stopped: %v", id, err)
+			return
+		}
+		if revocation.Complete {
+			log.Printf("revoked %d of %d credentials of issuer %s", revocation.Revoked, revocation.Examined, id)
+			return
+		}
+	}
+}
+
+// ResumeIssuerRevocations continues every issuer revocation whose credentials are not all processed
+func (f *FabricService) ResumeIssuerRevocations() {
+	issuers, err := f.GetAllIssuers()
+	if err != nil {
+		log.Printf("failed to look for unfinished issuer revocations: %v", err)
+		return
+	}
+	for _, issuer := range issuers {
+		if issuer.Revocation != nil && !issuer.Revocation.Complete {
+			go f.RunIssuerRevocation(issuer.ID)
+		}
+	}
+}
+
+// registerIssuerRevocationRoutes adds the admin routes revoking issuers and the public progress route
+func registerIssuerRevocationRoutes(public gin.IRoutes, admin gin.IRoutes, fs *FabricService) {
+	// GET /issuers/:issuerId/revocation - Revocation policy and how far its credentials have been processed
+	public.GET("/issuers/:issuerId/revocation", func(c *gin.Context) {
+		issuer, err := fs.ReadIssuer(c.Param("issuerId"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Issuer not found", "details": err.Error()})
+			return
+		}
+		_, running := runningRevocations.Load(issuer.ID)
+		c.JSON(http.StatusOK, gin.H{"issuerId": issuer.ID, "status": issuer.Status, "revocation": issuer.Revocation, "running": running})
+	})
+
+	// POST /admin/issuers/:issuerId/revoke - Revoke an issuer, its credentials are processed in the background
+	admin.POST("/admin/issuers/:issuerId/revoke", func(c *gin.Context) {
+		var req RevokeIssuerRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+			return
+		}
+
+		issuer, err := fs.RevokeIssuer(c.Param("issuerId"), req)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to revoke issuer", "details": err.Error()})
+			return
+		}
+
+		if !issuer.Revocation.Complete {
+			go fs.RunIssuerRevocation(issuer.ID)
+		}
+		c.JSON(http.StatusAccepted, gin.H{"issuerId": issuer.ID, "status": issuer.Status, "revocation": issuer.Revocation})
+	})
+
+	// POST /admin/issuers/:issuerId/revocation/resume - Restart processing the credentials after an error
+	admin.POST("/admin/issuers/:issuerId/revocation/resume", func(c *gin.Context) {
+		issuer, err := fs.ReadIssuer(c.Param("issuerId"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Issuer not found", "details": err.Error()})
+			return
+		}
+		if issuer.Revocation == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Issuer " + issuer.ID + " is not revoked"})
+			return
+		}
+
+		if !issuer.Revocation.Complete {
+			go fs.RunIssuerRevocation(issuer.ID)
+		}
+		c.JSON(http.StatusAccepted, gin.H{"issuerId": issuer.ID, "status": issuer.Status, "revocation": issuer.Revocation})
+	})
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const metricsNamespace = "diploma_gateway"
+
+// Verification outcomes counted by verifications_total
+const (
+	verificationVerified      = "verified"
+	verificationNotFound      = "not_found"
+	verificationRevoked       = "revoked"
+	verificationSuspended     = "suspended"
+	verificationIssuerRevoked = "issuer_revoked"
+	verificationBadSignature  = "bad_signature"
+	verificationError         = "error"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	fabricCallDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "fabric_call_duration_seconds",
+		Help:      "Duration of chaincode evaluate and submit calls, including retries.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"operation", "transaction", "result"})
+
+	endorsementFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "fabric_endorsement_failures_total",
+		Help:      "Submitted transactions that failed endorsement.",
+	}, []string{"transaction"})
+
+	verifications = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "verifications_total",
+		Help:      "Credential verifications by method and outcome.",
+	}, []string{"method", "outcome"})
+)
+
+// metricsMiddleware counts requests and observes their latency. Routes are labelled with the
+// route pattern, not the request path, to keep the label set bounded.
+func metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		httpRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpRequestDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// observeFabricCall records a chaincode call that started at start, err points to its result
+func observeFabricCall(operation string, transaction string, start time.Time, err *error) {
+	result := "success"
+	if *err != nil {
+		result = "error"
+		var endorseErr *client.EndorseError
+		if errors.As(*err, &endorseErr) {
+			endorsementFailures.WithLabelValues(transaction).Inc()
+		}
+	}
+	fabricCallDuration.WithLabelValues(operation, transaction, result).Observe(time.Since(start).Seconds())
+}
+
+// recordVerification counts a verification that found the credential, by its ledger status and the state of its issuer
+func recordVerification(method string, credential *Credential, issuer *IssuerStanding) {
+	switch {
+	case credential.Status == "Revoked":
+		verifications.WithLabelValues(method, verificationRevoked).Inc()
+	case credential.Status == "Suspended":
+		verifications.WithLabelValues(method, verificationSuspended).Inc()
+	case issuer != nil && issuer.Status == "Revoked":
+		verifications.WithLabelValues(method, verificationIssuerRevoked).Inc()
+	default:
+		verifications.WithLabelValues(method, verificationVerified).Inc()
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-gateway/pkg/hash"
+	"github.com/hyperledger/fabric-gateway/pkg/identity"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// Deadlines the gateway client applies to every call
+const (
+	evaluateTimeout = 10 * time.Second
+	endorseTimeout  = 15 * time.Second
+	submitTimeout   = 15 * time.Second
+)
+
+const (
+	// Attempts per call, each retry goes to the next peer
+	maxCallAttempts = 3
+	// Delay before a retry, multiplied by the attempt number
+	retryBackoff = 500 * time.Millisecond
+	// How often peer connections are probed
+	peerHealthInterval = 10 * time.Second
+)
+
+// peerConnection is a gRPC connection to one gateway peer with its own gateway client
+type peerConnection struct {
+	endpoint string
+	conn     *grpc.ClientConn
+	gw       *client.Gateway
+	network  *client.Network
+	contract *client.Contract
+}
+
+// PeerStatus is the connection state of a gateway peer
+type PeerStatus struct {
+	Endpoint string `json:"endpoint"`
+	State    string `json:"state"`
+	Active   bool   `json:"active"`
+}
+
+// dialPeer creates the connection to a peer. The connection is established in the background
+// and re-established by gRPC whenever it drops.
+func dialPeer(peer PeerConfig, cfg *Config, id identity.Identity, sign identity.Sign) (*peerConnection, error) {
+	certificatePEM, err := os.ReadFile(peer.TLSCertPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TLS certificate of %s: %v", peer.Endpoint, err)
+	}
+
+	certPool := x509.NewCertPool()
+	if ok := certPool.AppendCertsFromPEM(certificatePEM); !ok {
+		return nil, fmt.Errorf("no certificates found in %s", peer.TLSCertPath)
+	}
+
+	tlsCreds := credentials.NewClientTLSFromCert(certPool, peer.GatewayPeer)
+	conn, err := grpc.NewClient(peer.Endpoint, grpc.WithTransportCredentials(tlsCreds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connection to %s: %v", peer.Endpoint, err)
+	}
+	conn.Connect()
+
+	gw, err := client.Connect(id,
+		client.WithSign(sign),
+		client.WithClientConnection(conn),
+		client.WithHash(hash.SHA256),
+		client.WithEvaluateTimeout(evaluateTimeout),
+		client.WithEndorseTimeout(endorseTimeout),
+		client.WithSubmitTimeout(submitTimeout),
+		client.WithCommitStatusTimeout(commitStatusTimeout),
+	)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	network := gw.GetNetwork(cfg.ChannelName)
+	return &peerConnection{
+		endpoint: peer.Endpoint,
+		conn:     conn,
+		gw:       gw,
+		network:  network,
+		contract: network.GetContract(cfg.ChaincodeName),
+	}, nil
+}
+
+// healthy reports whether the connection is usable or may become usable without waiting for a backoff
+func (p *peerConnection) healthy() bool {
+	switch p.conn.GetState() {
+	case connectivity.TransientFailure, connectivity.Shutdown:
+		return false
+	default:
+		return true
+	}
+}
+
+// currentPeer returns the peer calls are sent to
+func (f *FabricService) currentPeer() *peerConnection {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.peers[f.active]
+}
+
+// failover moves calls away from a failed peer to the next healthy one. When no other peer is
+// healthy it still moves on, so retries do not keep hitting the same peer.
+func (f *FabricService) failover(failed *peerConnection) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.peers) < 2 || f.peers[f.active] != failed {
+		return
+	}
+
+	next := (f.active + 1) % len(f.peers)
+	for i := 1; i < len(f.peers); i++ {
+		candidate := (f.active + i) % len(f.peers)
+		if f.peers[candidate].healthy() {
+			next = candidate
+			break
+		}
+	}
+
+	log.Printf("failing over from peer %s to %s", failed.endpoint, f.peers[next].endpoint)
+	f.active = next
+}
+
+// MonitorPeers probes the peer connections until ctx is cancelled. Idle connections are woken up,
+// calls leave an unhealthy peer and return to the first configured peer once it is ready again.
+func (f *FabricService) MonitorPeers(ctx context.Context) {
+	ticker := time.NewTicker(peerHealthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, peer := range f.peers {
+			if peer.conn.GetState() == connectivity.Idle {
+				peer.conn.Connect()
+			}
+		}
+
+		current := f.currentPeer()
+		if !current.healthy() {
+			f.failover(current)
+			continue
+		}
+
+		f.mu.Lock()
+		if f.active != 0 && f.peers[0].conn.GetState() == connectivity.Ready {
+			log.Printf("peer %s recovered, moving back from %s", f.peers[0].endpoint, f.peers[f.active].endpoint)
+			f.active = 0
+		}
+		f.mu.Unlock()
+	}
+}
+
+// PeerStatuses returns the connection state of every configured peer
+func (f *FabricService) PeerStatuses() []PeerStatus {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	statuses := make([]PeerStatus, 0, len(f.peers))
+	for i, peer := range f.peers {
+		statuses = append(statuses, PeerStatus{
+			Endpoint: peer.endpoint,
+			State:    peer.conn.GetState().String(),
+			Active:   i == f.active,
+		})
+	}
+	return statuses
+}
+
+// withRetry runs call against the current peer, failing over and retrying while the error is retryable
+func (f *FabricService) withRetry(retryable func(error) bool, call func(peer *peerConnection) error) error {
+	for attempt := 1; ; attempt++ {
+		peer := f.currentPeer()
+		err := call(peer)
+		if err == nil || !retryable(err) || attempt == maxCallAttempts {
+			return err
+		}
+
+		log.Printf("call to peer %s failed, retrying: %v", peer.endpoint, err)
+		f.failover(peer)
+		time.Sleep(time.Duration(attempt) * retryBackoff)
+	}
+}
+
+// evaluate runs a query transaction
+func (f *FabricService) evaluate(name string, options ...client.ProposalOption) (result []byte, err error) {
+	defer observeFabricCall("evaluate", name, time.Now(), &err)
+	err = f.withRetry(isTransientError, func(peer *peerConnection) (err error) {
+		result, err = peer.contract.Evaluate(name, options...)
+		return err
+	})
+	return result, err
+}
+
+// submit endorses and submits a transaction and waits for it to commit
+func (f *FabricService) submit(name string, options ...client.ProposalOption) (result []byte, err error) {
+	defer observeFabricCall("submit", name, time.Now(), &err)
+	err = f.withRetry(isRetryableSubmitError, func(peer *peerConnection) (err error) {
+		result, err = peer.contract.Submit(name, options...)
+		return err
+	})
+	return result, err
+}
+
+// submitAsync endorses and submits a transaction without waiting for it to commit
+func (f *FabricService) submitAsync(name string, options ...client.ProposalOption) (result []byte, commit *client.Commit, err error) {
+	defer observeFabricCall("submit", name, time.Now(), &err)
+	err = f.withRetry(isRetryableSubmitError, func(peer *peerConnection) (err error) {
+		result, commit, err = peer.contract.SubmitAsync(name, options...)
+		return err
+	})
+	return result, commit, err
+}
+
+func (f *FabricService) evaluateTransaction(name string, args ...string) ([]byte, error) {
+	return f.evaluate(name, client.WithArguments(args...))
+}
+
+func (f *FabricService) submitTransaction(name string, args ...string) ([]byte, error) {
+	return f.submit(name, client.WithArguments(args...))
+}
+
+// isTransientError reports errors caused by an unreachable or overloaded peer rather than the chaincode
+func isTransientError(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted:
+		return true
+	default:
+		return false
+	}
+}
+
+// isRetryableSubmitError only retries failed endorsements. Once a transaction has been sent to the
+// orderer it may commit, and a retry would submit it a second time.
+func isRetryableSubmitError(err error) bool {
+	var endorseErr *client.EndorseError
+	return errors.As(err, &endorseErr) && isTransientError(err)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// Transient map key the chaincode reads graduate details from
+const graduateDetailsTransientKey = "graduateDetails"
+
+const graduateDetailsSaltBytes = 16
+
+// GraduateDetails mirrors the chaincode private data record. Field order must match
+// the chaincode struct, the salted hash is computed over its JSON encoding.
+type GraduateDetails struct {
+	FullName     string        `json:"fullName" binding:"required"`
+	PersonalCode string        `json:"personalCode" binding:"required"`
+	ThesisTitle  string        `json:"thesisTitle"`
+	Grades       []CourseGrade `json:"grades"`
+	Salt         string        `json:"salt,omitempty"`
+}
+
+type CourseGrade struct {
+	Course  string  `json:"course"`
+	Grade   string  `json:"grade"`
+	Credits float64 `json:"credits"`
+}
+
+// SaltGraduateDetails sets a fresh random salt and returns the hash to record on the public credential
+func SaltGraduateDetails(details *GraduateDetails) (string, error) {
+	salt, err := randomHex(graduateDetailsSaltBytes)
+	if err != nil {
+		return "", err
+	}
+	details.Salt = salt
+	return HashGraduateDetails(details)
+}
+
+// HashGraduateDetails returns hex(SHA-256(salt || JSON of details without salt)), same as the chaincode
+func HashGraduateDetails(details *GraduateDetails) (string, error) {
+	salt, err := hex.DecodeString(details.Salt)
+	if err != nil {
+		return "", err
+	}
+
+	unsalted := *details
+	unsalted.Salt = ""
+	detailsJSON, err := json.Marshal(unsalted)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	h.Write(salt)
+	h.Write(detailsJSON)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// PutGraduateDetails stores graduate details in the private data collection of the credential's issuer.
+// The details travel in the transient map and only the issuer's organization endorses: its peers
+// are the ones holding the collection, and the details are not sent to peers outside it.
+func (f *FabricService) PutGraduateDetails(id string, issuerID string, details *GraduateDetails) error {
+	issuer, err := f.ReadIssuer(issuerID)
+	if err != nil {
+		return err
+	}
+	mspID := issuer.MSPID
+	if mspID == "" {
+		mspID = f.mspID
+	}
+
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	_, err = f.submit("PutGraduateDetails",
+		client.WithArguments(id),
+		client.WithTransient(map[string][]byte{graduateDetailsTransientKey: detailsJSON}),
+		client.WithEndorsingOrganizations(mspID),
+	)
+	return err
+}
+
+// ReadGraduateDetails reads graduate details from the issuer's private data collection
+func (f *FabricService) ReadGraduateDetails(id string) (*GraduateDetails, error) {
+	result, err := f.evaluateTransaction("ReadGraduateDetails", id)
+	if err != nil {
+		return nil, err
+	}
+	var details GraduateDetails
+	if err := json.Unmarshal(result, &details); err != nil {
+		return nil, err
+	}
+	return &details, nil
+}
+
+// registerPrivateDetailsRoutes adds the routes reading and replacing graduate details
+func registerPrivateDetailsRoutes(router gin.IRoutes, fs *FabricService) {
+	// GET /credential/:id/private - Graduate details, only for the issuing university
+	router.GET("/credential/:id/private", requireScope(scopeList), func(c *gin.Context) {
+		id := c.Param("id")
+		if authorizeCredentialIssuer(c, fs, id) == nil {
+			return
+		}
+
+		details, err := fs.ReadGraduateDetails(id)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Graduate details not found", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, details)
+	})
+
+	// PUT /credential/:id/private - Replace graduate details and the hash committing to them
+	router.PUT("/credential/:id/private", requireScope(scopeIssue), func(c *gin.Context) {
+		id := c.Param("id")
+		credential := authorizeCredentialIssuer(c, fs, id)
+		if credential == nil {
+			return
+		}
+
+		var details GraduateDetails
+		if err := c.ShouldBindJSON(&details); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+			return
+		}
+
+		detailsHash, err := SaltGraduateDetails(&details)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash graduate details", "details": err.Error()})
+			return
+		}
+
+		// The public hash is updated first, the private write is only accepted when it matches
+		credential.ID = strings.TrimPrefix(credential.ID, credentialKeyPrefix)
+		credential.GraduateDetailsHash = detailsHash
+		if err := fs.UpdateCredential(credential); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to update credential", "details": err.Error()})
+			return
+		}
+
+		if err := fs.PutGraduateDetails(id, credential.IssuerID, &details); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store graduate details", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"credentialId": id, "graduateDetailsHash": detailsHash})
+	})
+}
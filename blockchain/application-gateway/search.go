@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// searchFields maps the field names of the search DSL to credential JSON paths
+var searchFields = map[string]string{
+	"issuerId":       "issuerId",
+	"status":         "status",
+	"credentialType": "credentialType",
+	"diplomaHash":    "diplomaHash",
+	"university":     "diplomaMetadata.universityName",
+	"degreeName":     "diplomaMetadata.degreeName",
+	"issueDate":      "diplomaMetadata.issueDate",
+	"expiryDate":     "diplomaMetadata.expiryDate",
+}
+
+// searchOperators maps the operators of the search DSL to Mango operators
+var searchOperators = map[string]string{
+	"eq":  "$eq",
+	"ne":  "$ne",
+	"gt":  "$gt",
+	"gte": "$gte",
+	"lt":  "$lt",
+	"lte": "$lte",
+	"in":  "$in",
+}
+
+const maxSearchConditions = 10
+
+// SearchCondition is a single comparison, e.g. {"field": "degreeName", "op": "eq", "value": "..."}
+type SearchCondition struct {
+	Field string      `json:"field" binding:"required"`
+	Op    string      `json:"op"` // Defaults to eq
+	Value interface{} `json:"value" binding:"required"`
+}
+
+// SearchCredentialsRequest for POST /credentials/search. Conditions are combined with AND.
+type SearchCredentialsRequest struct {
+	Conditions []SearchCondition `json:"conditions" binding:"required,min=1,dive"`
+	PageSize   int32             `json:"pageSize"`
+	Bookmark   string            `json:"bookmark"`
+}
+
+// ToSelector translates the request into a Mango selector the chaincode accepts. A non-empty
+// issuerID adds a clause restricting the results to that issuer's credentials.
+func (r *SearchCredentialsRequest) ToSelector(issuerID string) (map[string]interface{}, error) {
+	if len(r.Conditions) > maxSearchConditions {
+		return nil, fmt.Errorf("at most %d conditions are allowed", maxSearchConditions)
+	}
+
+	clauses := make([]interface{}, 0, len(r.Conditions))
+	for _, condition := range r.Conditions {
+		path, ok := searchFields[condition.Field]
+		if !ok {
+			return nil, fmt.Errorf("field %q is not searchable", condition.Field)
+		}
+
+		op := condition.Op
+		if op == "" {
+			op = "eq"
+		}
+		operator, ok := searchOperators[op]
+		if !ok {
+			return nil, fmt.Errorf("operator %q is not supported", condition.Op)
+		}
+
+		value, err := searchValue(op, condition.Value)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %v", condition.Field, err)
+		}
+
+		clauses = append(clauses, map[string]interface{}{
+			path: map[string]interface{}{operator: value},
+		})
+	}
+
+	if issuerID != "" {
+		clauses = append(clauses, map[string]interface{}{
+			searchFields["issuerId"]: map[string]interface{}{"$eq": issuerID},
+		})
+	}
+
+	return map[string]interface{}{"$and": clauses}, nil
+}
+
+// searchValue accepts strings, and non-empty string arrays for the in operator
+func searchValue(op string, value interface{}) (interface{}, error) {
+	if op == "in" {
+		items, ok := value.([]interface{})
+		if !ok || len(items) == 0 {
+			return nil, fmt.Errorf("in expects a non-empty array of strings")
+		}
+		for _, item := range items {
+			if _, ok := item.(string); !ok {
+				return nil, fmt.Errorf("in expects a non-empty array of strings")
+			}
+		}
+		return items, nil
+	}
+
+	str, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("%s expects a string", op)
+	}
+	return str, nil
+}
+
+// SearchCredentials runs a validated Mango selector through the chaincode rich query
+func (f *FabricService) SearchCredentials(selector map[string]interface{}, pageSize int32, bookmark string) (*CredentialPage, error) {
+	selectorJSON, err := json.Marshal(selector)
+	if err != nil {
+		return nil, err
+	}
+	result, err := f.evaluateTransaction("QueryCredentials", string(selectorJSON), strconv.FormatInt(int64(pageSize), 10), bookmark)
+	if err != nil {
+		return nil, err
+	}
+	var page CredentialPage
+	if err := json.Unmarshal(result, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// searchCredentialsHandler serves POST /credentials/search after requireIssuerOrAdmin. Issuer
+// callers only find their own credentials, admins search every issuer.
+func searchCredentialsHandler(fs *FabricService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SearchCredentialsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+			return
+		}
+
+		issuerID := ""
+		if !authenticatedAdmin(c) {
+			issuerID = authenticatedIssuer(c)
+		}
+
+		selector, err := req.ToSelector(issuerID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid search", "details": err.Error()})
+			return
+		}
+
+		page, err := fs.SearchCredentials(selector, req.PageSize, req.Bookmark)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search credentials", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"credentials": page.Credentials,
+			"count":       page.Count,
+			"bookmark":    page.Bookmark,
+		})
+	}
+}
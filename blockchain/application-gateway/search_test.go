@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSearchSelectorRestrictsIssuer(t *testing.T) {
+	req := SearchCredentialsRequest{Conditions: []SearchCondition{
+		{Field: "issuerId", Value: "rtu"},
+		{Field: "issueDate", Op: "gte", Value: "2024-01-01"},
+	}}
+
+	tests := []struct {
+		issuerID string
+		want     string
+	}{
+		{"", `{"$and":[{"issuerId":{"$eq":"rtu"}},{"diplomaMetadata.issueDate":{"$gte":"2024-01-01"}}]}`},
+		{"lu", `{"$and":[{"issuerId":{"$eq":"rtu"}},{"diplomaMetadata.issueDate":{"$gte":"2024-01-01"}},{"issuerId":{"$eq":"lu"}}]}`},
+	}
+	for _, tt := range tests {
+		selector, err := req.ToSelector(tt.issuerID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := json.Marshal(selector)
+		if string(got) != tt.want {
+			t.Errorf("ToSelector(%q) = %s, want %s", tt.issuerID, got, tt.want)
+		}
+	}
+}
+
+func TestSearchSelectorRejectsUnknownFieldsAndOperators(t *testing.T) {
+	for _, condition := range []SearchCondition{
+		{Field: "graduateDetailsHash", Value: "x"},
+		{Field: "status", Op: "regex", Value: "Val.*"},
+		{Field: "status", Op: "in", Value: []interface{}{}},
+		{Field: "status", Value: map[string]interface{}{"$ne": ""}},
+	} {
+		req := SearchCredentialsRequest{Conditions: []SearchCondition{condition}}
+		if _, err := req.ToSelector("lu"); err == nil {
+			t.Errorf("condition %+v was accepted", condition)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// Ledger backends selectable with Config.LedgerBackend
+const (
+	ledgerBackendFabric = "fabric"
+	ledgerBackendMemory = "memory"
+)
+
+// Identity recorded on revocations made by the in-memory backend
+const memoryStoreIdentity = "memory-store"
+
+// CredentialStore is the ledger backend the credential routes run against
+type CredentialStore interface {
+	ReadCredential(id string) (*Credential, error)
+	GetAllCredentials() ([]*Credential, error)
+	// CreateCredential returns the ID the ledger assigned
+	CreateCredential(cred *Credential) (string, error)
+	RevokeCredential(id string, reason string, note string) error
+}
+
+// asyncCredentialCreator is implemented by backends that can return before a credential is committed
+type asyncCredentialCreator interface {
+	CreateCredentialAsync(cred *Credential) (string, *client.Commit, error)
+}
+
+var _ CredentialStore = (*FabricService)(nil)
+var _ CredentialStore = (*MemoryCredentialStore)(nil)
+
+// NewCredentialStore opens the configured backend. The FabricService is nil for backends other than
+// Fabric, routes that need chaincode features beyond CredentialStore are unavailable then.
+func NewCredentialStore(cfg *Config) (CredentialStore, *FabricService, error) {
+	switch cfg.LedgerBackend {
+	case ledgerBackendFabric:
+		fs, err := ConnectGateway(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		return fs, fs, nil
+	case ledgerBackendMemory:
+		return NewMemoryCredentialStore(issuers, cfg.MSPID), nil, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown ledger backend %q, expected %s or %s", cfg.LedgerBackend, ledgerBackendFabric, ledgerBackendMemory)
+	}
+}
+
+// requireFabric rejects requests to routes that need the Fabric backend when another backend is selected
+func requireFabric(fs *FabricService, backend string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if fs == nil {
+			c.AbortWithStatusJSON(http.StatusNotImplemented, gin.H{"error": "Not available with the " + backend + " ledger backend"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Matches mirrors the chaincode filter for backends without chaincode queries
+func (f *CredentialFilter) Matches(credential *Credential) bool {
+	if f.IssuerID != "" && credential.IssuerID != f.IssuerID {
+		return false
+	}
+	if f.Status != "" && credential.Status != f.Status {
+		return false
+	}
+	if f.CredentialType != "" && credential.CredentialType != f.CredentialType {
+		return false
+	}
+	// ISO dates compare correctly as strings
+	if f.IssuedFrom != "" && credential.DiplomaMetadata.IssueDate < f.IssuedFrom {
+		return false
+	}
+	if f.IssuedTo != "" && credential.DiplomaMetadata.IssueDate > f.IssuedTo {
+		return false
+	}
+	return true
+}
+
+// NewCredentialID mirrors the chaincode ID derivation: issuer ID, a dash and the full SHA-256 of
+// issuer ID, diploma hash and graduate key separated by zero bytes
+func NewCredentialID(issuerID string, diplomaHash string, graduatePublicKey string) string {
+	h := sha256.New()
+	h.Write([]byte(issuerID))
+	h.Write([]byte{0})
+	h.Write([]byte(diplomaHash))
+	h.Write([]byte{0})
+	h.Write([]byte(graduatePublicKey))
+	return issuerID + "-" + hex.EncodeToString(h.Sum(nil))
+}
+
+// validateNewCredential applies the chaincode rules for the ID, status and hash scheme of a
+// credential about to be created and returns the ID the ledger assigns it
+func validateNewCredential(cred *Credential) (string, error) {
+	id := NewCredentialID(cred.IssuerID, cred.DiplomaHash, cred.GraduatePublicKey)
+	if cred.ID != "" && cred.ID != id {
+		return "", fmt.Errorf("credential id must be empty or %s", id)
+	}
+
+	if cred.Status != "" && cred.Status != "Valid" {
+		return "", fmt.Errorf("new credentials must have status Valid, got %s", cred.Status)
+	}
+
+	switch cred.HashScheme {
+	case "", hashSchemeSHA256:
+	case hashSchemeHMACSHA256:
+		digest, err := hex.DecodeString(cred.DiplomaHash)
+		if err != nil || len(digest) != sha256.Size {
+			return "", fmt.Errorf("diplomaHash must be a hex encoded HMAC-SHA256 commitment")
+		}
+	default:
+		return "", fmt.Errorf("unknown hash scheme %q, expected %s or %s", cred.HashScheme, hashSchemeSHA256, hashSchemeHMACSHA256)
+	}
+
+	return id, nil
+}
+
+// MemoryCredentialStore keeps credentials in process memory, for development and tests without a
+// Fabric network. It applies the chaincode rules for IDs, active issuers, duplicates, hash schemes
+// and revocation. Issuer signatures are not verified since no issuer keys are available off-ledger.
+type MemoryCredentialStore struct {
+	mu          sync.RWMutex
+	mspID       string // Recorded as the revoking organization
+	issuers     map[string]Issuer
+	credentials map[string]*Credential
+}
+
+func NewMemoryCredentialStore(knownIssuers []Issuer, mspID string) *MemoryCredentialStore {
+	store := &MemoryCredentialStore{
+		mspID:       mspID,
+		issuers:     make(map[string]Issuer),
+		credentials: make(map[string]*Credential),
+	}
+	for _, issuer := range knownIssuers {
+		store.issuers[issuer.ID] = issuer
+	}
+	return store
+}
+
+func (m *MemoryCredentialStore) ReadCredential(id string) (*Credential, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	credential, ok := m.credentials[id]
+	if !ok {
+		return nil, fmt.Errorf("the credential %s does not exist", id)
+	}
+	return copyCredential(credential), nil
+}
+
+// GetAllCredentials returns every credential ordered by ID, like a ledger range query
+func (m *MemoryCredentialStore) GetAllCredentials() ([]*Credential, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ids := make([]string, 0, len(m.credentials))
+	for id := range m.credentials {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	credentials := make([]*Credential, 0, len(ids))
+	for _, id := range ids {
+		credentials = append(credentials, copyCredential(m.credentials[id]))
+	}
+	return credentials, nil
+}
+
+func (m *MemoryCredentialStore) CreateCredential(cred *Credential) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	issuer, ok := m.issuers[cred.IssuerID]
+	if !ok {
+		return "", fmt.Errorf("the issuer %s does not exist", cred.IssuerID)
+	}
+	if issuer.Status != "" && issuer.Status != "Active" {
+		return "", fmt.Errorf("issuer %s is not active", cred.IssuerID)
+	}
+
+	id, err := validateNewCredential(cred)
+	if err != nil {
+		return "", err
+	}
+
+	if _, exists := m.credentials[id]; exists {
+		return "", fmt.Errorf("the credential %s already exists", credentialKeyPrefix+id)
+	}
+
+	stored := copyCredential(cred)
+	stored.ID = credentialKeyPrefix + id
+	stored.Status = "Valid"
+	stored.Revocation = nil
+	stored.Suspension = nil
+	stored.LegacyID = ""
+	stored.SignedAt = time.Now().UTC().Format(time.RFC3339)
+	m.credentials[id] = stored
+
+	return id, nil
+}
+
+func (m *MemoryCredentialStore) RevokeCredential(id string, reason string, note string) error {
+	reason = strings.ToLower(strings.TrimSpace(reason))
+	switch reason {
+	case "fraud", "error", "superseded", "withdrawn":
+	case "":
+		return fmt.Errorf("revocation reason is required")
+	default:
+		return fmt.Errorf("unknown revocation reason %q, expected one of fraud, error, superseded, withdrawn", reason)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	credential, ok := m.credentials[id]
+	if !ok {
+		return fmt.Errorf("the credential %s does not exist", id)
+	}
+	if credential.Status != "Valid" && credential.Status != "Suspended" {
+		return fmt.Errorf("credential cannot move from %s to Revoked", credential.Status)
+	}
+
+	txID, err := randomHex(32)
+	if err != nil {
+		return err
+	}
+
+	credential.Status = "Revoked"
+	credential.Revocation = &Revocation{
+		Reason:       reason,
+		Note:         note,
+		RevokedAt:    time.Now().UTC().Format(time.RFC3339),
+		RevokedBy:    memoryStoreIdentity,
+		RevokerMSPID: m.mspID,
+		TxID:         txID,
+	}
+	return nil
+}
+
+// copyCredential returns a copy that does not share the revocation and suspension records
+func copyCredential(credential *Credential) *Credential {
+	clone := *credential
+	if credential.Revocation != nil {
+		revocation := *credential.Revocation
+		clone.Revocation = &revocation
+	}
+	if credential.Suspension != nil {
+		suspension := *credential.Suspension
+		clone.Suspension = &suspension
+	}
+	return &clone
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// Commit states reported by GET /transactions/:txId
+const (
+	txStatusPending   = "pending"
+	txStatusCommitted = "committed"
+	txStatusFailed    = "failed"
+)
+
+const (
+	// How long the background wait for a commit status may take
+	commitStatusTimeout = 2 * time.Minute
+	// Finished transactions are forgotten after this long
+	transactionRetention = time.Hour
+)
+
+// TransactionStatus is the commit state of a transaction submitted asynchronously
+type TransactionStatus struct {
+	TxID           string     `json:"txId"`
+	Transaction    string     `json:"transaction"` // Chaincode function name
+	Status         string     `json:"status"`
+	ValidationCode string     `json:"validationCode,omitempty"` // Peer validation code once known, e.g. VALID or MVCC_READ_CONFLICT
+	BlockNumber    uint64     `json:"blockNumber,omitempty"`
+	Result         string     `json:"result,omitempty"` // Endorsed chaincode result
+	Error          string     `json:"error,omitempty"`
+	SubmittedAt    time.Time  `json:"submittedAt"`
+	CompletedAt    *time.Time `json:"completedAt,omitempty"`
+	// Work left to do after the commit, reported as the error when the gateway restarts before it ran
+	FollowUp string `json:"followUp,omitempty"`
+}
+
+// TransactionTracker keeps the commit state of asynchronously submitted transactions, persisted to a
+// JSON file so that a restart does not lose them
+type TransactionTracker struct {
+	mu           sync.Mutex
+	path         string
+	transactions map[string]*TransactionStatus
+}
+
+// LoadTransactionTracker opens the tracker at path. A missing file yields an empty tracker, an empty
+// path one kept in memory only. Transactions still pending were being awaited by the previous process,
+// nothing waits for them or runs their follow-up any more, so they are marked failed.
+func LoadTransactionTracker(path string) (*TransactionTracker, error) {
+	tracker := &TransactionTracker{
+		path:         path,
+		transactions: make(map[string]*TransactionStatus),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return tracker, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []*TransactionStatus
+	if err := json.Unmarshal(data, &statuses); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	for _, status := range statuses {
+		if status.Status == txStatusPending {
+			status.Status = txStatusFailed
+			status.Error = "gateway restarted before the commit status was known"
+			if status.FollowUp != "" {
+				status.Error += ", " + status.FollowUp
+			}
+			status.CompletedAt = &now
+		}
+		tracker.transactions[status.TxID] = status
+	}
+
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	if err := tracker.save(); err != nil {
+		return nil, err
+	}
+	return tracker, nil
+}
+
+// save writes the tracker to disk. Callers must hold the lock.
+func (t *TransactionTracker) save() error {
+	if t.path == "" {
+		return nil
+	}
+
+	statuses := make([]*TransactionStatus, 0, len(t.transactions))
+	for _, status := range t.transactions {
+		statuses = append(statuses, status)
+	}
+
+	data, err := json.MarshalIndent(statuses, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.path)
+}
+
+// Track records a submitted transaction as pending and waits for its commit status in the background.
+// onCommit, if set, runs after a successful commit and its error is recorded on the status. followUp
+// tells the client what to do instead when onCommit never ran.
+func (t *TransactionTracker) Track(name string, result []byte, commit *client.Commit, followUp string, onCommit func() error) *TransactionStatus {
+	status := &TransactionStatus{
+		TxID:        commit.TransactionID(),
+		Transaction: name,
+		Status:      txStatusPending,
+		Result:      string(result),
+		SubmittedAt: time.Now().UTC(),
+	}
+	if onCommit != nil {
+		status.FollowUp = followUp
+	}
+
+	t.mu.Lock()
+	t.prune()
+	t.transactions[status.TxID] = status
+	if err := t.save(); err != nil {
+		// The commit is still awaited, only a restart would lose it
+		log.Printf("failed to save transaction %s: %v", status.TxID, err)
+	}
+	snapshot := *status
+	t.mu.Unlock()
+
+	go t.await(status.TxID, commit, onCommit)
+
+	return &snapshot
+}
+
+// Get returns a copy of the status of a tracked transaction
+func (t *TransactionTracker) Get(txID string) (*TransactionStatus, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status, ok := t.transactions[txID]
+	if !ok {
+		return nil, false
+	}
+	snapshot := *status
+	return &snapshot, true
+}
+
+func (t *TransactionTracker) await(txID string, commit *client.Commit, onCommit func() error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commitStatusTimeout)
+	defer cancel()
+
+	commitStatus, err := commit.StatusWithContext(ctx)
+
+	var followUpErr error
+	if err == nil && commitStatus.Successful && onCommit != nil {
+		followUpErr = onCommit()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status, ok := t.transactions[txID]
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	status.CompletedAt = &now
+
+	switch {
+	case err != nil:
+		// The transaction may still commit, but this gateway can no longer tell
+		status.Status = txStatusFailed
+		status.Error = "failed to obtain commit status: " + err.Error()
+	case !commitStatus.Successful:
+		status.Status = txStatusFailed
+		status.ValidationCode = commitStatus.Code.String()
+		status.BlockNumber = commitStatus.BlockNumber
+		status.Error = "transaction was not committed"
+	default:
+		status.Status = txStatusCommitted
+		status.ValidationCode = commitStatus.Code.String()
+		status.BlockNumber = commitStatus.BlockNumber
+		if followUpErr != nil {
+			status.Error = followUpErr.Error()
+		}
+	}
+
+	if err := t.save(); err != nil {
+		log.Printf("failed to save transaction %s: %v", txID, err)
+	}
+}
+
+// prune drops finished transactions older than transactionRetention, the caller holds the lock
+func (t *TransactionTracker) prune() {
+	cutoff := time.Now().Add(-transactionRetention)
+	for txID, status := range t.transactions {
+		if status.CompletedAt != nil && status.CompletedAt.Before(cutoff) {
+			delete(t.transactions, txID)
+		}
+	}
+}
+
+// CreateCredentialAsync endorses and submits CreateCredential without waiting for the commit.
+// It returns the credential ID from the endorsement and the pending commit.
+func (f *FabricService) CreateCredentialAsync(cred *Credential) (string, *client.Commit, error) {
+	credJSON, err := json.Marshal(cred)
+	if err != nil {
+		return "", nil, err
+	}
+	result, commit, err := f.submitAsync("CreateCredential", client.WithArguments(string(credJSON)))
+	if err != nil {
+		return "", nil, err
+	}
+	return string(result), commit, nil
+}
+
+// transactionStatusHandler serves GET /transactions/:txId
+func transactionStatusHandler(tracker *TransactionTracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status, ok := tracker.Get(c.Param("txId"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+			return
+		}
+		c.JSON(http.StatusOK, status)
+	}
+}
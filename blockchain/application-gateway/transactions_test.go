@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadTransactionTrackerFailsPendingTransactions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transactions.json")
+	completed := time.Now().UTC().Add(-time.Minute)
+	previous := []*TransactionStatus{
+		{TxID: "committed", Transaction: "CreateCredential", Status: txStatusCommitted, ValidationCode: "VALID", CompletedAt: &completed,
+			FollowUp: "graduate details were not stored"},
+		{TxID: "pending", Transaction: "CreateCredential", Status: txStatusPending},
+		{TxID: "details", Transaction: "CreateCredential", Status: txStatusPending, FollowUp: "graduate details were not stored"},
+	}
+	data, _ := json.Marshal(previous)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second load is a later restart, the transactions stay failed
+	for restart := 1; restart <= 2; restart++ {
+		tracker, err := LoadTransactionTracker(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if status, _ := tracker.Get("committed"); status.Status != txStatusCommitted || status.Error != "" {
+			t.Errorf("restart %d: committed transaction is %s: %s", restart, status.Status, status.Error)
+		}
+		for txID, followUp := range map[string]bool{"pending": false, "details": true} {
+			status, ok := tracker.Get(txID)
+			if !ok {
+				t.Fatalf("restart %d: %s is lost", restart, txID)
+			}
+			if status.Status != txStatusFailed || status.CompletedAt == nil || !strings.Contains(status.Error, "gateway restarted") {
+				t.Errorf("restart %d: %s is %s: %s", restart, txID, status.Status, status.Error)
+			}
+			if strings.Contains(status.Error, "graduate details") != followUp {
+				t.Errorf("restart %d: %s error %q", restart, txID, status.Error)
+			}
+		}
+	}
+}
+
+func TestLoadTransactionTrackerWithoutFile(t *testing.T) {
+	tracker, err := LoadTransactionTracker(filepath.Join(t.TempDir(), "transactions.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tracker.Get("tx"); ok {
+		t.Error("empty tracker knows a transaction")
+	}
+}
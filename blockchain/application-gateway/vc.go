@@ -0,0 +1,513 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// W3C Verifiable Credentials Data Model 2.0. Its context maps terms it does not define to an
+// issuer-dependent vocabulary, which covers the diploma terms below.
+const vcContextV2 = "https://www.w3.org/ns/credentials/v2"
+
+// Controlled Identifiers context defining Multikey verification methods
+const cidContextV1 = "https://www.w3.org/ns/cid/v1"
+
+// Media type of exported credentials
+const vcMediaType = "application/vc"
+
+// Type of the credentialStatus entry, resolved by GET /credential/:id/status on the issuing gateway
+const ledgerStatusType = "LedgerCredentialStatus"
+
+// vcLedger is what the verifiable credential routes read from the ledger, a *FabricService
+type vcLedger interface {
+	ReadCredential(id string) (*Credential, error)
+	ReadIssuer(id string) (*LedgerIssuer, error)
+	IssuerStanding(id string) *IssuerStanding
+}
+
+// VerifiableCredential is a ledger credential rendered as a VC 2.0 JSON-LD document
+type VerifiableCredential struct {
+	Context           []string            `json:"@context"`
+	ID                string              `json:"id"`
+	Type              []string            `json:"type"`
+	Issuer            VCIssuer            `json:"issuer"`
+	ValidFrom         string              `json:"validFrom,omitempty"`
+	ValidUntil        string              `json:"validUntil,omitempty"`
+	CredentialSubject VCSubject           `json:"credentialSubject"`
+	CredentialStatus  VCStatus            `json:"credentialStatus"`
+	Proof             *DataIntegrityProof `json:"proof,omitempty"`
+}
+
+// VCIssuer identifies the issuer by its gateway URL
+type VCIssuer struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// VCSubject is the graduate, known to the ledger by their public key and diploma hash
+type VCSubject struct {
+	Degree            VCDegree `json:"degree"`
+	DiplomaHash       string   `json:"diplomaHash"`
+	HashScheme        string   `json:"hashScheme,omitempty"`
+	GraduatePublicKey string   `json:"graduatePublicKey"`
+}
+
+// VCDegree carries the diploma metadata
+type VCDegree struct {
+	Name           string `json:"name"`
+	DegreeType     string `json:"degreeType,omitempty"`
+	UniversityName string `json:"universityName"`
+}
+
+// VCStatus points verifiers back to the ledger status of the credential
+type VCStatus struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+// DataIntegrityProof is a W3C Data Integrity proof with one of the JCS cryptosuites
+type DataIntegrityProof struct {
+	Type               string `json:"type"`
+	Cryptosuite        string `json:"cryptosuite"`
+	Created            string `json:"created"`
+	VerificationMethod string `json:"verificationMethod"`
+	ProofPurpose       string `json:"proofPurpose"`
+	ProofValue         string `json:"proofValue,omitempty"`
+}
+
+// gatewayBaseURL returns the URL credentials, issuers and keys are published under. It is only
+// taken from the configuration: Host and X-Forwarded-Proto are chosen by the client.
+func gatewayBaseURL(cfg *Config) string {
+	return strings.TrimSuffix(cfg.PublicURL, "/")
+}
+
+// requirePublicURL answers 501 on routes whose documents carry gateway URLs while publicUrl is unset
+func requirePublicURL(cfg *Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cfg.PublicURL == "" {
+			c.AbortWithStatusJSON(http.StatusNotImplemented, gin.H{"error": "Verifiable credentials require publicUrl to be configured"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// renderVC builds the unsigned document of a credential. It only uses fields that do not change
+// with the credential status, so a document verifies for as long as the ledger record is unchanged.
+func renderVC(base string, credential *Credential, issuer *LedgerIssuer) *VerifiableCredential {
+	id := strings.TrimPrefix(credential.ID, credentialKeyPrefix)
+
+	validFrom := vcTime(credential.SignedAt)
+	if validFrom == "" {
+		validFrom = vcTime(credential.DiplomaMetadata.IssueDate)
+	}
+
+	return &VerifiableCredential{
+		Context: []string{vcContextV2},
+		ID:      base + "/credential/" + id,
+		Type:    []string{"VerifiableCredential", "DiplomaCredential"},
+		Issuer: VCIssuer{
+			ID:   base + "/issuers/" + issuer.ID,
+			Name: issuer.Name,
+		},
+		ValidFrom:  validFrom,
+		ValidUntil: vcTime(credential.DiplomaMetadata.ExpiryDate),
+		CredentialSubject: VCSubject{
+			Degree: VCDegree{
+				Name:           credential.DiplomaMetadata.DegreeName,
+				DegreeType:     credential.CredentialType,
+				UniversityName: credential.DiplomaMetadata.UniversityName,
+			},
+			DiplomaHash:       credential.DiplomaHash,
+			HashScheme:        credential.HashScheme,
+			GraduatePublicKey: credential.GraduatePublicKey,
+		},
+		CredentialStatus: VCStatus{
+			ID:   base + "/credential/" + id + "/status",
+			Type: ledgerStatusType,
+		},
+	}
+}
+
+// vcTime converts an RFC 3339 time or a YYYY-MM-DD date to an RFC 3339 UTC time, or returns ""
+func vcTime(value string) string {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC().Format(time.RFC3339)
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t.Format(time.RFC3339)
+	}
+	return ""
+}
+
+// verificationMethodURL is the URL of an issuer key as a Multikey document
+func verificationMethodURL(base string, issuerID string, keyID string) string {
+	return base + "/issuers/" + issuerID + "/keys/" + keyID
+}
+
+// jsonObject converts a value to the generic form documents are canonicalized and compared in
+func jsonObject(v any) (map[string]any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var object map[string]any
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+// proofHashInputs splits a secured document into the proof options and the unsecured document.
+// The options take the @context of the document, as the JCS cryptosuites require.
+func proofHashInputs(document map[string]any, proof map[string]any) (map[string]any, map[string]any) {
+	unsecured := make(map[string]any, len(document))
+	for key, value := range document {
+		if key != "proof" {
+			unsecured[key] = value
+		}
+	}
+
+	options := make(map[string]any, len(proof)+1)
+	for key, value := range proof {
+		if key != "proofValue" {
+			options[key] = value
+		}
+	}
+	options["@context"] = document["@context"]
+	return options, unsecured
+}
+
+// signVC adds a Data Integrity proof made with the issuer key to a rendered credential
+func signVC(vc *VerifiableCredential, signer *vcSigner, verificationMethod string) error {
+	cryptosuite, err := cryptosuiteFor(signer.key.Public())
+	if err != nil {
+		return err
+	}
+	proof := &DataIntegrityProof{
+		Type:               "DataIntegrityProof",
+		Cryptosuite:        cryptosuite,
+		Created:            time.Now().UTC().Format(time.RFC3339),
+		VerificationMethod: verificationMethod,
+		ProofPurpose:       "assertionMethod",
+	}
+
+	document, err := jsonObject(vc)
+	if err != nil {
+		return err
+	}
+	proofObject, err := jsonObject(proof)
+	if err != nil {
+		return err
+	}
+
+	options, unsecured := proofHashInputs(document, proofObject)
+	hashData, err := proofHashData(options, unsecured)
+	if err != nil {
+		return err
+	}
+	if proof.ProofValue, err = signer.sign(hashData); err != nil {
+		return err
+	}
+
+	vc.Proof = proof
+	return nil
+}
+
+// vcVerification is the outcome of checking a presented document against the ledger
+type vcVerification struct {
+	credential *Credential
+	keyID      string
+	failure    string // Why the document does not verify, empty when it does
+}
+
+// verifyVC checks the proof of a presented document with the issuer assertion key it names and compares
+// the document with a fresh rendering of the ledger credential under base. Errors are for documents
+// that cannot be checked at all; documents that fail the checks are reported in failure.
+func verifyVC(fs vcLedger, base string, document map[string]any) (*vcVerification, int, error) {
+	proofObject, ok := document["proof"].(map[string]any)
+	if !ok {
+		return nil, http.StatusBadRequest, errors.New("document has no proof object")
+	}
+	var proof DataIntegrityProof
+	data, _ := json.Marshal(proofObject)
+	if err := json.Unmarshal(data, &proof); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid proof: %v", err)
+	}
+	if proof.Type != "DataIntegrityProof" || proof.ProofPurpose != "assertionMethod" {
+		return nil, http.StatusBadRequest, errors.New("proof must be a DataIntegrityProof for assertionMethod")
+	}
+	if proof.Cryptosuite != cryptosuiteECDSA && proof.Cryptosuite != cryptosuiteEdDSA {
+		return nil, http.StatusBadRequest, fmt.Errorf("unsupported cryptosuite %q, expected %s or %s", proof.Cryptosuite, cryptosuiteECDSA, cryptosuiteEdDSA)
+	}
+	created, err := time.Parse(time.RFC3339, proof.Created)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid proof creation time: %v", err)
+	}
+
+	// The document ID is <base>/credential/<id>, documents published under another base are not ours
+	documentID, _ := document["id"].(string)
+	credentialID, ok := strings.CutPrefix(documentID, base+"/credential/")
+	if !ok || credentialID == "" || strings.Contains(credentialID, "/") {
+		return nil, http.StatusBadRequest, errors.New("document id is not a credential URL of this gateway")
+	}
+
+	credential, err := fs.ReadCredential(credentialID)
+	if err != nil {
+		return nil, http.StatusNotFound, fmt.Errorf("credential %s not found: %v", credentialID, err)
+	}
+	issuer, err := fs.ReadIssuer(credential.IssuerID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("failed to read issuer %s: %v", credential.IssuerID, err)
+	}
+
+	result := &vcVerification{credential: credential}
+
+	// Only assertion keys on the ledger are trusted, whatever host the verification method names.
+	// Credential signing keys never sign exported documents.
+	_, keyID, _ := strings.Cut(proof.VerificationMethod, "/issuers/"+issuer.ID+"/keys/")
+	var key *IssuerSigningKey
+	for _, candidate := range issuer.AssertionKeys {
+		if keyID != "" && candidate.ID == keyID {
+			key = &candidate
+			break
+		}
+	}
+	if key == nil {
+		result.failure = "Verification method is not an assertion key of issuer " + issuer.ID
+		return result, http.StatusOK, nil
+	}
+	result.keyID = key.ID
+
+	if key.Status == "Compromised" {
+		result.failure = "Signed with key " + key.ID + ", which is compromised"
+		return result, http.StatusOK, nil
+	}
+	if !keyValidAt(key, created) {
+		result.failure = "Key " + key.ID + " was not active when the proof was created"
+		return result, http.StatusOK, nil
+	}
+
+	options, unsecured := proofHashInputs(document, proofObject)
+	hashData, err := proofHashData(options, unsecured)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if err := verifyProofValue(key.PublicKey, proof.Cryptosuite, hashData, proof.ProofValue); err != nil {
+		result.failure = "Invalid proof: " + err.Error()
+		return result, http.StatusOK, nil
+	}
+
+	// A valid proof over other claims than the ledger holds means the credential changed since export
+	rendered, err := jsonObject(renderVC(base, credential, issuer))
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	presented, err := canonicalJSON(unsecured)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	expected, err := canonicalJSON(rendered)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if string(presented) != string(expected) {
+		result.failure = "Document does not match the credential on the ledger"
+	}
+	return result, http.StatusOK, nil
+}
+
+// keyValidAt reports whether an issuer key was active at a time, bounds included
+func keyValidAt(key *IssuerSigningKey, at time.Time) bool {
+	if key.ValidFrom != "" {
+		validFrom, err := time.Parse(time.RFC3339, key.ValidFrom)
+		if err != nil || at.Before(validFrom) {
+			return false
+		}
+	}
+	if key.ValidTo != "" {
+		validTo, err := time.Parse(time.RFC3339, key.ValidTo)
+		if err != nil || at.After(validTo) {
+			return false
+		}
+	}
+	return true
+}
+
+// registerVCRoutes adds the routes exporting credentials as verifiable credentials and verifying them
+func registerVCRoutes(public gin.IRoutes, fs vcLedger, cfg *Config) {
+	// GET /credential/:id/vc - Credential as a VC 2.0 document signed with the issuer's active assertion key
+	public.GET("/credential/:id/vc", requirePublicURL(cfg), func(c *gin.Context) {
+		credential, err := fs.ReadCredential(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Credential not found", "details": err.Error()})
+			return
+		}
+		if credential.Status == "Revoked" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Revoked credentials are not exported", "revocation": credential.Revocation})
+			return
+		}
+
+		issuer, err := fs.ReadIssuer(credential.IssuerID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read issuer", "details": err.Error()})
+			return
+		}
+		if issuer.Status == "Revoked" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Issuer " + issuer.ID + " is revoked"})
+			return
+		}
+
+		signer, err := loadVCSigner(cfg.VCKeysDir, issuer.ID)
+		if errors.Is(err, os.ErrNotExist) {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": "No assertion key configured for issuer " + issuer.ID})
+			return
+		}
+		if err != nil {
+			log.Printf("failed to load VC assertion key of issuer %s: %v", issuer.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load assertion key", "details": err.Error()})
+			return
+		}
+
+		// The proof must verify against the ledger, so only the issuer's active assertion key may sign.
+		// The key signing credentials onto the ledger stays with the issuer.
+		var active *IssuerSigningKey
+		for _, key := range issuer.AssertionKeys {
+			if key.Status == "Active" {
+				active = &key
+			}
+		}
+		if active == nil || active.ID != signer.keyID {
+			c.JSON(http.StatusConflict, gin.H{"error": "Configured key of issuer " + issuer.ID + " is not its active assertion key on the ledger", "keyId": signer.keyID})
+			return
+		}
+
+		base := gatewayBaseURL(cfg)
+		vc := renderVC(base, credential, issuer)
+		if err := signVC(vc, signer, verificationMethodURL(base, issuer.ID, signer.keyID)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign credential", "details": err.Error()})
+			return
+		}
+
+		body, err := json.Marshal(vc)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode credential", "details": err.Error()})
+			return
+		}
+		c.Data(http.StatusOK, vcMediaType, body)
+	})
+
+	// GET /credential/:id/status - Current ledger status, the credentialStatus of exported credentials
+	public.GET("/credential/:id/status", func(c *gin.Context) {
+		id := c.Param("id")
+		credential, err := fs.ReadCredential(id)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Credential not found", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"credentialId": id,
+			"status":       credential.Status,
+			"revocation":   credential.Revocation,
+			"suspension":   credential.Suspension,
+			"issuer":       fs.IssuerStanding(credential.IssuerID),
+		})
+	})
+
+	// GET /issuers/:issuerId/keys/:keyId - Issuer assertion or signing key as a Multikey verification method
+	public.GET("/issuers/:issuerId/keys/:keyId", requirePublicURL(cfg), func(c *gin.Context) {
+		issuer, err := fs.ReadIssuer(c.Param("issuerId"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Issuer not found", "details": err.Error()})
+			return
+		}
+
+		var key *IssuerSigningKey
+		for _, candidate := range slices.Concat(issuer.AssertionKeys, issuerKeys(issuer)) {
+			if candidate.ID == c.Param("keyId") {
+				key = &candidate
+				break
+			}
+		}
+		if key == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Key not found"})
+			return
+		}
+
+		publicKeyMultibase, err := multikey(key.PublicKey)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Key cannot be expressed as a Multikey", "details": err.Error()})
+			return
+		}
+
+		base := gatewayBaseURL(cfg)
+		method := gin.H{
+			"@context":           []string{cidContextV1},
+			"id":                 verificationMethodURL(base, issuer.ID, key.ID),
+			"type":               "Multikey",
+			"controller":         base + "/issuers/" + issuer.ID,
+			"publicKeyMultibase": publicKeyMultibase,
+		}
+		if key.Status == "Compromised" {
+			method["revoked"] = key.ValidTo
+		}
+		c.JSON(http.StatusOK, method)
+	})
+
+	// POST /verify/vc - Verify an exported credential against the ledger
+	public.POST("/verify/vc", requirePublicURL(cfg), func(c *gin.Context) {
+		var document map[string]any
+		if err := c.ShouldBindJSON(&document); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"verified": false, "error": "Invalid request", "details": err.Error()})
+			return
+		}
+
+		result, status, err := verifyVC(fs, gatewayBaseURL(cfg), document)
+		if err != nil {
+			switch status {
+			case http.StatusNotFound:
+				verifications.WithLabelValues("vc", verificationNotFound).Inc()
+			case http.StatusInternalServerError:
+				verifications.WithLabelValues("vc", verificationError).Inc()
+			}
+			c.JSON(status, gin.H{"verified": false, "error": "Credential could not be verified", "details": err.Error()})
+			return
+		}
+
+		credential := result.credential
+		credentialID := strings.TrimPrefix(credential.ID, credentialKeyPrefix)
+		if result.failure != "" {
+			verifications.WithLabelValues("vc", verificationBadSignature).Inc()
+			c.JSON(http.StatusOK, gin.H{
+				"verified":     false,
+				"message":      result.failure,
+				"credentialId": credentialID,
+				"keyId":        result.keyID,
+			})
+			return
+		}
+
+		issuer := fs.IssuerStanding(credential.IssuerID)
+		recordVerification("vc", credential, issuer)
+		c.JSON(http.StatusOK, gin.H{
+			"verified":     true,
+			"message":      "Verifiable credential verified",
+			"credentialId": credentialID,
+			"keyId":        result.keyID,
+			"status":       credential.Status,
+			"issuerId":     credential.IssuerID,
+			"issuer":       issuer,
+			"revocation":   credential.Revocation,
+			"suspension":   credential.Suspension,
+		})
+	})
+}
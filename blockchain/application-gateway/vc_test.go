@@ -0,0 +1,313 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testLedger serves fixed credentials and issuers to the verifiable credential routes
+type testLedger struct {
+	credentials map[string]*Credential
+	issuers     map[string]*LedgerIssuer
+}
+
+func (l *testLedger) ReadCredential(id string) (*Credential, error) {
+	credential, ok := l.credentials[id]
+	if !ok {
+		return nil, fmt.Errorf("the credential %s does not exist", id)
+	}
+	copied := *credential
+	return &copied, nil
+}
+
+func (l *testLedger) ReadIssuer(id string) (*LedgerIssuer, error) {
+	issuer, ok := l.issuers[id]
+	if !ok {
+		return nil, fmt.Errorf("the issuer %s does not exist", id)
+	}
+	copied := *issuer
+	return &copied, nil
+}
+
+func (l *testLedger) IssuerStanding(id string) *IssuerStanding {
+	return &IssuerStanding{ID: id, Status: l.issuers[id].Status}
+}
+
+// newP256Key generates a P-256 key and returns it with its public key in PEM
+func newP256Key(t *testing.T) (*ecdsa.PrivateKey, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, publicKeyPEM(t, &key.PublicKey)
+}
+
+// writeVCKey stores key as the VC key of issuer lu in dir
+func writeVCKey(t *testing.T, dir string, key *ecdsa.PrivateKey) {
+	t.Helper()
+	privateDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "lu.pem"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newVCGateway serves the VC routes for credential lu-1 of issuer lu. The issuer's active assertion
+// key is the P-256 key written to the returned VC keys directory, its ledger signing key is another.
+func newVCGateway(t *testing.T) (*gin.Engine, *testLedger, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	assertionKey, assertionPublicKey := newP256Key(t)
+	_, publicKey := newP256Key(t)
+	dir := t.TempDir()
+	writeVCKey(t, dir, assertionKey)
+	validFrom := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+
+	ledger := &testLedger{
+		credentials: map[string]*Credential{
+			"lu-1": {
+				ID:                credentialKeyPrefix + "lu-1",
+				DiplomaHash:       testDiplomaHash,
+				GraduatePublicKey: testPublicKey,
+				IssuerID:          "lu",
+				Status:            "Valid",
+				CredentialType:    "Bachelor",
+				SignedAt:          "2025-06-20T10:00:00Z",
+				DiplomaMetadata: DiplomaMetadata{
+					UniversityName: "University of Latvia",
+					DegreeName:     "Computer Science",
+					IssueDate:      "2025-06-20",
+				},
+			},
+		},
+		issuers: map[string]*LedgerIssuer{
+			"lu": {
+				ID:        "lu",
+				Name:      "University of Latvia",
+				Status:    "Active",
+				PublicKey: publicKey,
+				Keys: []IssuerSigningKey{{
+					ID:        keyFingerprint(publicKey),
+					PublicKey: publicKey,
+					Status:    "Active",
+					ValidFrom: validFrom,
+				}},
+				AssertionKeys: []IssuerSigningKey{{
+					ID:        keyFingerprint(assertionPublicKey),
+					PublicKey: assertionPublicKey,
+					Status:    "Active",
+					ValidFrom: validFrom,
+				}},
+			},
+		},
+	}
+
+	router := gin.New()
+	registerVCRoutes(router, ledger, &Config{VCKeysDir: dir, PublicURL: "https://gateway.example"})
+	return router, ledger, dir
+}
+
+func serve(router *gin.Engine, method string, path string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// verifyDocument posts a document to /verify/vc and returns the verified flag and message
+func verifyDocument(t *testing.T, router *gin.Engine, document map[string]any) (bool, string) {
+	t.Helper()
+	body, err := json.Marshal(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := serve(router, http.MethodPost, "/verify/vc", string(body))
+	if w.Code != http.StatusOK {
+		t.Fatalf("verify: %d %s", w.Code, w.Body)
+	}
+	var result struct {
+		Verified bool   `json:"verified"`
+		Message  string `json:"message"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	return result.Verified, result.Message
+}
+
+func TestVerifiableCredentialRoundTrip(t *testing.T) {
+	router, ledger, _ := newVCGateway(t)
+
+	w := serve(router, http.MethodGet, "/credential/lu-1/vc", "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != vcMediaType {
+		t.Fatalf("export: %d %s %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+	exported := w.Body.String()
+
+	var document map[string]any
+	if err := json.Unmarshal([]byte(exported), &document); err != nil {
+		t.Fatal(err)
+	}
+	proof, _ := document["proof"].(map[string]any)
+	if proof["cryptosuite"] != cryptosuiteECDSA || !strings.HasPrefix(proof["proofValue"].(string), "z") {
+		t.Fatalf("unexpected proof %v", proof)
+	}
+	if verified, message := verifyDocument(t, router, document); !verified {
+		t.Fatalf("exported document does not verify: %s", message)
+	}
+
+	// Each tamper starts from the exported document
+	tampers := []struct {
+		name    string
+		tamper  func(document map[string]any)
+		message string
+	}{
+		{"degree", func(d map[string]any) {
+			d["credentialSubject"].(map[string]any)["degree"].(map[string]any)["name"] = "Medicine"
+		}, "Invalid proof"},
+		{"added claim", func(d map[string]any) {
+			d["credentialSubject"].(map[string]any)["honours"] = true
+		}, "Invalid proof"},
+		{"proof creation time", func(d map[string]any) {
+			d["proof"].(map[string]any)["created"] = time.Now().UTC().Add(-time.Minute).Format(time.RFC3339)
+		}, "Invalid proof"},
+		{"proof value", func(d map[string]any) {
+			value := d["proof"].(map[string]any)["proofValue"].(string)
+			last := "2"
+			if strings.HasSuffix(value, last) {
+				last = "3"
+			}
+			d["proof"].(map[string]any)["proofValue"] = value[:len(value)-1] + last
+		}, "Invalid proof"},
+		{"unknown key", func(d map[string]any) {
+			d["proof"].(map[string]any)["verificationMethod"] = "https://gateway.example/issuers/lu/keys/0000000000000000"
+		}, "not an assertion key"},
+		{"ledger signing key", func(d map[string]any) {
+			d["proof"].(map[string]any)["verificationMethod"] = "https://gateway.example/issuers/lu/keys/" + ledger.issuers["lu"].Keys[0].ID
+		}, "not an assertion key"},
+	}
+	for _, tt := range tampers {
+		var tampered map[string]any
+		json.Unmarshal([]byte(exported), &tampered)
+		tt.tamper(tampered)
+		verified, message := verifyDocument(t, router, tampered)
+		if verified || !strings.Contains(message, tt.message) {
+			t.Errorf("tampered %s: verified %v, %q, want %q", tt.name, verified, message, tt.message)
+		}
+	}
+
+	// A correctly signed document no longer matching the ledger is rejected as well
+	ledger.credentials["lu-1"].DiplomaMetadata.DegreeName = "Mathematics"
+	if verified, message := verifyDocument(t, router, document); verified || !strings.Contains(message, "does not match the credential") {
+		t.Errorf("changed ledger credential: verified %v, %q", verified, message)
+	}
+}
+
+func TestVerifiableCredentialExportNeedsActiveAssertionKey(t *testing.T) {
+	router, ledger, _ := newVCGateway(t)
+
+	_, otherKey := newP256Key(t)
+	issuer := ledger.issuers["lu"]
+	issuer.AssertionKeys[0].Status = "Retired"
+	issuer.AssertionKeys = append(issuer.AssertionKeys, IssuerSigningKey{ID: keyFingerprint(otherKey), PublicKey: otherKey, Status: "Active"})
+
+	if w := serve(router, http.MethodGet, "/credential/lu-1/vc", ""); w.Code != http.StatusConflict {
+		t.Errorf("export with a retired assertion key: %d %s, want 409", w.Code, w.Body)
+	}
+
+	issuer.AssertionKeys = nil
+	if w := serve(router, http.MethodGet, "/credential/lu-1/vc", ""); w.Code != http.StatusConflict {
+		t.Errorf("export without an assertion key: %d %s, want 409", w.Code, w.Body)
+	}
+}
+
+// The gateway must not sign documents with the key signing credentials onto the ledger, even
+// when an operator configures it as the VC key
+func TestVerifiableCredentialExportRefusesLedgerSigningKey(t *testing.T) {
+	router, ledger, dir := newVCGateway(t)
+
+	signingKey, publicKey := newP256Key(t)
+	writeVCKey(t, dir, signingKey)
+	issuer := ledger.issuers["lu"]
+	issuer.PublicKey = publicKey
+	issuer.Keys = []IssuerSigningKey{{ID: keyFingerprint(publicKey), PublicKey: publicKey, Status: "Active"}}
+
+	if w := serve(router, http.MethodGet, "/credential/lu-1/vc", ""); w.Code != http.StatusConflict {
+		t.Errorf("export with the ledger signing key: %d %s, want 409", w.Code, w.Body)
+	}
+}
+
+// Without publicUrl the gateway would have to take its URL from the request, which the client controls
+func TestVerifiableCredentialRoutesNeedPublicURL(t *testing.T) {
+	_, ledger, dir := newVCGateway(t)
+	router := gin.New()
+	registerVCRoutes(router, ledger, &Config{VCKeysDir: dir})
+
+	keyID := ledger.issuers["lu"].AssertionKeys[0].ID
+	requests := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodGet, "/credential/lu-1/vc"},
+		{http.MethodGet, "/issuers/lu/keys/" + keyID},
+		{http.MethodPost, "/verify/vc"},
+	}
+	for _, r := range requests {
+		if w := serve(router, r.method, r.path, `{}`); w.Code != http.StatusNotImplemented {
+			t.Errorf("%s %s without publicUrl: %d %s, want 501", r.method, r.path, w.Code, w.Body)
+		}
+	}
+	if w := serve(router, http.MethodGet, "/credential/lu-1/status", ""); w.Code != http.StatusOK {
+		t.Errorf("status without publicUrl: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestVerifiableCredentialURLsComeFromPublicURL(t *testing.T) {
+	router, _, _ := newVCGateway(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/credential/lu-1/vc", nil)
+	req.Host = "attacker.example"
+	req.Header.Set("X-Forwarded-Proto", "http")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("export: %d %s", w.Code, w.Body)
+	}
+	if strings.Contains(w.Body.String(), "attacker.example") {
+		t.Fatalf("exported document uses the request host: %s", w.Body)
+	}
+
+	var document map[string]any
+	json.Unmarshal(w.Body.Bytes(), &document)
+	if document["id"] != "https://gateway.example/credential/lu-1" {
+		t.Errorf("document id %v", document["id"])
+	}
+
+	// A document naming another base is not one of ours, whatever its proof
+	for _, id := range []string{"https://attacker.example/credential/lu-1", "https://gateway.example.attacker.example/credential/lu-1", "lu-1"} {
+		document["id"] = id
+		body, _ := json.Marshal(document)
+		if w := serve(router, http.MethodPost, "/verify/vc", string(body)); w.Code != http.StatusBadRequest || strings.Contains(w.Body.String(), `"verified":true`) {
+			t.Errorf("document with id %s: %d %s, want 400", id, w.Code, w.Body)
+		}
+	}
+}
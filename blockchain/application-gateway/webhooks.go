@@ -0,0 +1,566 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	webhookMaxAttempts    = 6
+	webhookInitialBackoff = 2 * time.Second
+	webhookMaxBackoff     = 2 * time.Minute
+	webhookTimeout        = 10 * time.Second
+	webhookDeadLetterCap  = 1000
+)
+
+var ErrWebhookNotFound = errors.New("webhook subscription not found")
+
+// Callbacks must not reach the gateway host or its internal network
+var errWebhookAddress = errors.New("webhook URL must resolve to a public address")
+
+// WebhookSubscription registers a callback URL for changes to a credential or to an issuer's credentials
+type WebhookSubscription struct {
+	ID           string    `json:"id"`
+	Owner        string    `json:"owner"`              // Issuer that created the subscription, the only one managing it
+	APIKeyID     string    `json:"apiKeyId,omitempty"` // API key that created the subscription, the only key managing it
+	URL          string    `json:"url"`
+	CredentialID string    `json:"credentialId,omitempty"`
+	IssuerID     string    `json:"issuerId,omitempty"`
+	Events       []string  `json:"events,omitempty"` // Empty means every event
+	Secret       string    `json:"secret,omitempty"` // HMAC key, only returned when the subscription is created
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// Matches reports whether the subscription wants the given event
+func (s *WebhookSubscription) Matches(event *LedgerEvent) bool {
+	subject := event.Subject()
+	if s.CredentialID != "" && !subject.RefersTo(s.CredentialID) {
+		return false
+	}
+	if s.IssuerID != "" && s.IssuerID != subject.IssuerID {
+		return false
+	}
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, name := range s.Events {
+		if name == event.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is the JSON body POSTed to subscribers
+type WebhookDelivery struct {
+	DeliveryID     string       `json:"deliveryId"`
+	SubscriptionID string       `json:"subscriptionId"`
+	Event          *LedgerEvent `json:"event"`
+	SentAt         time.Time    `json:"sentAt"`
+}
+
+// DeadLetter is a delivery that was abandoned after all retries failed
+type DeadLetter struct {
+	Owner     string          `json:"owner"`              // Owner of the subscription
+	APIKeyID  string          `json:"apiKeyId,omitempty"` // API key that created the subscription
+	Delivery  WebhookDelivery `json:"delivery"`
+	URL       string          `json:"url"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"lastError"`
+	FailedAt  time.Time       `json:"failedAt"`
+}
+
+// CreateWebhookRequest for POST /webhooks
+type CreateWebhookRequest struct {
+	URL          string   `json:"url" binding:"required,url"`
+	CredentialID string   `json:"credentialId"`
+	IssuerID     string   `json:"issuerId"`
+	Events       []string `json:"events"`
+}
+
+// webhookOwner is the caller managing subscriptions. Issuer users manage every subscription of
+// their issuer, API keys only those they created.
+type webhookOwner struct {
+	IssuerID string
+	APIKeyID string
+}
+
+// owns reports whether the caller manages a subscription or dead letter of issuerID created with apiKeyID
+func (o webhookOwner) owns(issuerID string, apiKeyID string) bool {
+	return issuerID == o.IssuerID && (o.APIKeyID == "" || apiKeyID == o.APIKeyID)
+}
+
+// WebhookStore keeps subscriptions and dead letters, persisted to a JSON file
+type WebhookStore struct {
+	mu            sync.RWMutex
+	path          string
+	subscriptions map[string]*WebhookSubscription
+	deadLetters   []DeadLetter
+}
+
+type webhookStoreFileContents struct {
+	Subscriptions []*WebhookSubscription `json:"subscriptions"`
+	DeadLetters   []DeadLetter           `json:"deadLetters"`
+}
+
+// LoadWebhookStore opens the store at path. A missing file yields an empty store.
+func LoadWebhookStore(path string) (*WebhookStore, error) {
+	store := &WebhookStore{
+		path:          path,
+		subscriptions: make(map[string]*WebhookSubscription),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var contents webhookStoreFileContents
+	if err := json.Unmarshal(data, &contents); err != nil {
+		return nil, err
+	}
+	for _, sub := range contents.Subscriptions {
+		store.subscriptions[sub.ID] = sub
+	}
+	store.deadLetters = contents.DeadLetters
+
+	return store, nil
+}
+
+// save writes the store to disk. Callers must hold the write lock.
+func (s *WebhookStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	contents := webhookStoreFileContents{
+		Subscriptions: make([]*WebhookSubscription, 0, len(s.subscriptions)),
+		DeadLetters:   s.deadLetters,
+	}
+	for _, sub := range s.subscriptions {
+		contents.Subscriptions = append(contents.Subscriptions, sub)
+	}
+
+	data, err := json.MarshalIndent(contents, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// Create registers a new subscription of an owner and generates its signing secret
+func (s *WebhookStore) Create(owner webhookOwner, req CreateWebhookRequest) (*WebhookSubscription, error) {
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+
+	sub := &WebhookSubscription{
+		ID:           id,
+		Owner:        owner.IssuerID,
+		APIKeyID:     owner.APIKeyID,
+		URL:          req.URL,
+		CredentialID: req.CredentialID,
+		IssuerID:     req.IssuerID,
+		Events:       req.Events,
+		Secret:       secret,
+		CreatedAt:    time.Now().UTC(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscriptions[id] = sub
+	if err := s.save(); err != nil {
+		delete(s.subscriptions, id)
+		return nil, err
+	}
+
+	created := *sub
+	return &created, nil
+}
+
+// Get returns a subscription of an owner without its secret. Other owners' subscriptions are not found.
+func (s *WebhookStore) Get(owner webhookOwner, id string) (*WebhookSubscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sub, ok := s.subscriptions[id]
+	if !ok || !owner.owns(sub.Owner, sub.APIKeyID) {
+		return nil, ErrWebhookNotFound
+	}
+	return redacted(sub), nil
+}
+
+// List returns the subscriptions of an owner without their secrets
+func (s *WebhookStore) List(owner webhookOwner) []*WebhookSubscription {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	subs := make([]*WebhookSubscription, 0, len(s.subscriptions))
+	for _, sub := range s.subscriptions {
+		if owner.owns(sub.Owner, sub.APIKeyID) {
+			subs = append(subs, redacted(sub))
+		}
+	}
+	return subs
+}
+
+// Delete removes a subscription of an owner
+func (s *WebhookStore) Delete(owner webhookOwner, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subscriptions[id]
+	if !ok || !owner.owns(sub.Owner, sub.APIKeyID) {
+		return ErrWebhookNotFound
+	}
+	delete(s.subscriptions, id)
+	if err := s.save(); err != nil {
+		s.subscriptions[id] = sub
+		return err
+	}
+	return nil
+}
+
+// matching returns copies of subscriptions, secrets included, that want the event
+func (s *WebhookStore) matching(event *LedgerEvent) []WebhookSubscription {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var subs []WebhookSubscription
+	for _, sub := range s.subscriptions {
+		if sub.Matches(event) {
+			subs = append(subs, *sub)
+		}
+	}
+	return subs
+}
+
+// AddDeadLetter records an abandoned delivery, keeping at most webhookDeadLetterCap entries
+func (s *WebhookStore) AddDeadLetter(letter DeadLetter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deadLetters = append(s.deadLetters, letter)
+	if len(s.deadLetters) > webhookDeadLetterCap {
+		s.deadLetters = s.deadLetters[len(s.deadLetters)-webhookDeadLetterCap:]
+	}
+	if err := s.save(); err != nil {
+		log.Printf("failed to persist webhook dead letter: %v", err)
+	}
+}
+
+// DeadLetters returns the abandoned deliveries of an owner's subscriptions
+func (s *WebhookStore) DeadLetters(owner webhookOwner) []DeadLetter {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	letters := []DeadLetter{}
+	for _, letter := range s.deadLetters {
+		if owner.owns(letter.Owner, letter.APIKeyID) {
+			letters = append(letters, letter)
+		}
+	}
+	return letters
+}
+
+func redacted(sub *WebhookSubscription) *WebhookSubscription {
+	copied := *sub
+	copied.Secret = ""
+	return &copied
+}
+
+// WebhookDispatcher posts matching ledger events to subscribers
+type WebhookDispatcher struct {
+	store          *WebhookStore
+	keys           *APIKeyStore // Subscriptions of revoked or expired API keys receive nothing
+	client         *http.Client
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+func NewWebhookDispatcher(store *WebhookStore, keys *APIKeyStore) *WebhookDispatcher {
+	// Addresses are checked when connecting, so a host resolving to an internal address after the
+	// subscription was created, or a redirect to one, is refused as well
+	dialer := &net.Dialer{Timeout: webhookTimeout, Control: refuseInternalAddress}
+	return &WebhookDispatcher{
+		store: store,
+		keys:  keys,
+		client: &http.Client{
+			Timeout:   webhookTimeout,
+			Transport: &http.Transport{DialContext: dialer.DialContext},
+		},
+		maxAttempts:    webhookMaxAttempts,
+		initialBackoff: webhookInitialBackoff,
+		maxBackoff:     webhookMaxBackoff,
+	}
+}
+
+// Run consumes events from an EventHub subscription until the channel is closed
+func (d *WebhookDispatcher) Run(events <-chan *LedgerEvent) {
+	for event := range events {
+		d.Dispatch(event)
+	}
+}
+
+// Dispatch starts a delivery for every subscription matching the event
+func (d *WebhookDispatcher) Dispatch(event *LedgerEvent) {
+	for _, sub := range d.store.matching(event) {
+		if sub.APIKeyID != "" && !d.keys.Active(sub.APIKeyID) {
+			continue
+		}
+		go d.deliver(sub, event)
+	}
+}
+
+// deliver POSTs the event, retrying with exponential backoff and dead-lettering on exhaustion
+func (d *WebhookDispatcher) deliver(sub WebhookSubscription, event *LedgerEvent) {
+	deliveryID, err := randomHex(16)
+	if err != nil {
+		log.Printf("failed to create webhook delivery id: %v", err)
+		return
+	}
+
+	delivery := WebhookDelivery{
+		DeliveryID:     deliveryID,
+		SubscriptionID: sub.ID,
+		Event:          event,
+		SentAt:         time.Now().UTC(),
+	}
+
+	body, err := json.Marshal(delivery)
+	if err != nil {
+		log.Printf("failed to marshal webhook delivery: %v", err)
+		return
+	}
+
+	backoff := d.initialBackoff
+	var lastErr error
+	for attempt := 1; attempt <= d.maxAttempts; attempt++ {
+		lastErr = d.post(sub, delivery, body, attempt)
+		if lastErr == nil {
+			return
+		}
+		if attempt == d.maxAttempts {
+			break
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > d.maxBackoff {
+			backoff = d.maxBackoff
+		}
+	}
+
+	log.Printf("webhook %s gave up on delivery %s: %v", sub.ID, deliveryID, lastErr)
+	d.store.AddDeadLetter(DeadLetter{
+		Owner:     sub.Owner,
+		APIKeyID:  sub.APIKeyID,
+		Delivery:  delivery,
+		URL:       sub.URL,
+		Attempts:  d.maxAttempts,
+		LastError: lastErr.Error(),
+		FailedAt:  time.Now().UTC(),
+	})
+}
+
+func (d *WebhookDispatcher) post(sub WebhookSubscription, delivery WebhookDelivery, body []byte, attempt int) error {
+	req, err := http.NewRequest(http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Id", sub.ID)
+	req.Header.Set("X-Webhook-Delivery", delivery.DeliveryID)
+	req.Header.Set("X-Webhook-Event", delivery.Event.Name)
+	req.Header.Set("X-Webhook-Attempt", strconv.Itoa(attempt))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+SignWebhookPayload(sub.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("subscriber responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// SignWebhookPayload computes the hex HMAC-SHA256 of "<timestamp>.<body>" with the subscription secret.
+// Subscribers recompute it from the X-Webhook-Timestamp header and the raw body.
+func SignWebhookPayload(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// publicAddress reports whether a callback may be sent to ip
+func publicAddress(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast() && !ip.IsMulticast()
+}
+
+// validateWebhookURL accepts http and https URLs whose host only resolves to public addresses
+func validateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("webhook URL must be http or https")
+	}
+
+	ips, err := net.LookupIP(u.Hostname())
+	if err != nil {
+		return fmt.Errorf("failed to resolve webhook host: %v", err)
+	}
+	for _, ip := range ips {
+		if !publicAddress(ip) {
+			return errWebhookAddress
+		}
+	}
+	return nil
+}
+
+// refuseInternalAddress is a net.Dialer Control function rejecting connections to non-public addresses
+func refuseInternalAddress(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !publicAddress(ip) {
+		return errWebhookAddress
+	}
+	return nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// currentWebhookOwner returns the owner of the subscriptions the caller authenticated by requireIssuer manages
+func currentWebhookOwner(c *gin.Context) webhookOwner {
+	return webhookOwner{IssuerID: authenticatedIssuer(c), APIKeyID: c.GetString(contextAPIKeyID)}
+}
+
+// registerWebhookRoutes adds the /webhooks subscription management routes. They run after
+// requireIssuer and need the webhooks scope. Subscriptions are limited to the caller's issuer, its
+// users manage all of them and API keys, e.g. of verifiers, only their own.
+func registerWebhookRoutes(router gin.IRoutes, store *WebhookStore, credentials CredentialStore) {
+	// POST /webhooks - Register a callback URL, the signing secret is only returned here
+	router.POST("/webhooks", func(c *gin.Context) {
+		var req CreateWebhookRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+			return
+		}
+
+		if req.CredentialID == "" && req.IssuerID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Either credentialId or issuerId is required"})
+			return
+		}
+
+		owner := currentWebhookOwner(c)
+		if req.IssuerID != "" {
+			if req.IssuerID != owner.IssuerID {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Subscriptions are limited to issuer " + owner.IssuerID})
+				return
+			}
+			// Every credential the issuer creates would be reported, which is listing them
+			if !slices.Contains(c.GetStringSlice(contextScopes), scopeList) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "The " + scopeList + " scope is required to subscribe to an issuer"})
+				return
+			}
+		}
+		if req.CredentialID != "" && authorizeCredentialIssuer(c, credentials, req.CredentialID) == nil {
+			return
+		}
+
+		if err := validateWebhookURL(req.URL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook URL", "details": err.Error()})
+			return
+		}
+
+		sub, err := store.Create(owner, req)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, sub)
+	})
+
+	// GET /webhooks - List the caller's subscriptions
+	router.GET("/webhooks", func(c *gin.Context) {
+		subs := store.List(currentWebhookOwner(c))
+		c.JSON(http.StatusOK, gin.H{
+			"webhooks": subs,
+			"count":    len(subs),
+		})
+	})
+
+	// GET /webhooks/dead-letters - Deliveries to the caller's subscriptions abandoned after all retries
+	router.GET("/webhooks/dead-letters", func(c *gin.Context) {
+		letters := store.DeadLetters(currentWebhookOwner(c))
+		c.JSON(http.StatusOK, gin.H{
+			"deadLetters": letters,
+			"count":       len(letters),
+		})
+	})
+
+	// GET /webhooks/:id - Read a subscription
+	router.GET("/webhooks/:id", func(c *gin.Context) {
+		sub, err := store.Get(currentWebhookOwner(c), c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, sub)
+	})
+
+	// DELETE /webhooks/:id - Remove a subscription
+	router.DELETE("/webhooks/:id", func(c *gin.Context) {
+		err := store.Delete(currentWebhookOwner(c), c.Param("id"))
+		if errors.Is(err, ErrWebhookNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook", "details": err.Error()})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookRequest is a delivery as received by a test subscriber
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+	at     time.Time
+}
+
+// webhookSubscriber records deliveries and answers with the given status codes in turn,
+// repeating the last one
+func webhookSubscriber(t *testing.T, statuses ...int) (*httptest.Server, func() []webhookRequest) {
+	t.Helper()
+	var mu sync.Mutex
+	var received []webhookRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, webhookRequest{header: r.Header.Clone(), body: body, at: time.Now()})
+		status := statuses[min(len(received), len(statuses))-1]
+		mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []webhookRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]webhookRequest{}, received...)
+	}
+}
+
+// testDispatcher delivers to httptest servers, which listen on loopback, with short backoffs
+func testDispatcher(store *WebhookStore, keys *APIKeyStore, server *httptest.Server) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		store:          store,
+		keys:           keys,
+		client:         server.Client(),
+		maxAttempts:    3,
+		initialBackoff: 20 * time.Millisecond,
+		maxBackoff:     30 * time.Millisecond,
+	}
+}
+
+func testEvent() *LedgerEvent {
+	return &LedgerEvent{
+		Name:          "CredentialRevoked",
+		BlockNumber:   7,
+		TransactionID: "tx1",
+		Payload:       json.RawMessage(`{"credentialId":"lu-1","issuerId":"lu","status":"Revoked"}`),
+	}
+}
+
+// waitFor polls until cond holds or fails the test after a second
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWebhookDeliveryIsSigned(t *testing.T) {
+	server, received := webhookSubscriber(t, http.StatusNoContent)
+	store, _ := LoadWebhookStore("")
+	keys, _ := LoadAPIKeyStore("")
+	sub, err := store.Create(webhookOwner{IssuerID: "lu"}, CreateWebhookRequest{URL: server.URL, IssuerID: "lu"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testDispatcher(store, keys, server).Dispatch(testEvent())
+	waitFor(t, "delivery", func() bool { return len(received()) == 1 })
+
+	delivery := received()[0]
+	timestamp := delivery.header.Get("X-Webhook-Timestamp")
+	if _, err := strconv.ParseInt(timestamp, 10, 64); err != nil {
+		t.Fatalf("X-Webhook-Timestamp %q is not a Unix time", timestamp)
+	}
+	want := "sha256=" + SignWebhookPayload(sub.Secret, timestamp, delivery.body)
+	if got := delivery.header.Get("X-Webhook-Signature"); got != want {
+		t.Errorf("X-Webhook-Signature = %q, want %q", got, want)
+	}
+	if got := delivery.header.Get("X-Webhook-Signature"); got == "sha256="+SignWebhookPayload("other secret", timestamp, delivery.body) {
+		t.Error("signature does not depend on the secret")
+	}
+
+	for header, want := range map[string]string{
+		"X-Webhook-Id":      sub.ID,
+		"X-Webhook-Event":   "CredentialRevoked",
+		"X-Webhook-Attempt": "1",
+		"Content-Type":      "application/json",
+	} {
+		if got := delivery.header.Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+
+	var body WebhookDelivery
+	if err := json.Unmarshal(delivery.body, &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.SubscriptionID != sub.ID || body.DeliveryID != delivery.header.Get("X-Webhook-Delivery") || body.Event.TransactionID != "tx1" {
+		t.Errorf("unexpected delivery body %s", delivery.body)
+	}
+}
+
+func TestWebhookRetriesServerErrors(t *testing.T) {
+	server, received := webhookSubscriber(t, http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK)
+	store, _ := LoadWebhookStore("")
+	keys, _ := LoadAPIKeyStore("")
+	if _, err := store.Create(webhookOwner{IssuerID: "lu"}, CreateWebhookRequest{URL: server.URL, CredentialID: "lu-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	dispatcher := testDispatcher(store, keys, server)
+	dispatcher.Dispatch(testEvent())
+	waitFor(t, "three attempts", func() bool { return len(received()) == 3 })
+
+	attempts := received()
+	deliveryID := attempts[0].header.Get("X-Webhook-Delivery")
+	for i, attempt := range attempts {
+		if got := attempt.header.Get("X-Webhook-Attempt"); got != strconv.Itoa(i+1) {
+			t.Errorf("attempt %d has X-Webhook-Attempt %q", i+1, got)
+		}
+		if got := attempt.header.Get("X-Webhook-Delivery"); got != deliveryID {
+			t.Errorf("attempt %d has delivery ID %q, want %q", i+1, got, deliveryID)
+		}
+	}
+
+	// The backoff doubles from initialBackoff and is capped at maxBackoff
+	if gap := attempts[1].at.Sub(attempts[0].at); gap < dispatcher.initialBackoff {
+		t.Errorf("second attempt after %v, want at least %v", gap, dispatcher.initialBackoff)
+	}
+	if gap := attempts[2].at.Sub(attempts[1].at); gap < dispatcher.maxBackoff {
+		t.Errorf("third attempt after %v, want at least %v", gap, dispatcher.maxBackoff)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if letters := store.DeadLetters(webhookOwner{IssuerID: "lu"}); len(letters) != 0 {
+		t.Errorf("delivered event was dead-lettered: %+v", letters)
+	}
+}
+
+func TestWebhookDeadLettersAfterLastAttempt(t *testing.T) {
+	server, received := webhookSubscriber(t, http.StatusServiceUnavailable)
+	store, _ := LoadWebhookStore("")
+	keys, _ := LoadAPIKeyStore("")
+	sub, err := store.Create(webhookOwner{IssuerID: "lu"}, CreateWebhookRequest{URL: server.URL, IssuerID: "lu"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testDispatcher(store, keys, server).Dispatch(testEvent())
+	waitFor(t, "dead letter", func() bool { return len(store.DeadLetters(webhookOwner{IssuerID: "lu"})) == 1 })
+
+	if got := len(received()); got != 3 {
+		t.Errorf("subscriber received %d attempts, want 3", got)
+	}
+	letter := store.DeadLetters(webhookOwner{IssuerID: "lu"})[0]
+	if letter.Attempts != 3 || letter.URL != server.URL || letter.Delivery.SubscriptionID != sub.ID {
+		t.Errorf("unexpected dead letter %+v", letter)
+	}
+	if !strings.Contains(letter.LastError, "503") {
+		t.Errorf("LastError = %q, want the subscriber status", letter.LastError)
+	}
+	if letters := store.DeadLetters(webhookOwner{IssuerID: "rtu"}); len(letters) != 0 {
+		t.Errorf("dead letter visible to another owner: %+v", letters)
+	}
+}
+
+func TestWebhookDispatcherRefusesInternalAddresses(t *testing.T) {
+	server, received := webhookSubscriber(t, http.StatusOK)
+	store, _ := LoadWebhookStore("")
+	keys, _ := LoadAPIKeyStore("")
+	if _, err := store.Create(webhookOwner{IssuerID: "lu"}, CreateWebhookRequest{URL: server.URL, IssuerID: "lu"}); err != nil {
+		t.Fatal(err)
+	}
+
+	dispatcher := NewWebhookDispatcher(store, keys)
+	dispatcher.initialBackoff, dispatcher.maxBackoff = time.Millisecond, time.Millisecond
+	dispatcher.Dispatch(testEvent())
+	waitFor(t, "dead letter", func() bool { return len(store.DeadLetters(webhookOwner{IssuerID: "lu"})) == 1 })
+
+	if got := len(received()); got != 0 {
+		t.Errorf("loopback subscriber received %d deliveries", got)
+	}
+	if letter := store.DeadLetters(webhookOwner{IssuerID: "lu"})[0]; !strings.Contains(letter.LastError, errWebhookAddress.Error()) {
+		t.Errorf("LastError = %q, want %q", letter.LastError, errWebhookAddress)
+	}
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	tests := []struct {
+		url string
+		ok  bool
+	}{
+		{"https://8.8.8.8/hook", true},
+		{"http://[2001:4860:4860::8888]:8080/hook", true},
+		{"ftp://8.8.8.8/hook", false},
+		{"https:///hook", false},
+		{"http://127.0.0.1:8080/hook", false},
+		{"http://localhost/hook", false},
+		{"http://[::1]/hook", false},
+		{"http://0.0.0.0/hook", false},
+		{"http://10.1.2.3/hook", false},
+		{"http://172.16.0.1/hook", false},
+		{"http://192.168.1.1/hook", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://[fe80::1]/hook", false},
+		{"http://[fd00::1]/hook", false},
+		{"http://[::ffff:127.0.0.1]/hook", false},
+	}
+	for _, tt := range tests {
+		if err := validateWebhookURL(tt.url); (err == nil) != tt.ok {
+			t.Errorf("validateWebhookURL(%q) = %v, want ok %v", tt.url, err, tt.ok)
+		}
+	}
+}
+
+// TestWebhookRoutesAreScopedToOwner subscribes through the gateway with API keys: keys with the
+// webhooks scope manage their own subscriptions to credentials of their issuer
+func TestWebhookRoutesAreScopedToOwner(t *testing.T) {
+	g := newMemoryGateway(t)
+	newKey := func(issuerID string, scopes ...string) string {
+		_, key, err := g.apiKeys.Create(issuerID, CreateAPIKeyRequest{Name: "test", Scopes: scopes}, "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	manager := newKey("lu", scopeList, scopeWebhooks)
+	verifier := newKey("lu", scopeWebhooks)
+	otherVerifier := newKey("lu", scopeWebhooks)
+	rtu := newKey("rtu", scopeIssue, scopeWebhooks)
+
+	luCredential := NewCredentialID("lu", testDiplomaHash, testPublicKey)
+	if w := g.call(g.luKey, http.MethodPost, "/credential", credentialBody("lu", testDiplomaHash, "")); w.Code != http.StatusCreated {
+		t.Fatalf("create lu credential: %d %s", w.Code, w.Body)
+	}
+	rtuCredential := NewCredentialID("rtu", testDiplomaHash, testPublicKey)
+	if w := g.call(rtu, http.MethodPost, "/credential", credentialBody("rtu", testDiplomaHash, "")); w.Code != http.StatusCreated {
+		t.Fatalf("create rtu credential: %d %s", w.Code, w.Body)
+	}
+
+	subscribe := func(key string, body string) *httptest.ResponseRecorder {
+		return g.call(key, http.MethodPost, "/webhooks", body)
+	}
+	w := subscribe(verifier, `{"url":"https://8.8.8.8/hook","credentialId":"`+luCredential+`"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("verifier subscribes to a credential: %d %s", w.Code, w.Body)
+	}
+	var sub WebhookSubscription
+	json.Unmarshal(w.Body.Bytes(), &sub)
+	if sub.Owner != "lu" || sub.APIKeyID == "" || sub.Secret == "" {
+		t.Fatalf("unexpected subscription %+v", sub)
+	}
+	if w := subscribe(manager, `{"url":"https://8.8.8.8/hook","issuerId":"lu"}`); w.Code != http.StatusCreated {
+		t.Fatalf("key with the list scope subscribes to its issuer: %d %s", w.Code, w.Body)
+	}
+
+	refused := []struct {
+		name string
+		key  string
+		body string
+		code int
+	}{
+		{"key without the webhooks scope", g.luKey, `{"url":"https://8.8.8.8/hook","credentialId":"` + luCredential + `"}`, http.StatusForbidden},
+		{"issuer without the list scope", verifier, `{"url":"https://8.8.8.8/hook","issuerId":"lu"}`, http.StatusForbidden},
+		{"other issuer", manager, `{"url":"https://8.8.8.8/hook","issuerId":"rtu"}`, http.StatusForbidden},
+		{"credential of another issuer", verifier, `{"url":"https://8.8.8.8/hook","credentialId":"` + rtuCredential + `"}`, http.StatusForbidden},
+		{"unknown credential", verifier, `{"url":"https://8.8.8.8/hook","credentialId":"lu-unknown"}`, http.StatusNotFound},
+		{"loopback callback", verifier, `{"url":"http://127.0.0.1/hook","credentialId":"` + luCredential + `"}`, http.StatusBadRequest},
+	}
+	for _, tt := range refused {
+		if w := subscribe(tt.key, tt.body); w.Code != tt.code {
+			t.Errorf("%s: %d %s, want %d", tt.name, w.Code, w.Body, tt.code)
+		}
+	}
+
+	// Keys only see the subscriptions they created, issuer users all of their issuer's
+	for key, count := range map[string]int{verifier: 1, otherVerifier: 0, manager: 1, rtu: 0} {
+		var listed struct {
+			Count int `json:"count"`
+		}
+		json.Unmarshal(g.call(key, http.MethodGet, "/webhooks", "").Body.Bytes(), &listed)
+		if listed.Count != count {
+			t.Errorf("key lists %d subscriptions, want %d", listed.Count, count)
+		}
+	}
+	if subs := g.webhooks.List(webhookOwner{IssuerID: "lu"}); len(subs) != 2 {
+		t.Errorf("issuer users see %d subscriptions, want 2", len(subs))
+	}
+
+	for _, key := range []string{otherVerifier, manager, rtu} {
+		if w := g.call(key, http.MethodGet, "/webhooks/"+sub.ID, ""); w.Code != http.StatusNotFound {
+			t.Errorf("another key reads: %d, want 404", w.Code)
+		}
+		if w := g.call(key, http.MethodDelete, "/webhooks/"+sub.ID, ""); w.Code != http.StatusNotFound {
+			t.Errorf("another key deletes: %d, want 404", w.Code)
+		}
+	}
+
+	if w := g.call(verifier, http.MethodGet, "/webhooks/"+sub.ID, ""); w.Code != http.StatusOK || strings.Contains(w.Body.String(), sub.Secret) {
+		t.Errorf("owner reads: %d %s", w.Code, w.Body)
+	}
+	if w := g.call(verifier, http.MethodDelete, "/webhooks/"+sub.ID, ""); w.Code != http.StatusNoContent {
+		t.Errorf("owner deletes: %d, want 204", w.Code)
+	}
+}
+
+func TestWebhookSubscriptionsOfRevokedKeysGetNoDeliveries(t *testing.T) {
+	server, received := webhookSubscriber(t, http.StatusOK)
+	store, _ := LoadWebhookStore("")
+	keys, _ := LoadAPIKeyStore("")
+	key, _, err := keys.Create("lu", CreateAPIKeyRequest{Scopes: []string{scopeWebhooks}}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := store.Create(webhookOwner{IssuerID: "lu", APIKeyID: key.ID}, CreateWebhookRequest{URL: server.URL, CredentialID: "lu-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dispatcher := testDispatcher(store, keys, server)
+	dispatcher.Dispatch(testEvent())
+	waitFor(t, "delivery", func() bool { return len(received()) == 1 })
+	if got := received()[0].header.Get("X-Webhook-Id"); got != sub.ID {
+		t.Fatalf("delivered to %s, want %s", got, sub.ID)
+	}
+
+	if err := keys.Revoke(key.ID); err != nil {
+		t.Fatal(err)
+	}
+	dispatcher.Dispatch(testEvent())
+	time.Sleep(50 * time.Millisecond)
+	if got := len(received()); got != 1 {
+		t.Errorf("subscription of a revoked key received %d deliveries, want 1", got)
+	}
+}
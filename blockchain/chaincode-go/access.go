@@ -0,0 +1,110 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// Certificate attributes read from the submitting client's enrollment certificate
+const IssuerIDAttribute = "issuerId"
+const RoleAttribute = "role"
+const AdminRole = "admin"
+
+// Caller describes the client identity that submitted the current transaction
+type Caller struct {
+	ID       string
+	MSPID    string
+	IssuerID string
+	Admin    bool
+}
+
+// getCaller resolves the submitting client from its certificate.
+// Admins are identified either by the role=admin attribute issued by the CA
+// or by the "admin" node OU that cryptogen puts on organization admins.
+func getCaller(ctx contractapi.TransactionContextInterface) (*Caller, error) {
+	identity := ctx.GetClientIdentity()
+
+	id, err := identity.GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client identity: %v", err)
+	}
+
+	mspID, err := identity.GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client MSP ID: %v", err)
+	}
+
+	issuerID, _, err := identity.GetAttributeValue(IssuerIDAttribute)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s attribute: %v", IssuerIDAttribute, err)
+	}
+
+	role, _, err := identity.GetAttributeValue(RoleAttribute)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s attribute: %v", RoleAttribute, err)
+	}
+
+	admin := role == AdminRole
+	if !admin {
+		cert, err := identity.GetX509Certificate()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client certificate: %v", err)
+		}
+		if cert != nil {
+			for _, ou := range cert.Subject.OrganizationalUnit {
+				if ou == AdminRole {
+					admin = true
+					break
+				}
+			}
+		}
+	}
+
+	return &Caller{
+		ID:       id,
+		MSPID:    mspID,
+		IssuerID: issuerID,
+		Admin:    admin,
+	}, nil
+}
+
+// assertAdmin rejects the transaction unless it was submitted by a consortium admin
+func assertAdmin(ctx contractapi.TransactionContextInterface) error {
+	caller, err := getCaller(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !caller.Admin {
+		return fmt.Errorf("client %s is not authorized to manage issuers", caller.MSPID)
+	}
+
+	return nil
+}
+
+// assertIssuerOwner rejects the transaction unless the caller acts on behalf of the given issuer
+func assertIssuerOwner(ctx contractapi.TransactionContextInterface, issuer *Issuer) error {
+	caller, err := getCaller(ctx)
+	if err != nil {
+		return err
+	}
+
+	if caller.IssuerID == "" {
+		return fmt.Errorf("client certificate has no %s attribute", IssuerIDAttribute)
+	}
+
+	if caller.IssuerID != issuer.ID {
+		return fmt.Errorf("client for issuer %s is not authorized to act for issuer %s", caller.IssuerID, issuer.ID)
+	}
+
+	if issuer.MSPID != "" && caller.MSPID != issuer.MSPID {
+		return fmt.Errorf("issuer %s belongs to %s, not %s", issuer.ID, issuer.MSPID, caller.MSPID)
+	}
+
+	return nil
+}
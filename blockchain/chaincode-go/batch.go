@@ -0,0 +1,125 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// MaxBatchSize bounds how many credentials one CreateCredentialsBatch transaction issues
+const MaxBatchSize = 100
+
+// BatchRowResult reports the outcome of one credential in a batch. Row is the 0-based position in the batch.
+type BatchRowResult struct {
+	Row          int    `json:"row"`
+	CredentialID string `json:"credentialId,omitempty" metadata:",optional"` // Set when the credential was created
+	Error        string `json:"error,omitempty" metadata:",optional"`        // Set when the row was rejected
+}
+
+// BatchResult is the per-row report of CreateCredentialsBatch
+type BatchResult struct {
+	IssuerID string            `json:"issuerId"`
+	Created  int               `json:"created"`
+	Failed   int               `json:"failed"`
+	Rows     []*BatchRowResult `json:"rows"`
+}
+
+// CreateCredentialsBatch issues up to MaxBatchSize credentials of one issuer in a single transaction.
+// Rows are validated like CreateCredential. Rejected rows are reported and skipped while the valid rows
+// are still written, so a failed row never blocks the rest of the batch. The transaction emits one
+// CredentialsBatchIssued event listing the created IDs.
+func (s *SmartContract) CreateCredentialsBatch(ctx contractapi.TransactionContextInterface, credentialsJSON string) (*BatchResult, error) {
+	var credentials []*Credential
+	if err := json.Unmarshal([]byte(credentialsJSON), &credentials); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal credentials: %v", err)
+	}
+
+	if len(credentials) == 0 {
+		return nil, fmt.Errorf("the batch is empty")
+	}
+	if len(credentials) > MaxBatchSize {
+		return nil, fmt.Errorf("the batch holds %d credentials, at most %d are allowed", len(credentials), MaxBatchSize)
+	}
+
+	// A batch belongs to one issuer, the first row that is not empty names it
+	firstRow := slices.IndexFunc(credentials, func(credential *Credential) bool { return credential != nil })
+	if firstRow < 0 {
+		return nil, fmt.Errorf("every row of the batch is empty")
+	}
+	issuer, err := s.readActiveIssuer(ctx, credentials[firstRow].IssuerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := assertIssuerOwner(ctx, issuer); err != nil {
+		return nil, err
+	}
+
+	result := &BatchResult{IssuerID: issuer.ID, Rows: make([]*BatchRowResult, 0, len(credentials))}
+	createdIDs := []string{}
+
+	// Writes of this transaction are not visible to its own reads, duplicates inside the batch are tracked here
+	seen := map[string]int{}
+
+	for row, credential := range credentials {
+		rowResult := &BatchRowResult{Row: row}
+		result.Rows = append(result.Rows, rowResult)
+
+		if credential == nil {
+			rowResult.Error = "row is empty"
+			result.Failed++
+			continue
+		}
+
+		if credential.IssuerID != issuer.ID {
+			rowResult.Error = fmt.Sprintf("issuerId must be %s for every row of the batch", issuer.ID)
+			result.Failed++
+			continue
+		}
+
+		derivedID := NewCredentialID(credential.IssuerID, credential.DiplomaHash, credential.GraduatePublicKey)
+		if first, ok := seen[derivedID]; ok {
+			rowResult.Error = fmt.Sprintf("duplicate of row %d", first)
+			result.Failed++
+			continue
+		}
+
+		id, err := s.issueCredential(ctx, issuer, credential)
+		if err != nil {
+			rowResult.Error = err.Error()
+			result.Failed++
+			continue
+		}
+		// Only stored rows count, a later row with the ID of a rejected one may still be valid
+		seen[derivedID] = row
+
+		rowResult.CredentialID = id
+		createdIDs = append(createdIDs, id)
+		result.Created++
+	}
+
+	if len(createdIDs) > 0 {
+		timestamp, err := txTimestamp(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		err = setEvent(ctx, EventCredentialsBatchIssued, CredentialBatchEvent{
+			CredentialIDs: createdIDs,
+			IssuerID:      issuer.ID,
+			Status:        StatusValid,
+			Timestamp:     timestamp,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,93 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// createBatch submits rows as CreateCredentialsBatch of issuer
+func (l *testLedger) createBatch(issuer *testIssuer, rows []*Credential) (*BatchResult, error) {
+	l.t.Helper()
+	rowsJSON, err := json.Marshal(rows)
+	if err != nil {
+		l.t.Fatal(err)
+	}
+	var result *BatchResult
+	err = l.invoke(issuer.client, func(ctx contractapi.TransactionContextInterface) (err error) {
+		result, err = l.contract.CreateCredentialsBatch(ctx, string(rowsJSON))
+		return err
+	})
+	return result, err
+}
+
+func TestCreateCredentialsBatch(t *testing.T) {
+	ledger := newTestLedger(t)
+	lu := ledger.addIssuer("lu", "Org1MSP")
+	rtu := ledger.addIssuer("rtu", "Org2MSP")
+
+	// An empty first row does not decide the issuer of the batch
+	result, err := ledger.createBatch(lu, []*Credential{
+		nil,
+		lu.newTestCredential(t, 1),
+		rtu.newTestCredential(t, 2),
+		lu.newTestCredential(t, 1),
+		lu.newTestCredential(t, 3),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IssuerID != "lu" || result.Created != 2 || result.Failed != 3 {
+		t.Fatalf("issuer %s, created %d, failed %d", result.IssuerID, result.Created, result.Failed)
+	}
+
+	rowErrors := []string{"row is empty", "", "issuerId must be lu", "duplicate of row 1", ""}
+	for i, want := range rowErrors {
+		row := result.Rows[i]
+		if want == "" {
+			if row.Error != "" || row.CredentialID == "" {
+				t.Errorf("row %d: %+v", i, row)
+			} else if ledger.credential(row.CredentialID).IssuerID != "lu" {
+				t.Errorf("row %d created %s for another issuer", i, row.CredentialID)
+			}
+			continue
+		}
+		if !strings.Contains(row.Error, want) || row.CredentialID != "" {
+			t.Errorf("row %d: %+v, want error %q", i, row, want)
+		}
+	}
+	if ledger.event.name != EventCredentialsBatchIssued {
+		t.Errorf("event %s", ledger.event.name)
+	}
+}
+
+func TestCreateCredentialsBatchRejected(t *testing.T) {
+	ledger := newTestLedger(t)
+	lu := ledger.addIssuer("lu", "Org1MSP")
+	rtu := ledger.addIssuer("rtu", "Org2MSP")
+
+	tests := []struct {
+		name   string
+		caller *testIssuer
+		rows   []*Credential
+		err    string
+	}{
+		{"empty", lu, []*Credential{}, "the batch is empty"},
+		{"only empty rows", lu, []*Credential{nil, nil}, "every row of the batch is empty"},
+		{"too large", lu, make([]*Credential, MaxBatchSize+1), "at most 100 are allowed"},
+		{"other issuer", rtu, []*Credential{nil, lu.newTestCredential(t, 1)}, "not authorized to act for issuer lu"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ledger.createBatch(tt.caller, tt.rows); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// Hash schemes of Credential.DiplomaHash
+const (
+	// Plain SHA-256 of the diploma file, the default for credentials without a scheme
+	HashSchemeSHA256 = "sha256"
+	// HMAC-SHA256 keyed with a per-credential salt over the hex SHA-256 of the diploma file.
+	// The salt stays with the issuer and the graduate, so the file alone cannot be looked up.
+	HashSchemeHMACSHA256 = "hmac-sha256"
+)
+
+// validateHashScheme checks the scheme is known and the diploma hash is a hex SHA-256 sized digest
+func validateHashScheme(credential *Credential) error {
+	switch credential.HashScheme {
+	case "", HashSchemeSHA256:
+		return nil
+	case HashSchemeHMACSHA256:
+		digest, err := hex.DecodeString(credential.DiplomaHash)
+		if err != nil || len(digest) != 32 {
+			return fmt.Errorf("diplomaHash must be a hex encoded HMAC-SHA256 commitment")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown hash scheme %q, expected %s or %s", credential.HashScheme, HashSchemeSHA256, HashSchemeHMACSHA256)
+	}
+}
@@ -0,0 +1,173 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// Alias keys map a credential ID from before the migration to its current ID.
+// The prefix sorts outside the CREDENTIAL_ range so aliases never show up in credential queries.
+const CredentialAliasKey = "ALIAS_CREDENTIAL_"
+
+// NewCredentialID derives the ID of a credential from the issuer namespace and the full
+// SHA-256 of issuer, diploma hash and graduate key, e.g. "lu-3f1a...". The same diploma
+// can be issued to different graduates, and IDs do not collide across issuers.
+func NewCredentialID(issuerID string, diplomaHash string, graduatePublicKey string) string {
+	h := sha256.New()
+	h.Write([]byte(issuerID))
+	h.Write([]byte{0})
+	h.Write([]byte(diplomaHash))
+	h.Write([]byte{0})
+	h.Write([]byte(graduatePublicKey))
+	return issuerID + "-" + hex.EncodeToString(h.Sum(nil))
+}
+
+// isNamespacedCredentialID reports whether id has the form produced by NewCredentialID for the issuer
+func isNamespacedCredentialID(id string, issuerID string) bool {
+	digest, ok := strings.CutPrefix(id, issuerID+"-")
+	if !ok || len(digest) != 2*sha256.Size {
+		return false
+	}
+	_, err := hex.DecodeString(digest)
+	return err == nil
+}
+
+// issuerCredentialRange returns the key range of an issuer's credentials with IDs from
+// NewCredentialID, starting after the credential ID cursor, or at the first one when it is empty.
+// The range ends at the issuer ID followed by '.', the character after '-'.
+func issuerCredentialRange(issuerID string, cursor string) (string, string) {
+	startKey := CredentialKey + issuerID + "-"
+	if cursor != "" {
+		startKey = CredentialKey + cursor + "\x00"
+	}
+	return startKey, CredentialKey + issuerID + "."
+}
+
+// credentialID returns the ledger ID of a stored credential without the key prefix
+func credentialID(credential *Credential) string {
+	return strings.TrimPrefix(credential.ID, CredentialKey)
+}
+
+// resolveCredentialAlias returns the current ID an old credential ID was migrated to, or an empty string
+func resolveCredentialAlias(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	target, err := ctx.GetStub().GetState(CredentialAliasKey + id)
+	if err != nil {
+		return "", fmt.Errorf("failed to read from world state: %v", err)
+	}
+	return string(target), nil
+}
+
+// CredentialAlias links a migrated credential's old ID to its new one
+type CredentialAlias struct {
+	OldID string `json:"oldId"`
+	NewID string `json:"newId"`
+}
+
+// CredentialMigration reports one chunk of MigrateCredentialIDs.
+// An empty cursor means every credential has been visited.
+type CredentialMigration struct {
+	Migrated  []*CredentialAlias `json:"migrated"`
+	Conflicts []*CredentialAlias `json:"conflicts"` // Not migrated, NewID is already taken
+	Scanned   int32              `json:"scanned"`
+	Cursor    string             `json:"cursor"` // Last credential ID scanned, where the next call starts after
+}
+
+// MigrateCredentialIDs re-keys up to pageSize credentials with legacy IDs to NewCredentialID,
+// starting after the cursor. Old IDs stay resolvable through alias keys and the record keeps its
+// old ID as legacyId. Call repeatedly with the returned cursor until it is empty.
+func (s *SmartContract) MigrateCredentialIDs(ctx contractapi.TransactionContextInterface, pageSize int32, cursor string) (*CredentialMigration, error) {
+	if err := assertAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	if pageSize > MaxPageSize {
+		pageSize = MaxPageSize
+	}
+
+	// Pagination is not available in update transactions, so the range starts right after the
+	// cursor key, a zero byte being the smallest possible suffix, and is cut off after pageSize keys
+	startKey := CredentialKey
+	if cursor != "" {
+		startKey = CredentialKey + cursor + "\x00"
+	}
+	resultsIterator, err := ctx.GetStub().GetStateByRange(startKey, CredentialKeyRangeEnd)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	migration := &CredentialMigration{
+		Migrated:  []*CredentialAlias{},
+		Conflicts: []*CredentialAlias{},
+	}
+
+	for migration.Scanned < pageSize && resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		migration.Scanned++
+		migration.Cursor = strings.TrimPrefix(queryResponse.Key, CredentialKey)
+
+		var credential Credential
+		if err := json.Unmarshal(queryResponse.Value, &credential); err != nil {
+			return nil, err
+		}
+
+		oldID := strings.TrimPrefix(queryResponse.Key, CredentialKey)
+		if isNamespacedCredentialID(oldID, credential.IssuerID) {
+			continue
+		}
+
+		alias := &CredentialAlias{
+			OldID: oldID,
+			NewID: NewCredentialID(credential.IssuerID, credential.DiplomaHash, credential.GraduatePublicKey),
+		}
+
+		existing, err := ctx.GetStub().GetState(CredentialKey + alias.NewID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read from world state: %v", err)
+		}
+		if existing != nil {
+			migration.Conflicts = append(migration.Conflicts, alias)
+			continue
+		}
+
+		if err := ctx.GetStub().DelState(queryResponse.Key); err != nil {
+			return nil, err
+		}
+		if err := updateCredentialIndexes(ctx, oldID, &credential, nil); err != nil {
+			return nil, err
+		}
+
+		credential.ID = CredentialKey + alias.NewID
+		credential.LegacyID = oldID
+		if err := s.putCredential(ctx, alias.NewID, &credential); err != nil {
+			return nil, err
+		}
+
+		if err := ctx.GetStub().PutState(CredentialAliasKey+oldID, []byte(alias.NewID)); err != nil {
+			return nil, err
+		}
+
+		migration.Migrated = append(migration.Migrated, alias)
+	}
+
+	// Migrated credentials are written under keys later in the range, they are skipped when reached
+	if !resultsIterator.HasNext() {
+		migration.Cursor = ""
+	}
+	return migration, nil
+}
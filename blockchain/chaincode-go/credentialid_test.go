@@ -0,0 +1,227 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+func TestNewCredentialID(t *testing.T) {
+	id := NewCredentialID("lu", testDiplomaHash(1), "graduate 1")
+	if id != NewCredentialID("lu", testDiplomaHash(1), "graduate 1") {
+		t.Fatal("ID is not deterministic")
+	}
+	if !isNamespacedCredentialID(id, "lu") {
+		t.Errorf("%s is not namespaced for lu", id)
+	}
+
+	// Every input takes part in the ID, and the separators keep shifted inputs apart
+	others := map[string]string{
+		"issuer":       NewCredentialID("rtu", testDiplomaHash(1), "graduate 1"),
+		"diploma hash": NewCredentialID("lu", testDiplomaHash(2), "graduate 1"),
+		"graduate key": NewCredentialID("lu", testDiplomaHash(1), "graduate 2"),
+		"shifted":      NewCredentialID("l", "u"+testDiplomaHash(1), "graduate 1"),
+	}
+	for name, other := range others {
+		if other == id {
+			t.Errorf("another %s gives the same ID %s", name, id)
+		}
+	}
+
+	tests := []struct {
+		id       string
+		issuerID string
+		want     bool
+	}{
+		{id, "lu", true},
+		{id, "rtu", false},
+		{id, "l", false},
+		{"credential1", "lu", false},
+		{"lu-" + strings.Repeat("a", 63), "lu", false},
+		{"lu-" + strings.Repeat("g", 64), "lu", false},
+	}
+	for _, tt := range tests {
+		if got := isNamespacedCredentialID(tt.id, tt.issuerID); got != tt.want {
+			t.Errorf("isNamespacedCredentialID(%s, %s) = %v, want %v", tt.id, tt.issuerID, got, tt.want)
+		}
+	}
+}
+
+func TestCreateCredentialDerivesID(t *testing.T) {
+	ledger := newTestLedger(t)
+	lu := ledger.addIssuer("lu", "Org1MSP")
+
+	id := ledger.issue(lu, 1)
+	if want := NewCredentialID("lu", testDiplomaHash(1), "graduate 1"); id != want {
+		t.Fatalf("ID %s, want %s", id, want)
+	}
+	if credential := ledger.credential(id); credential.ID != CredentialKey+id || credential.LegacyID != "" {
+		t.Errorf("stored as %s with legacy ID %q", credential.ID, credential.LegacyID)
+	}
+}
+
+// legacyCredential returns credential n of issuer under an ID from before NewCredentialID
+func legacyCredential(t *testing.T, issuer *testIssuer, n int) Credential {
+	credential := issuer.newTestCredential(t, n)
+	credential.ID = fmt.Sprintf("credential%d", n)
+	credential.Status = StatusValid
+	return *credential
+}
+
+// migrate calls MigrateCredentialIDs with pageSize until the cursor is empty and returns every chunk
+func (l *testLedger) migrate(pageSize int32) []*CredentialMigration {
+	l.t.Helper()
+	var chunks []*CredentialMigration
+	cursor := ""
+	for {
+		var migration *CredentialMigration
+		l.mustInvoke(org1Admin, func(ctx contractapi.TransactionContextInterface) (err error) {
+			migration, err = l.contract.MigrateCredentialIDs(ctx, pageSize, cursor)
+			return err
+		})
+		chunks = append(chunks, migration)
+		if migration.Cursor == "" {
+			return chunks
+		}
+		if migration.Scanned != pageSize {
+			l.t.Fatalf("chunk with cursor %s scanned %d credentials, want %d", migration.Cursor, migration.Scanned, pageSize)
+		}
+		if len(chunks) > 10 {
+			l.t.Fatal("migration does not finish")
+		}
+		cursor = migration.Cursor
+	}
+}
+
+func TestMigrateCredentialIDs(t *testing.T) {
+	ledger := newTestLedger(t)
+	lu := ledger.addIssuer("lu", "Org1MSP")
+	rtu := ledger.addIssuer("rtu", "Org2MSP")
+	for n := 1; n <= 3; n++ {
+		ledger.putLegacyCredential(legacyCredential(t, lu, n))
+	}
+	ledger.putLegacyCredential(legacyCredential(t, rtu, 4))
+	current := ledger.issue(lu, 5)
+
+	chunks := ledger.migrate(2)
+	if len(chunks) < 3 {
+		t.Errorf("migrated in %d chunks of 2 credentials", len(chunks))
+	}
+	migrated := map[string]string{}
+	for _, chunk := range chunks {
+		if len(chunk.Conflicts) != 0 {
+			t.Errorf("unexpected conflicts %+v", chunk.Conflicts)
+		}
+		for _, alias := range chunk.Migrated {
+			if _, ok := migrated[alias.OldID]; ok {
+				t.Errorf("%s migrated twice", alias.OldID)
+			}
+			migrated[alias.OldID] = alias.NewID
+		}
+	}
+	if len(migrated) != 4 {
+		t.Fatalf("migrated %v, want the 4 legacy credentials", migrated)
+	}
+
+	for n, issuer := range map[int]string{1: "lu", 2: "lu", 3: "lu", 4: "rtu"} {
+		oldID := fmt.Sprintf("credential%d", n)
+		newID := NewCredentialID(issuer, testDiplomaHash(n), fmt.Sprintf("graduate %d", n))
+		if migrated[oldID] != newID {
+			t.Errorf("%s migrated to %s, want %s", oldID, migrated[oldID], newID)
+		}
+		if _, ok := ledger.state[CredentialKey+oldID]; ok {
+			t.Errorf("%s is still stored under its old key", oldID)
+		}
+
+		// The old ID resolves through its alias
+		credential := ledger.credential(oldID)
+		if credential.ID != CredentialKey+newID || credential.LegacyID != oldID || credential.DiplomaHash != testDiplomaHash(n) {
+			t.Errorf("%s reads %s with legacy ID %s", oldID, credential.ID, credential.LegacyID)
+		}
+		if ledger.credential(newID).LegacyID != oldID {
+			t.Errorf("%s lost its legacy ID", newID)
+		}
+
+		if ledger.indexed(IssuerCredentialIndex, issuer, oldID) || !ledger.indexed(IssuerCredentialIndex, issuer, newID) {
+			t.Errorf("%s index still points at %s", IssuerCredentialIndex, oldID)
+		}
+		if ledger.indexed(StatusCredentialIndex, StatusValid, oldID) || !ledger.indexed(StatusCredentialIndex, StatusValid, newID) {
+			t.Errorf("%s index still points at %s", StatusCredentialIndex, oldID)
+		}
+	}
+	if ledger.credential(current).LegacyID != "" {
+		t.Errorf("credential with a current ID was migrated")
+	}
+
+	// A second run finds nothing left to migrate
+	for _, chunk := range ledger.migrate(10) {
+		if len(chunk.Migrated) != 0 {
+			t.Errorf("migrated %+v again", chunk.Migrated)
+		}
+	}
+}
+
+func TestMigrateCredentialIDsConflict(t *testing.T) {
+	ledger := newTestLedger(t)
+	lu := ledger.addIssuer("lu", "Org1MSP")
+	// The same diploma was issued to the same graduate under a current ID as well
+	current := ledger.issue(lu, 1)
+	ledger.putLegacyCredential(legacyCredential(t, lu, 1))
+
+	chunks := ledger.migrate(0)
+	if len(chunks) != 1 {
+		t.Fatalf("%d chunks with the default page size", len(chunks))
+	}
+	migration := chunks[0]
+	if len(migration.Migrated) != 0 || len(migration.Conflicts) != 1 {
+		t.Fatalf("migrated %+v, conflicts %+v", migration.Migrated, migration.Conflicts)
+	}
+	if conflict := migration.Conflicts[0]; conflict.OldID != "credential1" || conflict.NewID != current {
+		t.Errorf("conflict %+v", conflict)
+	}
+
+	// Both records stay where they were, and the old ID does not resolve to the other credential
+	if credential := ledger.credential("credential1"); credential.ID != "credential1" {
+		t.Errorf("credential1 reads %s", credential.ID)
+	}
+	if _, ok := ledger.state[CredentialAliasKey+"credential1"]; ok {
+		t.Error("alias written for a conflicting credential")
+	}
+	if credential := ledger.credential(current); credential.LegacyID != "" {
+		t.Errorf("%s was overwritten by credential1", current)
+	}
+}
+
+func TestMigrateCredentialIDsOnlyByAdmins(t *testing.T) {
+	ledger := newTestLedger(t)
+	lu := ledger.addIssuer("lu", "Org1MSP")
+	ledger.putLegacyCredential(legacyCredential(t, lu, 1))
+
+	err := ledger.invoke(lu.client, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := ledger.contract.MigrateCredentialIDs(ctx, 0, "")
+		return err
+	})
+	if err == nil {
+		t.Fatal("issuer migrated credential IDs")
+	}
+	if credential := ledger.credential("credential1"); credential.LegacyID != "" {
+		t.Errorf("credential1 was migrated")
+	}
+}
+
+func TestReadCredentialUnknownID(t *testing.T) {
+	ledger := newTestLedger(t)
+	err := ledger.invoke(testReader, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := ledger.contract.ReadCredential(ctx, "credential1")
+		return err
+	})
+	if err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("reading an unknown credential: %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// Chaincode event names. Fabric keeps only the last event set by a transaction,
+// so every transaction emits at most one of these.
+const EventCredentialIssued = "CredentialIssued"
+const EventCredentialsBatchIssued = "CredentialsBatchIssued"
+const EventCredentialRevoked = "CredentialRevoked"
+const EventCredentialSuspended = "CredentialSuspended"
+const EventCredentialReinstated = "CredentialReinstated"
+const EventIssuerRevoked = "IssuerRevoked"
+const EventIssuerCredentialsRevoked = "IssuerCredentialsRevoked"
+const EventIssuerKeyRotated = "IssuerKeyRotated"
+const EventIssuerAssertionKeyRotated = "IssuerAssertionKeyRotated"
+const EventIssuerProposed = "IssuerProposed"
+const EventIssuerProposalVoted = "IssuerProposalVoted"
+const EventIssuerApproved = "IssuerApproved"
+const EventIssuerProposalRejected = "IssuerProposalRejected"
+const EventIssuerProposalWithdrawn = "IssuerProposalWithdrawn"
+
+// CredentialEvent is the payload of credential lifecycle events
+type CredentialEvent struct {
+	CredentialID string      `json:"credentialId"`
+	IssuerID     string      `json:"issuerId"`
+	Status       string      `json:"status"`
+	Timestamp    string      `json:"timestamp"` // RFC 3339 timestamp of the emitting transaction
+	Revocation   *Revocation `json:"revocation,omitempty"`
+}
+
+// CredentialBatchEvent is the payload of EventCredentialsBatchIssued, listing every credential the batch created
+type CredentialBatchEvent struct {
+	CredentialIDs []string `json:"credentialIds"`
+	IssuerID      string   `json:"issuerId"`
+	Status        string   `json:"status"`
+	Timestamp     string   `json:"timestamp"` // RFC 3339 timestamp of the emitting transaction
+}
+
+// IssuerEvent is the payload of issuer lifecycle events
+type IssuerEvent struct {
+	IssuerID  string `json:"issuerId"`
+	Status    string `json:"status"`
+	KeyID     string `json:"keyId,omitempty"` // New key of EventIssuerKeyRotated and EventIssuerAssertionKeyRotated
+	Timestamp string `json:"timestamp"`       // RFC 3339 timestamp of the emitting transaction
+}
+
+// IssuerCredentialsRevokedEvent is the payload of EventIssuerCredentialsRevoked, listing the
+// credentials one chunk of an issuer revocation revoked
+type IssuerCredentialsRevokedEvent struct {
+	IssuerID      string   `json:"issuerId"`
+	CredentialIDs []string `json:"credentialIds"`
+	Status        string   `json:"status"`
+	Complete      bool     `json:"complete"` // The last chunk of the revocation
+	Timestamp     string   `json:"timestamp"`
+}
+
+// IssuerProposalEvent is the payload of issuer proposal events
+type IssuerProposalEvent struct {
+	ProposalID string `json:"proposalId"`
+	IssuerID   string `json:"issuerId"`
+	Status     string `json:"status"`
+	Approvals  int    `json:"approvals"`
+	Rejections int    `json:"rejections"`
+	Threshold  int    `json:"threshold"`
+	Timestamp  string `json:"timestamp"` // RFC 3339 timestamp of the emitting transaction
+}
+
+// emitCredentialEvent sets a credential lifecycle event on the current transaction
+func emitCredentialEvent(ctx contractapi.TransactionContextInterface, name string, id string, credential *Credential) error {
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	return setEvent(ctx, name, CredentialEvent{
+		CredentialID: id,
+		IssuerID:     credential.IssuerID,
+		Status:       credential.Status,
+		Timestamp:    timestamp,
+		Revocation:   credential.Revocation,
+	})
+}
+
+// emitIssuerEvent sets an issuer lifecycle event on the current transaction
+func emitIssuerEvent(ctx contractapi.TransactionContextInterface, name string, issuer *Issuer) error {
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	return setEvent(ctx, name, IssuerEvent{
+		IssuerID:  issuer.ID,
+		Status:    issuer.Status,
+		Timestamp: timestamp,
+	})
+}
+
+// emitProposalEvent sets an issuer proposal event on the current transaction
+func emitProposalEvent(ctx contractapi.TransactionContextInterface, name string, proposal *IssuerProposal) error {
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	approvals, rejections := proposal.Tally()
+	return setEvent(ctx, name, IssuerProposalEvent{
+		ProposalID: proposal.ID,
+		IssuerID:   proposal.Issuer.ID,
+		Status:     proposal.Status,
+		Approvals:  approvals,
+		Rejections: rejections,
+		Threshold:  proposal.Threshold,
+		Timestamp:  timestamp,
+	})
+}
+
+func setEvent(ctx contractapi.TransactionContextInterface, name string, payload interface{}) error {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %v", name, err)
+	}
+
+	if err := ctx.GetStub().SetEvent(name, payloadBytes); err != nil {
+		return fmt.Errorf("failed to set %s event: %v", name, err)
+	}
+
+	return nil
+}
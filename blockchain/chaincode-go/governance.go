@@ -0,0 +1,490 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// Ledger keys of the governance settings and of issuer proposals
+const GovernanceKey = "GOVERNANCE"
+const IssuerProposalKey = "PROPOSAL_"
+const IssuerProposalKeyRangeEnd = "PROPOSAL_\uffff"
+
+// Issuer proposal states. Only pending proposals accept votes.
+const ProposalPending = "Pending"
+const ProposalApproved = "Approved"
+const ProposalRejected = "Rejected"
+const ProposalWithdrawn = "Withdrawn"
+
+// Issuer IDs prefix credential IDs, so they are kept short and URL safe
+var issuerIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_]{1,31}$`)
+
+// Governance decides who approves new issuers: admins of the listed organizations vote,
+// one vote per organization, and Threshold approvals activate the issuer
+type Governance struct {
+	AdminMSPIDs []string `json:"adminMspIds"`
+	Threshold   int      `json:"threshold"`
+}
+
+// IssuerProposal is a candidate issuer waiting for approval, with every vote cast on it
+type IssuerProposal struct {
+	ID            string         `json:"id"` // Transaction ID of the proposal
+	Issuer        Issuer         `json:"issuer"`
+	Status        string         `json:"status"`
+	ProposedBy    string         `json:"proposedBy"`
+	ProposerMSPID string         `json:"proposerMspId"`
+	ProposedAt    string         `json:"proposedAt"`
+	AdminMSPIDs   []string       `json:"adminMspIds"` // Governance when the proposal was made
+	Threshold     int            `json:"threshold"`
+	Votes         []ProposalVote `json:"votes"`
+	DecidedAt     string         `json:"decidedAt,omitempty" metadata:",optional"`
+}
+
+// ProposalVote records one organization's vote
+type ProposalVote struct {
+	AdminID string `json:"adminId"`
+	MSPID   string `json:"mspId"`
+	Approve bool   `json:"approve"`
+	Comment string `json:"comment,omitempty" metadata:",optional"`
+	VotedAt string `json:"votedAt"`
+	TxID    string `json:"txId"`
+}
+
+// Tally returns the number of approving and rejecting votes
+func (p *IssuerProposal) Tally() (approvals int, rejections int) {
+	for _, vote := range p.Votes {
+		if vote.Approve {
+			approvals++
+		} else {
+			rejections++
+		}
+	}
+	return approvals, rejections
+}
+
+// InitGovernance sets the organizations whose admins approve issuers and how many approvals are
+// needed. It can only be called once, InitLedger sets a default for the test network.
+func (s *SmartContract) InitGovernance(ctx contractapi.TransactionContextInterface, adminMSPIDsJSON string, threshold int) error {
+	if err := assertAdmin(ctx); err != nil {
+		return err
+	}
+
+	var adminMSPIDs []string
+	if err := json.Unmarshal([]byte(adminMSPIDsJSON), &adminMSPIDs); err != nil {
+		return fmt.Errorf("failed to unmarshal admin MSP IDs: %v", err)
+	}
+
+	return putGovernance(ctx, &Governance{AdminMSPIDs: adminMSPIDs, Threshold: threshold})
+}
+
+// GetGovernance returns the issuer approval settings
+func (s *SmartContract) GetGovernance(ctx contractapi.TransactionContextInterface) (*Governance, error) {
+	data, err := ctx.GetStub().GetState(GovernanceKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if data == nil {
+		return nil, fmt.Errorf("governance is not initialized, call InitGovernance")
+	}
+
+	var governance Governance
+	if err := json.Unmarshal(data, &governance); err != nil {
+		return nil, err
+	}
+	return &governance, nil
+}
+
+func putGovernance(ctx contractapi.TransactionContextInterface, governance *Governance) error {
+	existing, err := ctx.GetStub().GetState(GovernanceKey)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("governance is already initialized")
+	}
+
+	if len(governance.AdminMSPIDs) == 0 {
+		return fmt.Errorf("at least one admin MSP ID is required")
+	}
+	for i, mspID := range governance.AdminMSPIDs {
+		if mspID == "" || slices.Contains(governance.AdminMSPIDs[:i], mspID) {
+			return fmt.Errorf("admin MSP IDs must be unique and not empty")
+		}
+	}
+	if governance.Threshold < 1 || governance.Threshold > len(governance.AdminMSPIDs) {
+		return fmt.Errorf("threshold must be between 1 and %d", len(governance.AdminMSPIDs))
+	}
+
+	data, err := json.Marshal(governance)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(GovernanceKey, data)
+}
+
+// ProposeIssuer submits a candidate issuer for approval and returns the proposal ID.
+// Clients of the candidate's organization propose it for their own MSP, admins may name any MSP.
+func (s *SmartContract) ProposeIssuer(ctx contractapi.TransactionContextInterface, issuerJSON string) (string, error) {
+	caller, err := getCaller(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	proposal, err := s.newIssuerProposal(ctx, caller, issuerJSON)
+	if err != nil {
+		return "", err
+	}
+
+	if err := emitProposalEvent(ctx, EventIssuerProposed, proposal); err != nil {
+		return "", err
+	}
+
+	return proposal.ID, nil
+}
+
+// CreateIssuer is the admin path to add an issuer: it proposes the issuer and approves it for the
+// admin's organization in one transaction. The issuer is Active right away when the governance
+// threshold is 1, otherwise the proposal waits for the votes of the other organizations.
+func (s *SmartContract) CreateIssuer(ctx contractapi.TransactionContextInterface, issuerJSON string) (*IssuerProposal, error) {
+	caller, err := getCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !caller.Admin {
+		return nil, fmt.Errorf("client %s is not authorized to create issuers", caller.MSPID)
+	}
+
+	proposal, err := s.newIssuerProposal(ctx, caller, issuerJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	// The proposal is not readable from the world state before this transaction commits,
+	// so the vote is cast on the proposal in hand
+	event, err := s.castVote(ctx, caller, proposal, true, "Created by an admin of "+caller.MSPID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := emitProposalEvent(ctx, event, proposal); err != nil {
+		return nil, err
+	}
+
+	return proposal, nil
+}
+
+// newIssuerProposal validates a candidate issuer and stores a pending proposal for it
+func (s *SmartContract) newIssuerProposal(ctx contractapi.TransactionContextInterface, caller *Caller, issuerJSON string) (*IssuerProposal, error) {
+	governance, err := s.GetGovernance(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var issuer Issuer
+	if err := json.Unmarshal([]byte(issuerJSON), &issuer); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal issuer: %v", err)
+	}
+
+	if !issuerIDPattern.MatchString(issuer.ID) {
+		return nil, fmt.Errorf("issuer id must be 2 to 32 lowercase letters, digits or underscores, starting with a letter or digit")
+	}
+	if issuer.Name == "" {
+		return nil, fmt.Errorf("issuer name is required")
+	}
+	if _, err := parsePublicKey(issuer.PublicKey); err != nil {
+		return nil, fmt.Errorf("invalid public key for issuer %s: %v", issuer.ID, err)
+	}
+
+	if !caller.Admin {
+		if issuer.MSPID != "" && issuer.MSPID != caller.MSPID {
+			return nil, fmt.Errorf("client of %s cannot propose an issuer for %s", caller.MSPID, issuer.MSPID)
+		}
+		if caller.IssuerID != "" && caller.IssuerID != issuer.ID {
+			return nil, fmt.Errorf("client for issuer %s cannot propose issuer %s", caller.IssuerID, issuer.ID)
+		}
+		issuer.MSPID = caller.MSPID
+	}
+	if issuer.MSPID == "" {
+		return nil, fmt.Errorf("issuer mspId is required")
+	}
+
+	existing, err := ctx.GetStub().GetState(IssuerKey + issuer.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("issuer %s already exists", issuer.ID)
+	}
+
+	pending, err := s.pendingProposalFor(ctx, issuer.ID)
+	if err != nil {
+		return nil, err
+	}
+	if pending != "" {
+		return nil, fmt.Errorf("issuer %s already has the pending proposal %s", issuer.ID, pending)
+	}
+
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Issuers only become active through approval, and start out with the proposed key only
+	issuer.Status = ProposalPending
+	issuer.Keys = nil
+	issuer.AssertionKeys = nil
+
+	proposal := &IssuerProposal{
+		ID:            ctx.GetStub().GetTxID(),
+		Issuer:        issuer,
+		Status:        ProposalPending,
+		ProposedBy:    caller.ID,
+		ProposerMSPID: caller.MSPID,
+		ProposedAt:    timestamp,
+		AdminMSPIDs:   governance.AdminMSPIDs,
+		Threshold:     governance.Threshold,
+		Votes:         []ProposalVote{},
+	}
+
+	if err := putProposal(ctx, proposal); err != nil {
+		return nil, err
+	}
+
+	indexKey, err := ctx.GetStub().CreateCompositeKey(IssuerProposalIndex, []string{issuer.ID, proposal.ID})
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.GetStub().PutState(indexKey, indexValue); err != nil {
+		return nil, err
+	}
+
+	return proposal, nil
+}
+
+// VoteOnIssuerProposal records an admin's vote for their organization. The issuer becomes Active
+// once the threshold of approvals is reached, and the proposal is rejected once that is no longer possible.
+func (s *SmartContract) VoteOnIssuerProposal(ctx contractapi.TransactionContextInterface, proposalID string, approve bool, comment string) (*IssuerProposal, error) {
+	caller, err := getCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !caller.Admin {
+		return nil, fmt.Errorf("client %s is not authorized to vote on issuer proposals", caller.MSPID)
+	}
+
+	proposal, err := s.ReadIssuerProposal(ctx, proposalID)
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := s.castVote(ctx, caller, proposal, approve, comment)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := emitProposalEvent(ctx, event, proposal); err != nil {
+		return nil, err
+	}
+
+	return proposal, nil
+}
+
+// castVote adds the vote of an admin's organization to a pending proposal, decides the proposal
+// when the vote settles it and stores it. It returns the event to emit.
+func (s *SmartContract) castVote(ctx contractapi.TransactionContextInterface, caller *Caller, proposal *IssuerProposal, approve bool, comment string) (string, error) {
+	if proposal.Status != ProposalPending {
+		return "", fmt.Errorf("proposal %s is %s", proposal.ID, proposal.Status)
+	}
+	if !slices.Contains(proposal.AdminMSPIDs, caller.MSPID) {
+		return "", fmt.Errorf("admins of %s do not vote on issuer proposals", caller.MSPID)
+	}
+	for _, vote := range proposal.Votes {
+		if vote.MSPID == caller.MSPID {
+			return "", fmt.Errorf("%s has already voted on proposal %s", caller.MSPID, proposal.ID)
+		}
+	}
+
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	proposal.Votes = append(proposal.Votes, ProposalVote{
+		AdminID: caller.ID,
+		MSPID:   caller.MSPID,
+		Approve: approve,
+		Comment: comment,
+		VotedAt: timestamp,
+		TxID:    ctx.GetStub().GetTxID(),
+	})
+
+	event := EventIssuerProposalVoted
+	approvals, rejections := proposal.Tally()
+	switch {
+	case approvals >= proposal.Threshold:
+		issuer := proposal.Issuer
+		issuer.Status = "Active"
+		key, err := newSigningKey(issuer.PublicKey, timestamp)
+		if err != nil {
+			return "", err
+		}
+		issuer.Keys = []IssuerSigningKey{*key}
+		if err := s.activateIssuer(ctx, &issuer); err != nil {
+			return "", err
+		}
+		proposal.Issuer = issuer
+		proposal.Status = ProposalApproved
+		proposal.DecidedAt = timestamp
+		event = EventIssuerApproved
+	case rejections > len(proposal.AdminMSPIDs)-proposal.Threshold:
+		proposal.Status = ProposalRejected
+		proposal.DecidedAt = timestamp
+		event = EventIssuerProposalRejected
+	}
+
+	if err := putProposal(ctx, proposal); err != nil {
+		return "", err
+	}
+
+	return event, nil
+}
+
+// WithdrawIssuerProposal lets the client that proposed an issuer withdraw a pending proposal
+func (s *SmartContract) WithdrawIssuerProposal(ctx contractapi.TransactionContextInterface, proposalID string) error {
+	caller, err := getCaller(ctx)
+	if err != nil {
+		return err
+	}
+
+	proposal, err := s.ReadIssuerProposal(ctx, proposalID)
+	if err != nil {
+		return err
+	}
+	if proposal.Status != ProposalPending {
+		return fmt.Errorf("proposal %s is %s", proposalID, proposal.Status)
+	}
+	if proposal.ProposedBy != caller.ID {
+		return fmt.Errorf("only the proposing client can withdraw proposal %s", proposalID)
+	}
+
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	proposal.Status = ProposalWithdrawn
+	proposal.DecidedAt = timestamp
+	if err := putProposal(ctx, proposal); err != nil {
+		return err
+	}
+
+	return emitProposalEvent(ctx, EventIssuerProposalWithdrawn, proposal)
+}
+
+// ReadIssuerProposal returns the proposal with given id
+func (s *SmartContract) ReadIssuerProposal(ctx contractapi.TransactionContextInterface, id string) (*IssuerProposal, error) {
+	data, err := ctx.GetStub().GetState(IssuerProposalKey + id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if data == nil {
+		return nil, fmt.Errorf("the proposal %s does not exist", id)
+	}
+
+	var proposal IssuerProposal
+	if err := json.Unmarshal(data, &proposal); err != nil {
+		return nil, err
+	}
+	return &proposal, nil
+}
+
+// GetIssuerProposals returns all proposals, or only those in the given status
+func (s *SmartContract) GetIssuerProposals(ctx contractapi.TransactionContextInterface, status string) ([]*IssuerProposal, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByRange(IssuerProposalKey, IssuerProposalKeyRangeEnd)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	proposals := []*IssuerProposal{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var proposal IssuerProposal
+		if err := json.Unmarshal(queryResponse.Value, &proposal); err != nil {
+			return nil, err
+		}
+		if status == "" || proposal.Status == status {
+			proposals = append(proposals, &proposal)
+		}
+	}
+
+	return proposals, nil
+}
+
+// pendingProposalFor returns the ID of the pending proposal for an issuer, or an empty string
+func (s *SmartContract) pendingProposalFor(ctx contractapi.TransactionContextInterface, issuerID string) (string, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(IssuerProposalIndex, []string{issuerID})
+	if err != nil {
+		return "", err
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		entry, err := resultsIterator.Next()
+		if err != nil {
+			return "", err
+		}
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(entry.Key)
+		if err != nil {
+			return "", err
+		}
+
+		proposal, err := s.ReadIssuerProposal(ctx, attributes[1])
+		if err != nil {
+			return "", err
+		}
+		if proposal.Status == ProposalPending {
+			return proposal.ID, nil
+		}
+	}
+
+	return "", nil
+}
+
+// activateIssuer writes an approved issuer, which must not exist yet
+func (s *SmartContract) activateIssuer(ctx contractapi.TransactionContextInterface, issuer *Issuer) error {
+	key := IssuerKey + issuer.ID
+	existing, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("issuer %s already exists", issuer.ID)
+	}
+
+	data, err := json.Marshal(issuer)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(key, data)
+}
+
+func putProposal(ctx contractapi.TransactionContextInterface, proposal *IssuerProposal) error {
+	data, err := json.Marshal(proposal)
+	if err != nil {
+		return fmt.Errorf("failed to marshal proposal: %v", err)
+	}
+	return ctx.GetStub().PutState(IssuerProposalKey+proposal.ID, data)
+}
@@ -0,0 +1,334 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// newGovernedLedger returns a ledger whose issuers are approved by admins of Org1MSP, Org2MSP and
+// Org3MSP with threshold approvals
+func newGovernedLedger(t *testing.T, threshold int) *testLedger {
+	ledger := newTestLedger(t)
+	ledger.mustInvoke(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+		return ledger.contract.InitGovernance(ctx, `["Org1MSP","Org2MSP","Org3MSP"]`, threshold)
+	})
+	return ledger
+}
+
+// candidateIssuer returns the JSON of issuer id for mspID with a new signing key
+func candidateIssuer(t *testing.T, id string, mspID string) string {
+	_, publicKey := newTestKey(t)
+	data, err := json.Marshal(&Issuer{ID: id, Name: strings.ToUpper(id), MSPID: mspID, PublicKey: publicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// propose submits ProposeIssuer as caller and returns the proposal ID
+func (l *testLedger) propose(caller *testIdentity, issuerJSON string) string {
+	l.t.Helper()
+	var id string
+	l.mustInvoke(caller, func(ctx contractapi.TransactionContextInterface) (err error) {
+		id, err = l.contract.ProposeIssuer(ctx, issuerJSON)
+		return err
+	})
+	return id
+}
+
+// vote submits VoteOnIssuerProposal as admin
+func (l *testLedger) vote(admin *testIdentity, proposalID string, approve bool) error {
+	return l.invoke(admin, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.VoteOnIssuerProposal(ctx, proposalID, approve, "")
+		return err
+	})
+}
+
+// proposal reads an issuer proposal
+func (l *testLedger) proposal(id string) *IssuerProposal {
+	l.t.Helper()
+	var proposal *IssuerProposal
+	l.mustInvoke(testReader, func(ctx contractapi.TransactionContextInterface) (err error) {
+		proposal, err = l.contract.ReadIssuerProposal(ctx, id)
+		return err
+	})
+	return proposal
+}
+
+func TestIssuerProposalVotes(t *testing.T) {
+	type ballot struct {
+		admin   *testIdentity
+		approve bool
+	}
+	tests := []struct {
+		name      string
+		threshold int
+		votes     []ballot
+		status    string // Of the proposal after the votes
+		event     string // Of the last vote
+	}{
+		{"one of two approvals", 2, []ballot{{org1Admin, true}}, ProposalPending, EventIssuerProposalVoted},
+		{"approved", 2, []ballot{{org1Admin, true}, {org3Admin, true}}, ProposalApproved, EventIssuerApproved},
+		{"approved after a rejection", 2, []ballot{{org2Admin, false}, {org1Admin, true}, {org3Admin, true}}, ProposalApproved, EventIssuerApproved},
+		{"rejection leaves enough voters", 2, []ballot{{org2Admin, false}}, ProposalPending, EventIssuerProposalVoted},
+		{"rejected", 2, []ballot{{org2Admin, false}, {org1Admin, true}, {org3Admin, false}}, ProposalRejected, EventIssuerProposalRejected},
+		{"unanimity rejected by one", 3, []ballot{{org1Admin, true}, {org2Admin, false}}, ProposalRejected, EventIssuerProposalRejected},
+		{"single approval", 1, []ballot{{org2Admin, true}}, ProposalApproved, EventIssuerApproved},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := newGovernedLedger(t, tt.threshold)
+			candidate := &testIdentity{id: "x509::CN=registrar@org2.example.com", mspID: "Org2MSP", issuerID: "vu"}
+			proposalID := ledger.propose(candidate, candidateIssuer(t, "vu", ""))
+
+			for _, b := range tt.votes {
+				if err := ledger.vote(b.admin, proposalID, b.approve); err != nil {
+					t.Fatalf("vote of %s: %v", b.admin.mspID, err)
+				}
+			}
+
+			proposal := ledger.proposal(proposalID)
+			if proposal.Status != tt.status || len(proposal.Votes) != len(tt.votes) {
+				t.Fatalf("proposal is %s with %d votes", proposal.Status, len(proposal.Votes))
+			}
+			if ledger.event.name != tt.event {
+				t.Errorf("event %s, want %s", ledger.event.name, tt.event)
+			}
+			if proposal.Issuer.MSPID != "Org2MSP" {
+				t.Errorf("proposed for %s, want the proposer's MSP", proposal.Issuer.MSPID)
+			}
+
+			_, exists := ledger.state[IssuerKey+"vu"]
+			if exists != (tt.status == ProposalApproved) {
+				t.Fatalf("issuer exists %v for a %s proposal", exists, tt.status)
+			}
+			if exists {
+				issuer := ledger.issuer("vu")
+				if issuer.Status != "Active" || len(issuer.Keys) != 1 || issuer.Keys[0].PublicKey != issuer.PublicKey {
+					t.Errorf("approved issuer %+v", issuer)
+				}
+			}
+
+			// Decided proposals take no more votes
+			if tt.status != ProposalPending {
+				if err := ledger.vote(org3Admin, proposalID, true); err == nil || !strings.Contains(err.Error(), "is "+tt.status) {
+					t.Errorf("vote on a %s proposal: %v", tt.status, err)
+				}
+			}
+		})
+	}
+}
+
+func TestIssuerProposalOneVotePerMSP(t *testing.T) {
+	ledger := newGovernedLedger(t, 2)
+	proposalID := ledger.propose(org1Admin, candidateIssuer(t, "vu", "Org2MSP"))
+
+	if err := ledger.vote(org1Admin, proposalID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another admin of the same organization, and a change of mind, count as the organization's second vote
+	otherOrg1Admin := &testIdentity{id: "x509::CN=Admin2@org1.example.com", mspID: "Org1MSP", admin: true}
+	for _, admin := range []*testIdentity{org1Admin, otherOrg1Admin} {
+		for _, approve := range []bool{true, false} {
+			if err := ledger.vote(admin, proposalID, approve); err == nil || !strings.Contains(err.Error(), "Org1MSP has already voted") {
+				t.Errorf("repeat vote %v of %s: %v", approve, admin.id, err)
+			}
+		}
+	}
+
+	outsider := &testIdentity{id: "x509::CN=Admin@org4.example.com", mspID: "Org4MSP", admin: true}
+	if err := ledger.vote(outsider, proposalID, true); err == nil || !strings.Contains(err.Error(), "admins of Org4MSP do not vote") {
+		t.Errorf("vote of a non-member organization: %v", err)
+	}
+	lu := &testIdentity{id: "x509::CN=lu@example.com", mspID: "Org2MSP", issuerID: "lu"}
+	if err := ledger.vote(lu, proposalID, true); err == nil || !strings.Contains(err.Error(), "not authorized to vote") {
+		t.Errorf("vote of a client that is no admin: %v", err)
+	}
+
+	if proposal := ledger.proposal(proposalID); proposal.Status != ProposalPending || len(proposal.Votes) != 1 {
+		t.Errorf("proposal is %s with %d votes", proposal.Status, len(proposal.Votes))
+	}
+}
+
+func TestWithdrawIssuerProposal(t *testing.T) {
+	ledger := newGovernedLedger(t, 2)
+	candidate := &testIdentity{id: "x509::CN=registrar@org2.example.com", mspID: "Org2MSP"}
+	proposalID := ledger.propose(candidate, candidateIssuer(t, "vu", ""))
+
+	withdraw := func(caller *testIdentity) error {
+		return ledger.invoke(caller, func(ctx contractapi.TransactionContextInterface) error {
+			return ledger.contract.WithdrawIssuerProposal(ctx, proposalID)
+		})
+	}
+
+	if err := withdraw(org2Admin); err == nil || !strings.Contains(err.Error(), "only the proposing client") {
+		t.Errorf("withdrawal by another client: %v", err)
+	}
+	if err := withdraw(candidate); err != nil {
+		t.Fatal(err)
+	}
+	if proposal := ledger.proposal(proposalID); proposal.Status != ProposalWithdrawn || proposal.DecidedAt == "" {
+		t.Errorf("proposal is %s, decided at %q", proposal.Status, proposal.DecidedAt)
+	}
+	if ledger.event.name != EventIssuerProposalWithdrawn {
+		t.Errorf("event %s", ledger.event.name)
+	}
+
+	if err := withdraw(candidate); err == nil || !strings.Contains(err.Error(), "is Withdrawn") {
+		t.Errorf("second withdrawal: %v", err)
+	}
+	if err := ledger.vote(org1Admin, proposalID, true); err == nil {
+		t.Error("vote on a withdrawn proposal")
+	}
+
+	// The issuer can be proposed again once nothing is pending for it
+	ledger.propose(candidate, candidateIssuer(t, "vu", ""))
+}
+
+func TestProposeIssuerRejected(t *testing.T) {
+	ledger := newGovernedLedger(t, 2)
+	ledger.addIssuer("lu", "Org1MSP")
+	ledger.propose(org1Admin, candidateIssuer(t, "vu", "Org2MSP"))
+
+	registrar := &testIdentity{id: "x509::CN=registrar@org2.example.com", mspID: "Org2MSP", issuerID: "rtu"}
+	tests := []struct {
+		name   string
+		caller *testIdentity
+		issuer string
+		err    string
+	}{
+		{"existing issuer", org1Admin, candidateIssuer(t, "lu", "Org1MSP"), "issuer lu already exists"},
+		{"pending proposal", org2Admin, candidateIssuer(t, "vu", "Org2MSP"), "already has the pending proposal"},
+		{"invalid id", org1Admin, candidateIssuer(t, "VU-1", "Org2MSP"), "issuer id must be"},
+		{"invalid key", org1Admin, `{"id":"vu2","name":"VU","mspId":"Org2MSP","publicKey":"not a key"}`, "invalid public key"},
+		{"no MSP", org1Admin, candidateIssuer(t, "vu2", ""), "issuer mspId is required"},
+		{"other MSP", registrar, candidateIssuer(t, "rtu", "Org1MSP"), "cannot propose an issuer for Org1MSP"},
+		{"other issuer", registrar, candidateIssuer(t, "vu2", ""), "client for issuer rtu cannot propose issuer vu2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ledger.invoke(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				_, err := ledger.contract.ProposeIssuer(ctx, tt.issuer)
+				return err
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestInitGovernance(t *testing.T) {
+	tests := []struct {
+		msps      string
+		threshold int
+		err       string
+	}{
+		{`[]`, 1, "at least one admin MSP ID"},
+		{`["Org1MSP","Org1MSP"]`, 1, "must be unique"},
+		{`["Org1MSP",""]`, 1, "must be unique and not empty"},
+		{`["Org1MSP","Org2MSP"]`, 0, "threshold must be between 1 and 2"},
+		{`["Org1MSP","Org2MSP"]`, 3, "threshold must be between 1 and 2"},
+	}
+	for _, tt := range tests {
+		ledger := newTestLedger(t)
+		err := ledger.invoke(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+			return ledger.contract.InitGovernance(ctx, tt.msps, tt.threshold)
+		})
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("InitGovernance(%s, %d) = %v, want %q", tt.msps, tt.threshold, err, tt.err)
+		}
+	}
+
+	ledger := newGovernedLedger(t, 2)
+	err := ledger.invoke(org2Admin, func(ctx contractapi.TransactionContextInterface) error {
+		return ledger.contract.InitGovernance(ctx, `["Org2MSP"]`, 1)
+	})
+	if err == nil || !strings.Contains(err.Error(), "already initialized") {
+		t.Errorf("second InitGovernance: %v", err)
+	}
+}
+
+// TestCreateIssuer covers the admin path, which proposes and votes in a single transaction: the
+// vote is cast on the proposal it just created, which the world state does not return yet
+func TestCreateIssuer(t *testing.T) {
+	createIssuer := func(ledger *testLedger, admin *testIdentity, issuerJSON string) (*IssuerProposal, error) {
+		var proposal *IssuerProposal
+		err := ledger.invoke(admin, func(ctx contractapi.TransactionContextInterface) (err error) {
+			proposal, err = ledger.contract.CreateIssuer(ctx, issuerJSON)
+			return err
+		})
+		return proposal, err
+	}
+
+	t.Run("threshold 1", func(t *testing.T) {
+		ledger := newGovernedLedger(t, 1)
+		proposal, err := createIssuer(ledger, org2Admin, candidateIssuer(t, "vu", "Org2MSP"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if proposal.Status != ProposalApproved || len(proposal.Votes) != 1 || proposal.Votes[0].MSPID != "Org2MSP" {
+			t.Fatalf("proposal %+v", proposal)
+		}
+		if stored := ledger.proposal(proposal.ID); stored.Status != ProposalApproved {
+			t.Errorf("stored proposal is %s", stored.Status)
+		}
+		if issuer := ledger.issuer("vu"); issuer.Status != "Active" || len(issuer.Keys) != 1 {
+			t.Errorf("issuer %+v", issuer)
+		}
+		if ledger.event.name != EventIssuerApproved {
+			t.Errorf("event %s", ledger.event.name)
+		}
+	})
+
+	t.Run("threshold 2", func(t *testing.T) {
+		ledger := newGovernedLedger(t, 2)
+		proposal, err := createIssuer(ledger, org2Admin, candidateIssuer(t, "vu", "Org2MSP"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if proposal.Status != ProposalPending || len(proposal.Votes) != 1 {
+			t.Fatalf("proposal %+v", proposal)
+		}
+		if _, ok := ledger.state[IssuerKey+"vu"]; ok {
+			t.Fatal("issuer active after one of two approvals")
+		}
+
+		if err := ledger.vote(org2Admin, proposal.ID, true); err == nil {
+			t.Error("the creating organization voted twice")
+		}
+		if err := ledger.vote(org1Admin, proposal.ID, true); err != nil {
+			t.Fatal(err)
+		}
+		if issuer := ledger.issuer("vu"); issuer.Status != "Active" {
+			t.Errorf("issuer is %s", issuer.Status)
+		}
+	})
+
+	t.Run("not an admin", func(t *testing.T) {
+		ledger := newGovernedLedger(t, 1)
+		registrar := &testIdentity{id: "x509::CN=registrar@org2.example.com", mspID: "Org2MSP"}
+		if _, err := createIssuer(ledger, registrar, candidateIssuer(t, "vu", "Org2MSP")); err == nil || !strings.Contains(err.Error(), "not authorized to create issuers") {
+			t.Errorf("got %v", err)
+		}
+	})
+
+	t.Run("not a voting organization", func(t *testing.T) {
+		ledger := newGovernedLedger(t, 1)
+		outsider := &testIdentity{id: "x509::CN=Admin@org4.example.com", mspID: "Org4MSP", admin: true}
+		if _, err := createIssuer(ledger, outsider, candidateIssuer(t, "vu", "Org4MSP")); err == nil || !strings.Contains(err.Error(), "do not vote") {
+			t.Errorf("got %v", err)
+		}
+		if _, ok := ledger.state[IssuerKey+"vu"]; ok {
+			t.Error("issuer created")
+		}
+	})
+}
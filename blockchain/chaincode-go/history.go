@@ -0,0 +1,95 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// CredentialHistoryEntry is a single committed version of a credential
+type CredentialHistoryEntry struct {
+	TxID       string      `json:"txId"`
+	Timestamp  string      `json:"timestamp"` // RFC 3339 commit time of the transaction
+	IsDelete   bool        `json:"isDelete"`
+	Credential *Credential `json:"credential,omitempty" metadata:",optional"` // Empty when the version is a delete
+}
+
+// GetCredentialHistory returns every committed version of a credential, oldest first.
+// For migrated credentials the versions stored under the legacy ID come first.
+func (s *SmartContract) GetCredentialHistory(ctx contractapi.TransactionContextInterface, id string) ([]*CredentialHistoryEntry, error) {
+	keys := []string{CredentialKey + id}
+
+	target, err := resolveCredentialAlias(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if target != "" {
+		keys = append(keys, CredentialKey+target)
+	} else if current, err := s.ReadCredential(ctx, id); err == nil && current.LegacyID != "" {
+		keys = []string{CredentialKey + current.LegacyID, CredentialKey + id}
+	}
+
+	var entries []*CredentialHistoryEntry
+	for _, key := range keys {
+		keyEntries, err := readKeyHistory(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, keyEntries...)
+	}
+
+	if entries == nil {
+		return nil, fmt.Errorf("the credential %s does not exist", id)
+	}
+
+	return entries, nil
+}
+
+// readKeyHistory returns the committed versions of a single credential key, oldest first
+func readKeyHistory(ctx contractapi.TransactionContextInterface, key string) ([]*CredentialHistoryEntry, error) {
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credential history: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var entries []*CredentialHistoryEntry
+	for resultsIterator.HasNext() {
+		modification, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		entry := &CredentialHistoryEntry{
+			TxID:     modification.TxId,
+			IsDelete: modification.IsDelete,
+		}
+
+		if ts := modification.Timestamp; ts != nil {
+			entry.Timestamp = time.Unix(ts.Seconds, int64(ts.Nanos)).UTC().Format(time.RFC3339Nano)
+		}
+
+		if !modification.IsDelete && len(modification.Value) > 0 {
+			var credential Credential
+			if err := json.Unmarshal(modification.Value, &credential); err != nil {
+				return nil, err
+			}
+			entry.Credential = &credential
+		}
+
+		entries = append(entries, entry)
+	}
+
+	// The peer returns the most recent modification first
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	return entries, nil
+}
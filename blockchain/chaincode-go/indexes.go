@@ -0,0 +1,215 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// Composite key indexes pointing to credential IDs
+const IssuerCredentialIndex = "issuer~credential"
+const HashCredentialIndex = "hash~credential"
+const StatusCredentialIndex = "status~credential"
+
+// Composite key index pointing from an issuer ID to the proposals made for it
+const IssuerProposalIndex = "issuer~proposal"
+
+// Index entries only carry the key, the value is a placeholder
+var indexValue = []byte{0x00}
+
+// putCredential writes a credential under the ledger key of the given id and
+// moves its index entries from the previously stored version.
+func (s *SmartContract) putCredential(ctx contractapi.TransactionContextInterface, id string, credential *Credential) error {
+	key := CredentialKey + id
+
+	previousBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+
+	var previous *Credential
+	if previousBytes != nil {
+		previous = &Credential{}
+		if err := json.Unmarshal(previousBytes, previous); err != nil {
+			return err
+		}
+	}
+
+	credentialBytes, err := json.Marshal(credential)
+	if err != nil {
+		return fmt.Errorf("failed to marshal credential data: %v", err)
+	}
+
+	if err := ctx.GetStub().PutState(key, credentialBytes); err != nil {
+		return err
+	}
+
+	return updateCredentialIndexes(ctx, id, previous, credential)
+}
+
+// credentialIndexes returns the index name and indexed attribute for every index of a credential
+func credentialIndexes(credential *Credential) map[string]string {
+	return map[string]string{
+		IssuerCredentialIndex: credential.IssuerID,
+		HashCredentialIndex:   credential.DiplomaHash,
+		StatusCredentialIndex: credential.Status,
+	}
+}
+
+// updateCredentialIndexes brings the index entries of credential id from previous to current.
+// Pass a nil previous for a new credential and a nil current for a deleted one.
+func updateCredentialIndexes(ctx contractapi.TransactionContextInterface, id string, previous *Credential, current *Credential) error {
+	var before, after map[string]string
+	if previous != nil {
+		before = credentialIndexes(previous)
+	}
+	if current != nil {
+		after = credentialIndexes(current)
+	}
+
+	for _, index := range []string{IssuerCredentialIndex, HashCredentialIndex, StatusCredentialIndex} {
+		oldValue, hadOld := before[index]
+		newValue, hasNew := after[index]
+		if hadOld && hasNew && oldValue == newValue {
+			continue
+		}
+
+		if hadOld {
+			key, err := ctx.GetStub().CreateCompositeKey(index, []string{oldValue, id})
+			if err != nil {
+				return fmt.Errorf("failed to create %s key: %v", index, err)
+			}
+			if err := ctx.GetStub().DelState(key); err != nil {
+				return fmt.Errorf("failed to delete %s entry: %v", index, err)
+			}
+		}
+
+		if hasNew {
+			key, err := ctx.GetStub().CreateCompositeKey(index, []string{newValue, id})
+			if err != nil {
+				return fmt.Errorf("failed to create %s key: %v", index, err)
+			}
+			if err := ctx.GetStub().PutState(key, indexValue); err != nil {
+				return fmt.Errorf("failed to put %s entry: %v", index, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// QueryCredentialsByIssuer returns a page of the issuer's credentials read through the issuer~credential index
+func (s *SmartContract) QueryCredentialsByIssuer(ctx contractapi.TransactionContextInterface, issuerID string, pageSize int32, bookmark string) (*CredentialPage, error) {
+	return s.queryCredentialIndex(ctx, IssuerCredentialIndex, issuerID, pageSize, bookmark)
+}
+
+// QueryCredentialsByStatus returns a page of credentials with the given status read through the status~credential index
+func (s *SmartContract) QueryCredentialsByStatus(ctx contractapi.TransactionContextInterface, status string, pageSize int32, bookmark string) (*CredentialPage, error) {
+	if _, ok := credentialTransitions[status]; !ok {
+		return nil, fmt.Errorf("unknown credential status %q", status)
+	}
+
+	return s.queryCredentialIndex(ctx, StatusCredentialIndex, status, pageSize, bookmark)
+}
+
+// QueryCredentialsByHash returns every credential issued for the given diploma hash
+func (s *SmartContract) QueryCredentialsByHash(ctx contractapi.TransactionContextInterface, diplomaHash string) ([]*Credential, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(HashCredentialIndex, []string{diplomaHash})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	credentials, err := s.readIndexedCredentials(ctx, resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	return credentials, nil
+}
+
+func (s *SmartContract) queryCredentialIndex(ctx contractapi.TransactionContextInterface, index string, value string, pageSize int32, bookmark string) (*CredentialPage, error) {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	if pageSize > MaxPageSize {
+		pageSize = MaxPageSize
+	}
+
+	resultsIterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(index, []string{value}, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	credentials, err := s.readIndexedCredentials(ctx, resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CredentialPage{
+		Credentials: credentials,
+		Count:       int32(len(credentials)),
+		Bookmark:    metadata.GetBookmark(),
+	}, nil
+}
+
+// readIndexedCredentials resolves the credentials referenced by index entries
+func (s *SmartContract) readIndexedCredentials(ctx contractapi.TransactionContextInterface, resultsIterator shim.StateQueryIteratorInterface) ([]*Credential, error) {
+	credentials := []*Credential{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		credential, err := s.resolveQueryResult(ctx, true, queryResponse)
+		if err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, credential)
+	}
+
+	return credentials, nil
+}
+
+// RebuildCredentialIndexes writes index entries for credentials stored before the indexes existed
+func (s *SmartContract) RebuildCredentialIndexes(ctx contractapi.TransactionContextInterface) (int, error) {
+	if err := assertAdmin(ctx); err != nil {
+		return 0, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByRange(CredentialKey, CredentialKeyRangeEnd)
+	if err != nil {
+		return 0, err
+	}
+	defer resultsIterator.Close()
+
+	count := 0
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return 0, err
+		}
+
+		var credential Credential
+		if err := json.Unmarshal(queryResponse.Value, &credential); err != nil {
+			return 0, err
+		}
+
+		id := strings.TrimPrefix(queryResponse.Key, CredentialKey)
+		if err := updateCredentialIndexes(ctx, id, nil, &credential); err != nil {
+			return 0, err
+		}
+		count++
+	}
+
+	return count, nil
+}
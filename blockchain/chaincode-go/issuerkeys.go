@@ -0,0 +1,269 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// Issuer signing key states. Retired keys still verify the credentials signed while they were
+// active, signatures made with a compromised key are no longer accepted.
+const KeyStatusActive = "Active"
+const KeyStatusRetired = "Retired"
+const KeyStatusCompromised = "Compromised"
+
+// IssuerSigningKey is one of an issuer's signing keys with the period it was used in
+type IssuerSigningKey struct {
+	ID        string `json:"id"` // First 16 hex digits of the SHA-256 of the DER encoded key
+	PublicKey string `json:"publicKey"`
+	Status    string `json:"status"`
+	ValidFrom string `json:"validFrom,omitempty" metadata:",optional"` // Empty for the key the issuer was created with
+	ValidTo   string `json:"validTo,omitempty" metadata:",optional"`   // Empty while the key is active
+}
+
+// RotateIssuerKey replaces the active signing key of an issuer. The previous key stays listed and
+// keeps verifying credentials signed before the rotation, unless it is marked compromised.
+func (s *SmartContract) RotateIssuerKey(ctx contractapi.TransactionContextInterface, issuerID string, publicKey string, compromised bool) (*Issuer, error) {
+	issuer, err := s.readActiveIssuer(ctx, issuerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := assertIssuerOwner(ctx, issuer); err != nil {
+		return nil, err
+	}
+
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	next, err := newSigningKey(publicKey, timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key for issuer %s: %v", issuerID, err)
+	}
+
+	if err := assertKeyUnused(issuer, next.ID); err != nil {
+		return nil, err
+	}
+
+	keys := issuerKeys(issuer)
+	for i := range keys {
+		if keys[i].Status == KeyStatusActive {
+			keys[i].ValidTo = timestamp
+			keys[i].Status = KeyStatusRetired
+			if compromised {
+				keys[i].Status = KeyStatusCompromised
+			}
+		}
+	}
+
+	issuer.Keys = append(keys, *next)
+	issuer.PublicKey = next.PublicKey
+
+	if err := putIssuer(ctx, issuer); err != nil {
+		return nil, err
+	}
+
+	err = setEvent(ctx, EventIssuerKeyRotated, IssuerEvent{
+		IssuerID:  issuer.ID,
+		Status:    issuer.Status,
+		KeyID:     next.ID,
+		Timestamp: timestamp,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return issuer, nil
+}
+
+// RotateIssuerAssertionKey registers the key signing the proofs of an issuer's exported verifiable
+// credentials and retires the previous one. Assertion keys are kept apart from signing keys, so
+// a gateway holding one to sign exported credentials cannot sign credentials onto the ledger.
+func (s *SmartContract) RotateIssuerAssertionKey(ctx contractapi.TransactionContextInterface, issuerID string, publicKey string, compromised bool) (*Issuer, error) {
+	issuer, err := s.readActiveIssuer(ctx, issuerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := assertIssuerOwner(ctx, issuer); err != nil {
+		return nil, err
+	}
+
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	next, err := newSigningKey(publicKey, timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key for issuer %s: %v", issuerID, err)
+	}
+	if key, _ := parsePublicKey(publicKey); key != nil {
+		if _, ok := key.(*rsa.PublicKey); ok {
+			return nil, fmt.Errorf("assertion keys must be ECDSA P-256 or Ed25519 keys")
+		}
+	}
+
+	if err := assertKeyUnused(issuer, next.ID); err != nil {
+		return nil, err
+	}
+
+	for i := range issuer.AssertionKeys {
+		if issuer.AssertionKeys[i].Status == KeyStatusActive {
+			issuer.AssertionKeys[i].ValidTo = timestamp
+			issuer.AssertionKeys[i].Status = KeyStatusRetired
+			if compromised {
+				issuer.AssertionKeys[i].Status = KeyStatusCompromised
+			}
+		}
+	}
+	issuer.AssertionKeys = append(issuer.AssertionKeys, *next)
+
+	if err := putIssuer(ctx, issuer); err != nil {
+		return nil, err
+	}
+
+	err = setEvent(ctx, EventIssuerAssertionKeyRotated, IssuerEvent{
+		IssuerID:  issuer.ID,
+		Status:    issuer.Status,
+		KeyID:     next.ID,
+		Timestamp: timestamp,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return issuer, nil
+}
+
+// assertKeyUnused rejects a key an issuer has already used, either for signing or for assertions
+func assertKeyUnused(issuer *Issuer, keyID string) error {
+	for _, key := range slices.Concat(issuerKeys(issuer), issuer.AssertionKeys) {
+		if key.ID == keyID {
+			return fmt.Errorf("key %s was already used by issuer %s", keyID, issuer.ID)
+		}
+	}
+
+	return nil
+}
+
+// newSigningKey validates a public key and returns it as an active key starting at validFrom
+func newSigningKey(publicKey string, validFrom string) (*IssuerSigningKey, error) {
+	if _, err := parsePublicKey(publicKey); err != nil {
+		return nil, err
+	}
+
+	return &IssuerSigningKey{
+		ID:        keyFingerprint(publicKey),
+		PublicKey: publicKey,
+		Status:    KeyStatusActive,
+		ValidFrom: validFrom,
+	}, nil
+}
+
+// issuerKeys returns the signing keys of an issuer, oldest first. Issuers created before keys
+// were rotated only carry PublicKey, which is returned as their single active key.
+func issuerKeys(issuer *Issuer) []IssuerSigningKey {
+	if len(issuer.Keys) > 0 {
+		return issuer.Keys
+	}
+
+	return []IssuerSigningKey{{
+		ID:        keyFingerprint(issuer.PublicKey),
+		PublicKey: issuer.PublicKey,
+		Status:    KeyStatusActive,
+	}}
+}
+
+// activeSigningKey returns the key new signatures of an issuer are checked against
+func activeSigningKey(issuer *Issuer) (*IssuerSigningKey, error) {
+	keys := issuerKeys(issuer)
+	for i := range keys {
+		if keys[i].Status == KeyStatusActive {
+			return &keys[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("issuer %s has no active signing key", issuer.ID)
+}
+
+// signingKeysAt returns the keys of an issuer that were active at the given RFC 3339 time.
+// Transaction timestamps have a resolution of one second, so a credential signed in the second
+// of a rotation matches both the retired and the new key. Credentials without a signing time
+// predate key rotation and were signed with the first key.
+func signingKeysAt(issuer *Issuer, signedAt string) ([]IssuerSigningKey, error) {
+	keys := issuerKeys(issuer)
+	if signedAt == "" {
+		return keys[:1], nil
+	}
+
+	at, err := time.Parse(time.RFC3339, signedAt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing time %q: %v", signedAt, err)
+	}
+
+	var matching []IssuerSigningKey
+	for _, key := range keys {
+		if key.ValidFrom != "" {
+			validFrom, err := time.Parse(time.RFC3339, key.ValidFrom)
+			if err != nil || at.Before(validFrom) {
+				continue
+			}
+		}
+		if key.ValidTo != "" {
+			validTo, err := time.Parse(time.RFC3339, key.ValidTo)
+			if err != nil || at.After(validTo) {
+				continue
+			}
+		}
+		matching = append(matching, key)
+	}
+
+	if len(matching) == 0 {
+		return nil, fmt.Errorf("issuer %s had no signing key at %s", issuer.ID, signedAt)
+	}
+
+	return matching, nil
+}
+
+// verifySignatureAt checks a credential signature against the issuer keys active when it was
+// signed and returns the matching key
+func verifySignatureAt(issuer *Issuer, credential *Credential) (*IssuerSigningKey, error) {
+	keys, err := signingKeysAt(issuer, credential.SignedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range keys {
+		if err = verifyIssuerSignature(keys[i].PublicKey, credential.DiplomaHash, credential.IssuerSignature); err == nil {
+			if keys[i].Status == KeyStatusCompromised {
+				return &keys[i], fmt.Errorf("signed with key %s, which is compromised", keys[i].ID)
+			}
+			return &keys[i], nil
+		}
+	}
+
+	return nil, err
+}
+
+// keyFingerprint identifies a public key independently of its PEM or base64 armor
+func keyFingerprint(publicKey string) string {
+	der, err := decodePublicKey(publicKey)
+	if err != nil {
+		der = []byte(publicKey)
+	}
+
+	h := sha256.Sum256(der)
+	return hex.EncodeToString(h[:8])
+}
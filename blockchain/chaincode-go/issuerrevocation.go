@@ -0,0 +1,314 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// Policies for the credentials of a revoked issuer
+const IssuerRevocationPolicyNone = "none"     // Not retroactive, credentials stay valid
+const IssuerRevocationPolicyCutoff = "cutoff" // Credentials signed at or after the cutoff are revoked
+const IssuerRevocationPolicyAll = "all"       // Every credential is revoked
+
+// Credentials examined per ContinueIssuerRevocation call when no chunk size is given, and at most
+const DefaultRevocationChunkSize = 50
+const MaxRevocationChunkSize = 200
+
+// IssuerRevocation records the revocation of an issuer and the progress of revoking its credentials
+type IssuerRevocation struct {
+	Policy       string `json:"policy"`                                // One of the IssuerRevocationPolicy values
+	Cutoff       string `json:"cutoff,omitempty" metadata:",optional"` // RFC 3339 time, for the cutoff policy
+	Note         string `json:"note"`                                  // Free-text explanation for verifiers
+	RevokedAt    string `json:"revokedAt"`                             // RFC 3339 timestamp of the revoking transaction
+	RevokedBy    string `json:"revokedBy"`                             // Client identity that submitted the revocation
+	RevokerMSPID string `json:"revokerMspId"`                          // Organization of the revoking client
+	TxID         string `json:"txId"`                                  // Transaction that revoked the issuer
+	Cursor       string `json:"cursor,omitempty" metadata:",optional"` // Last credential ID examined by the cascade
+	// Set once every ID from NewCredentialID is examined, the cascade then examines legacy IDs
+	Legacy       bool   `json:"legacy,omitempty" metadata:",optional"`
+	LegacyCursor string `json:"legacyCursor,omitempty" metadata:",optional"` // Last legacy credential ID examined
+	Examined     int    `json:"examined"`                                    // Credentials examined so far
+	Revoked      int    `json:"revoked"`                                     // Credentials revoked so far
+	Complete     bool   `json:"complete"`                                    // Every credential has been examined
+	CompletedAt  string `json:"completedAt,omitempty" metadata:",optional"`
+}
+
+// RevokeIssuer stops an issuer from issuing and records what happens to its credentials.
+// Credentials are revoked afterwards in chunks by ContinueIssuerRevocation, unless the policy is none.
+func (s *SmartContract) RevokeIssuer(ctx contractapi.TransactionContextInterface, id string, policy string, cutoff string, note string) (*Issuer, error) {
+	if err := assertAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	issuer, err := s.readActiveIssuer(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	switch policy {
+	case IssuerRevocationPolicyNone, IssuerRevocationPolicyAll:
+		if cutoff != "" {
+			return nil, fmt.Errorf("a cutoff is only accepted with the %s policy", IssuerRevocationPolicyCutoff)
+		}
+	case IssuerRevocationPolicyCutoff:
+		cutoffTime, err := parseIssueTime(cutoff)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cutoff: %v", err)
+		}
+		cutoff = cutoffTime.Format(time.RFC3339)
+	default:
+		return nil, fmt.Errorf("unknown issuer revocation policy %q, expected %s, %s or %s",
+			policy, IssuerRevocationPolicyNone, IssuerRevocationPolicyCutoff, IssuerRevocationPolicyAll)
+	}
+
+	caller, err := getCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	revokedAt, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	issuer.Status = "Revoked"
+	issuer.Revocation = &IssuerRevocation{
+		Policy:       policy,
+		Cutoff:       cutoff,
+		Note:         note,
+		RevokedAt:    revokedAt,
+		RevokedBy:    caller.ID,
+		RevokerMSPID: caller.MSPID,
+		TxID:         ctx.GetStub().GetTxID(),
+	}
+	if policy == IssuerRevocationPolicyNone {
+		issuer.Revocation.Complete = true
+		issuer.Revocation.CompletedAt = revokedAt
+	}
+
+	if err := putIssuer(ctx, issuer); err != nil {
+		return nil, err
+	}
+
+	if err := emitIssuerEvent(ctx, EventIssuerRevoked, issuer); err != nil {
+		return nil, err
+	}
+
+	return issuer, nil
+}
+
+// ContinueIssuerRevocation examines up to chunkSize credentials of a revoked issuer, in ID order
+// after the last one examined, and revokes those its policy covers. Credentials with IDs from
+// NewCredentialID come first, then those still carrying legacy IDs. Call it until the returned
+// revocation is complete; a failed or interrupted call is simply repeated.
+func (s *SmartContract) ContinueIssuerRevocation(ctx contractapi.TransactionContextInterface, issuerID string, chunkSize int) (*IssuerRevocation, error) {
+	if err := assertAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	if chunkSize <= 0 {
+		chunkSize = DefaultRevocationChunkSize
+	}
+	if chunkSize > MaxRevocationChunkSize {
+		chunkSize = MaxRevocationChunkSize
+	}
+
+	issuer, err := s.ReadIssuer(ctx, issuerID)
+	if err != nil {
+		return nil, err
+	}
+	revocation := issuer.Revocation
+	if issuer.Status != "Revoked" || revocation == nil {
+		return nil, fmt.Errorf("issuer %s is not revoked", issuerID)
+	}
+	if revocation.Complete {
+		return revocation, nil
+	}
+
+	var cutoff time.Time
+	if revocation.Policy == IssuerRevocationPolicyCutoff {
+		if cutoff, err = parseIssueTime(revocation.Cutoff); err != nil {
+			return nil, err
+		}
+	}
+
+	caller, err := getCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	revokedIDs := []string{}
+	examined := 0
+
+	// revoke revokes one credential of the issuer when the policy covers it
+	revoke := func(id string, credential *Credential) error {
+		if credential.IssuerID != issuerID || credential.Status == StatusRevoked {
+			return nil
+		}
+		if revocation.Policy == IssuerRevocationPolicyCutoff {
+			// Credentials without a known issue time are revoked, they cannot be shown to predate the cutoff
+			if issuedAt, ok := credentialIssueTime(credential); ok && issuedAt.Before(cutoff) {
+				return nil
+			}
+		}
+
+		credential.Status = StatusRevoked
+		credential.Suspension = nil
+		credential.Revocation = &Revocation{
+			Reason:       RevocationReasonIssuerRevoked,
+			Note:         revocation.Note,
+			RevokedAt:    timestamp,
+			RevokedBy:    caller.ID,
+			RevokerMSPID: caller.MSPID,
+			TxID:         ctx.GetStub().GetTxID(),
+		}
+		if err := s.putCredential(ctx, id, credential); err != nil {
+			return err
+		}
+		revokedIDs = append(revokedIDs, id)
+		return nil
+	}
+
+	// Pagination is not available in update transactions and range queries do not accept composite
+	// keys, so the chunk is a range over the issuer's credential IDs starting after the cursor of the
+	// previous one. Each call reads at most chunkSize credentials.
+	if !revocation.Legacy {
+		startKey, endKey := issuerCredentialRange(issuerID, revocation.Cursor)
+		resultsIterator, err := ctx.GetStub().GetStateByRange(startKey, endKey)
+		if err != nil {
+			return nil, err
+		}
+		defer resultsIterator.Close()
+
+		for examined < chunkSize && resultsIterator.HasNext() {
+			entry, err := resultsIterator.Next()
+			if err != nil {
+				return nil, err
+			}
+			id := strings.TrimPrefix(entry.Key, CredentialKey)
+
+			examined++
+			revocation.Cursor = id
+
+			var credential Credential
+			if err := json.Unmarshal(entry.Value, &credential); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal credential %s: %v", id, err)
+			}
+			if err := revoke(id, &credential); err != nil {
+				return nil, err
+			}
+		}
+		revocation.Legacy = !resultsIterator.HasNext()
+	}
+
+	// Legacy IDs, left by credentials that were not or could not be migrated, do not share the
+	// issuer's prefix. They are found through the issuer's entries in the issuer~credential index,
+	// which are read up to the legacy cursor again on every call.
+	if revocation.Legacy && examined < chunkSize {
+		entries, err := ctx.GetStub().GetStateByPartialCompositeKey(IssuerCredentialIndex, []string{issuerID})
+		if err != nil {
+			return nil, err
+		}
+		defer entries.Close()
+
+		for examined < chunkSize && entries.HasNext() {
+			entry, err := entries.Next()
+			if err != nil {
+				return nil, err
+			}
+			_, attributes, err := ctx.GetStub().SplitCompositeKey(entry.Key)
+			if err != nil {
+				return nil, err
+			}
+			id := attributes[1]
+			if strings.HasPrefix(id, issuerID+"-") || id <= revocation.LegacyCursor {
+				continue
+			}
+
+			examined++
+			revocation.LegacyCursor = id
+
+			credentialJSON, err := ctx.GetStub().GetState(CredentialKey + id)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read from world state: %v", err)
+			}
+			if credentialJSON == nil {
+				continue
+			}
+			var credential Credential
+			if err := json.Unmarshal(credentialJSON, &credential); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal credential %s: %v", id, err)
+			}
+			if err := revoke(id, &credential); err != nil {
+				return nil, err
+			}
+		}
+
+		if !entries.HasNext() {
+			revocation.Complete = true
+			revocation.CompletedAt = timestamp
+		}
+	}
+
+	revocation.Examined += examined
+	revocation.Revoked += len(revokedIDs)
+
+	if err := putIssuer(ctx, issuer); err != nil {
+		return nil, err
+	}
+
+	err = setEvent(ctx, EventIssuerCredentialsRevoked, IssuerCredentialsRevokedEvent{
+		IssuerID:      issuerID,
+		CredentialIDs: revokedIDs,
+		Status:        StatusRevoked,
+		Complete:      revocation.Complete,
+		Timestamp:     timestamp,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return revocation, nil
+}
+
+// credentialIssueTime returns when a credential was issued: the ledger time its signature was
+// accepted, or its diploma issue date for credentials recorded before that was kept
+func credentialIssueTime(credential *Credential) (time.Time, bool) {
+	if credential.SignedAt != "" {
+		if signedAt, err := time.Parse(time.RFC3339, credential.SignedAt); err == nil {
+			return signedAt, true
+		}
+	}
+
+	issueDate, err := parseIssueTime(credential.DiplomaMetadata.IssueDate)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return issueDate, true
+}
+
+// parseIssueTime accepts an RFC 3339 time or a YYYY-MM-DD date, which is read as midnight UTC
+func parseIssueTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither an RFC 3339 time nor a YYYY-MM-DD date", value)
+	}
+	return t, nil
+}
@@ -0,0 +1,273 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// revokeIssuer submits RevokeIssuer as an admin
+func (l *testLedger) revokeIssuer(issuerID string, policy string, cutoff string) error {
+	return l.invoke(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.RevokeIssuer(ctx, issuerID, policy, cutoff, "Accreditation withdrawn")
+		return err
+	})
+}
+
+// continueRevocation submits one ContinueIssuerRevocation chunk as an admin
+func (l *testLedger) continueRevocation(issuerID string, chunkSize int) *IssuerRevocation {
+	l.t.Helper()
+	var revocation *IssuerRevocation
+	l.mustInvoke(org1Admin, func(ctx contractapi.TransactionContextInterface) (err error) {
+		revocation, err = l.contract.ContinueIssuerRevocation(ctx, issuerID, chunkSize)
+		return err
+	})
+	return revocation
+}
+
+// finishRevocation calls ContinueIssuerRevocation until it is complete and returns the number of calls
+func (l *testLedger) finishRevocation(issuerID string, chunkSize int) int {
+	l.t.Helper()
+	for calls := 1; calls <= 20; calls++ {
+		if l.continueRevocation(issuerID, chunkSize).Complete {
+			return calls
+		}
+	}
+	l.t.Fatalf("revocation of %s does not complete", issuerID)
+	return 0
+}
+
+func TestIssuerRevocationCoversLegacyIDs(t *testing.T) {
+	ledger := newTestLedger(t)
+	lu := ledger.addIssuer("lu", "Org1MSP")
+	rtu := ledger.addIssuer("rtu", "Org2MSP")
+	current := []string{ledger.issue(lu, 1), ledger.issue(lu, 2)}
+
+	// Unmigrated credentials, a migration conflict and a legacy credential of another issuer
+	ledger.putLegacyCredential(legacyCredential(t, lu, 3))
+	ledger.putLegacyCredential(legacyCredential(t, lu, 4))
+	conflict := legacyCredential(t, lu, 1)
+	conflict.ID = "conflict1"
+	ledger.putLegacyCredential(conflict)
+	ledger.putLegacyCredential(legacyCredential(t, rtu, 5))
+
+	if err := ledger.revokeIssuer("lu", IssuerRevocationPolicyAll, ""); err != nil {
+		t.Fatal(err)
+	}
+	if calls := ledger.finishRevocation("lu", 2); calls != 3 {
+		t.Errorf("revoked 5 credentials in chunks of 2 with %d calls", calls)
+	}
+
+	revocation := ledger.issuer("lu").Revocation
+	if revocation.Examined != 5 || revocation.Revoked != 5 || !revocation.Legacy {
+		t.Errorf("revocation %+v", revocation)
+	}
+	for _, id := range append(current, "credential3", "credential4", "conflict1") {
+		if credential := ledger.credential(id); credential.Status != StatusRevoked || credential.Revocation.Reason != RevocationReasonIssuerRevoked {
+			t.Errorf("%s is %s", id, credential.Status)
+		}
+	}
+	if status := ledger.credential("credential5").Status; status != StatusValid {
+		t.Errorf("credential of another issuer is %s", status)
+	}
+}
+
+func TestIssuerRevocationPolicies(t *testing.T) {
+	tests := []struct {
+		policy    string
+		chunkSize int
+		revoked   []int // Credentials revoked by the cascade, by index
+	}{
+		{IssuerRevocationPolicyNone, 0, nil},
+		{IssuerRevocationPolicyAll, 1, []int{0, 1, 2, 3}},
+		{IssuerRevocationPolicyAll, 2, []int{0, 1, 2, 3}},
+		{IssuerRevocationPolicyAll, 0, []int{0, 1, 2, 3}},
+		{IssuerRevocationPolicyCutoff, 1, []int{2, 3}},
+		{IssuerRevocationPolicyCutoff, 2, []int{2, 3}},
+		{IssuerRevocationPolicyCutoff, 0, []int{2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s in chunks of %d", tt.policy, tt.chunkSize), func(t *testing.T) {
+			ledger := newTestLedger(t)
+			lu := ledger.addIssuer("lu", "Org1MSP")
+			rtu := ledger.addIssuer("rtu", "Org2MSP")
+
+			// Credentials 0 and 1 predate the cutoff, 0 is suspended and 4 was revoked by the issuer
+			var ids []string
+			var cutoff string
+			for n := 0; n < 5; n++ {
+				if n == 2 {
+					cutoff = ledger.now.Format(time.RFC3339)
+				}
+				ids = append(ids, ledger.issue(lu, n))
+			}
+			other := ledger.issue(rtu, 9)
+			ledger.mustInvoke(lu.client, func(ctx contractapi.TransactionContextInterface) error {
+				return suspend.submit(ledger.contract, ctx, ids[0])
+			})
+			ledger.mustInvoke(lu.client, func(ctx contractapi.TransactionContextInterface) error {
+				return revoke.submit(ledger.contract, ctx, ids[4])
+			})
+			if tt.policy != IssuerRevocationPolicyCutoff {
+				cutoff = ""
+			}
+
+			if err := ledger.revokeIssuer("lu", tt.policy, cutoff); err != nil {
+				t.Fatal(err)
+			}
+			issuer := ledger.issuer("lu")
+			if issuer.Status != "Revoked" || issuer.Revocation.Policy != tt.policy || issuer.Revocation.Complete != (tt.policy == IssuerRevocationPolicyNone) {
+				t.Fatalf("revoked issuer %+v with %+v", issuer, issuer.Revocation)
+			}
+			if ledger.event.name != EventIssuerRevoked {
+				t.Errorf("event %s", ledger.event.name)
+			}
+
+			// Every chunk resumes after the cursor of the previous one and examines at most chunkSize credentials
+			chunkSize := tt.chunkSize
+			if chunkSize == 0 {
+				chunkSize = DefaultRevocationChunkSize
+			}
+			var revokedIDs []string
+			var previous IssuerRevocation
+			for calls := 1; !previous.Complete; calls++ {
+				if calls > 10 {
+					t.Fatal("revocation does not complete")
+				}
+				revocation := ledger.continueRevocation("lu", tt.chunkSize)
+				if tt.policy == IssuerRevocationPolicyNone {
+					previous = *revocation
+					break
+				}
+				if examined := revocation.Examined - previous.Examined; examined > chunkSize || (examined < chunkSize && examined < 5-previous.Examined) {
+					t.Errorf("call %d examined %d credentials after %d", calls, examined, previous.Examined)
+				}
+				if revocation.Cursor < previous.Cursor {
+					t.Errorf("call %d moved the cursor back from %s to %s", calls, previous.Cursor, revocation.Cursor)
+				}
+				if !revocation.Complete && revocation.CompletedAt != "" {
+					t.Errorf("call %d: incomplete revocation completed at %s", calls, revocation.CompletedAt)
+				}
+
+				var event IssuerCredentialsRevokedEvent
+				if err := json.Unmarshal(ledger.event.payload, &event); err != nil || ledger.event.name != EventIssuerCredentialsRevoked {
+					t.Fatalf("call %d emitted %s: %v", calls, ledger.event.name, err)
+				}
+				if event.Complete != revocation.Complete {
+					t.Errorf("call %d event complete %v", calls, event.Complete)
+				}
+				revokedIDs = append(revokedIDs, event.CredentialIDs...)
+				previous = *revocation
+			}
+
+			stored := ledger.issuer("lu").Revocation
+			if !stored.Complete || stored.CompletedAt == "" || stored.Revoked != len(tt.revoked) {
+				t.Errorf("stored revocation %+v", stored)
+			}
+			if tt.policy != IssuerRevocationPolicyNone && stored.Examined != 5 {
+				t.Errorf("examined %d credentials, want 5", stored.Examined)
+			}
+
+			for n, id := range ids {
+				credential := ledger.credential(id)
+				cascaded := slices.Contains(tt.revoked, n)
+				if cascaded != slices.Contains(revokedIDs, id) {
+					t.Errorf("credential %d in the events: %v", n, !cascaded)
+				}
+				switch {
+				case cascaded:
+					if credential.Status != StatusRevoked || credential.Suspension != nil || credential.Revocation.Reason != RevocationReasonIssuerRevoked {
+						t.Errorf("credential %d is %s with %+v", n, credential.Status, credential.Revocation)
+					}
+				case n == 4:
+					if credential.Revocation.Reason == RevocationReasonIssuerRevoked {
+						t.Error("revocation by the issuer was overwritten")
+					}
+				case n == 0:
+					if credential.Status != StatusSuspended {
+						t.Errorf("credential %d is %s", n, credential.Status)
+					}
+				default:
+					if credential.Status != StatusValid {
+						t.Errorf("credential %d is %s", n, credential.Status)
+					}
+				}
+			}
+			if status := ledger.credential(other).Status; status != StatusValid {
+				t.Errorf("credential of another issuer is %s", status)
+			}
+
+			// A complete revocation is returned unchanged
+			event := ledger.event
+			if again := ledger.continueRevocation("lu", tt.chunkSize); *again != *stored || ledger.event != event {
+				t.Errorf("revocation changed after it completed: %+v", again)
+			}
+		})
+	}
+}
+
+func TestRevokeIssuerRejected(t *testing.T) {
+	ledger := newTestLedger(t)
+	lu := ledger.addIssuer("lu", "Org1MSP")
+
+	tests := []struct {
+		name   string
+		policy string
+		cutoff string
+		err    string
+	}{
+		{"unknown policy", "some", "", "unknown issuer revocation policy"},
+		{"cutoff with all", IssuerRevocationPolicyAll, "2025-01-01", "only accepted with the cutoff policy"},
+		{"cutoff with none", IssuerRevocationPolicyNone, "2025-01-01", "only accepted with the cutoff policy"},
+		{"missing cutoff", IssuerRevocationPolicyCutoff, "", "invalid cutoff"},
+		{"invalid cutoff", IssuerRevocationPolicyCutoff, "01.09.2025", "invalid cutoff"},
+	}
+	for _, tt := range tests {
+		if err := ledger.revokeIssuer("lu", tt.policy, tt.cutoff); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got %v, want %q", tt.name, err, tt.err)
+		}
+	}
+
+	err := ledger.invoke(lu.client, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := ledger.contract.RevokeIssuer(ctx, "lu", IssuerRevocationPolicyAll, "", "")
+		return err
+	})
+	if err == nil {
+		t.Error("issuer revoked itself")
+	}
+	err = ledger.invoke(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := ledger.contract.ContinueIssuerRevocation(ctx, "lu", 0)
+		return err
+	})
+	if err == nil || !strings.Contains(err.Error(), "is not revoked") {
+		t.Errorf("cascade of an active issuer: %v", err)
+	}
+
+	// A revoked issuer issues nothing and is not revoked twice
+	if err := ledger.revokeIssuer("lu", IssuerRevocationPolicyCutoff, "2025-09-01"); err != nil {
+		t.Fatal(err)
+	}
+	if cutoff := ledger.issuer("lu").Revocation.Cutoff; cutoff != "2025-09-01T00:00:00Z" {
+		t.Errorf("cutoff stored as %s", cutoff)
+	}
+	if err := ledger.revokeIssuer("lu", IssuerRevocationPolicyAll, ""); err == nil {
+		t.Error("issuer revoked twice")
+	}
+	credentialJSON, _ := json.Marshal(lu.newTestCredential(t, 1))
+	err = ledger.invoke(lu.client, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := ledger.contract.CreateCredential(ctx, string(credentialJSON))
+		return err
+	})
+	if err == nil {
+		t.Error("revoked issuer issued a credential")
+	}
+}
@@ -0,0 +1,132 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// Credential statuses
+const StatusValid = "Valid"
+const StatusSuspended = "Suspended"
+const StatusRevoked = "Revoked"
+
+// credentialTransitions lists the statuses a credential may move to from each status.
+// Revoked is terminal.
+var credentialTransitions = map[string][]string{
+	StatusValid:     {StatusSuspended, StatusRevoked},
+	StatusSuspended: {StatusValid, StatusRevoked},
+	StatusRevoked:   {},
+}
+
+// Suspension records a temporary hold placed on a credential
+type Suspension struct {
+	Note           string `json:"note"`           // Free-text explanation for verifiers
+	SuspendedAt    string `json:"suspendedAt"`    // RFC 3339 timestamp of the suspending transaction
+	SuspendedBy    string `json:"suspendedBy"`    // Client identity that submitted the suspension
+	SuspenderMSPID string `json:"suspenderMspId"` // Organization of the suspending client
+	TxID           string `json:"txId"`           // Transaction that suspended the credential
+}
+
+// checkTransition returns an error unless a credential may move from status to next
+func checkTransition(status string, next string) error {
+	allowed, ok := credentialTransitions[status]
+	if !ok {
+		return fmt.Errorf("unknown credential status %q", status)
+	}
+
+	for _, candidate := range allowed {
+		if candidate == next {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("credential cannot move from %s to %s", status, next)
+}
+
+// SuspendCredential temporarily invalidates a valid credential until it is reinstated or revoked
+func (s *SmartContract) SuspendCredential(ctx contractapi.TransactionContextInterface, id string, note string) error {
+	credential, err := s.readOwnedCredential(ctx, id)
+	if err != nil {
+		return err
+	}
+	id = credentialID(credential)
+
+	if err := checkTransition(credential.Status, StatusSuspended); err != nil {
+		return err
+	}
+
+	caller, err := getCaller(ctx)
+	if err != nil {
+		return err
+	}
+
+	suspendedAt, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	credential.Status = StatusSuspended
+	credential.Suspension = &Suspension{
+		Note:           note,
+		SuspendedAt:    suspendedAt,
+		SuspendedBy:    caller.ID,
+		SuspenderMSPID: caller.MSPID,
+		TxID:           ctx.GetStub().GetTxID(),
+	}
+
+	if err := s.putCredential(ctx, id, credential); err != nil {
+		return err
+	}
+
+	return emitCredentialEvent(ctx, EventCredentialSuspended, id, credential)
+}
+
+// ReinstateCredential returns a suspended credential to the valid status
+func (s *SmartContract) ReinstateCredential(ctx contractapi.TransactionContextInterface, id string) error {
+	credential, err := s.readOwnedCredential(ctx, id)
+	if err != nil {
+		return err
+	}
+	id = credentialID(credential)
+
+	if credential.Status != StatusSuspended {
+		return fmt.Errorf("the credential %s is %s, only suspended credentials can be reinstated", id, credential.Status)
+	}
+
+	if err := checkTransition(credential.Status, StatusValid); err != nil {
+		return err
+	}
+
+	credential.Status = StatusValid
+	credential.Suspension = nil
+
+	if err := s.putCredential(ctx, id, credential); err != nil {
+		return err
+	}
+
+	return emitCredentialEvent(ctx, EventCredentialReinstated, id, credential)
+}
+
+// readOwnedCredential reads a credential and asserts that the caller acts for its issuer
+func (s *SmartContract) readOwnedCredential(ctx contractapi.TransactionContextInterface, id string) (*Credential, error) {
+	credential, err := s.ReadCredential(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	issuer, err := s.ReadIssuer(ctx, credential.IssuerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := assertIssuerOwner(ctx, issuer); err != nil {
+		return nil, err
+	}
+
+	return credential, nil
+}
@@ -0,0 +1,138 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+func TestCheckTransition(t *testing.T) {
+	tests := []struct {
+		from    string
+		to      string
+		allowed bool
+	}{
+		{StatusValid, StatusSuspended, true},
+		{StatusValid, StatusRevoked, true},
+		{StatusValid, StatusValid, false},
+		{StatusSuspended, StatusValid, true},
+		{StatusSuspended, StatusRevoked, true},
+		{StatusSuspended, StatusSuspended, false},
+		{StatusRevoked, StatusValid, false},
+		{StatusRevoked, StatusSuspended, false},
+		{StatusRevoked, StatusRevoked, false},
+		{"Expired", StatusValid, false},
+	}
+	for _, tt := range tests {
+		if err := checkTransition(tt.from, tt.to); (err == nil) != tt.allowed {
+			t.Errorf("checkTransition(%s, %s) = %v, want allowed %v", tt.from, tt.to, err, tt.allowed)
+		}
+	}
+}
+
+// lifecycleStep is a status transaction submitted by the credential's issuer
+type lifecycleStep string
+
+const (
+	suspend   lifecycleStep = "suspend"
+	reinstate lifecycleStep = "reinstate"
+	revoke    lifecycleStep = "revoke"
+)
+
+func (step lifecycleStep) submit(s *SmartContract, ctx contractapi.TransactionContextInterface, id string) error {
+	switch step {
+	case suspend:
+		return s.SuspendCredential(ctx, id, "Under investigation")
+	case reinstate:
+		return s.ReinstateCredential(ctx, id)
+	default:
+		return s.RevokeCredential(ctx, id, "error", "Issued by mistake")
+	}
+}
+
+func TestCredentialLifecycle(t *testing.T) {
+	tests := []struct {
+		name   string
+		steps  []lifecycleStep
+		status string // After the last step, which is the only one allowed to fail
+		err    string // Expected error of the last step
+		event  string // Event of the last successful transaction
+	}{
+		{"suspend", []lifecycleStep{suspend}, StatusSuspended, "", EventCredentialSuspended},
+		{"reinstate", []lifecycleStep{suspend, reinstate}, StatusValid, "", EventCredentialReinstated},
+		{"suspend again", []lifecycleStep{suspend, reinstate, suspend}, StatusSuspended, "", EventCredentialSuspended},
+		{"revoke", []lifecycleStep{revoke}, StatusRevoked, "", EventCredentialRevoked},
+		{"revoke suspended", []lifecycleStep{suspend, revoke}, StatusRevoked, "", EventCredentialRevoked},
+		{"suspend suspended", []lifecycleStep{suspend, suspend}, StatusSuspended, "cannot move from Suspended to Suspended", EventCredentialSuspended},
+		{"reinstate valid", []lifecycleStep{reinstate}, StatusValid, "only suspended credentials can be reinstated", EventCredentialIssued},
+		{"reinstate revoked", []lifecycleStep{suspend, revoke, reinstate}, StatusRevoked, "only suspended credentials can be reinstated", EventCredentialRevoked},
+		{"suspend revoked", []lifecycleStep{revoke, suspend}, StatusRevoked, "cannot move from Revoked to Suspended", EventCredentialRevoked},
+		{"revoke revoked", []lifecycleStep{revoke, revoke}, StatusRevoked, "cannot move from Revoked to Revoked", EventCredentialRevoked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := newTestLedger(t)
+			lu := ledger.addIssuer("lu", "Org1MSP")
+			id := ledger.issue(lu, 1)
+
+			var err error
+			for i, step := range tt.steps {
+				err = ledger.invoke(lu.client, func(ctx contractapi.TransactionContextInterface) error {
+					return step.submit(ledger.contract, ctx, id)
+				})
+				if err != nil && i < len(tt.steps)-1 {
+					t.Fatalf("%s: %v", step, err)
+				}
+			}
+			if tt.err == "" && err != nil {
+				t.Fatalf("%s: %v", tt.steps[len(tt.steps)-1], err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("%s: got %v, want %q", tt.steps[len(tt.steps)-1], err, tt.err)
+			}
+
+			credential := ledger.credential(id)
+			if credential.Status != tt.status {
+				t.Errorf("status %s, want %s", credential.Status, tt.status)
+			}
+			if (credential.Suspension != nil) != (credential.Status == StatusSuspended) {
+				t.Errorf("%s credential has suspension %+v", credential.Status, credential.Suspension)
+			}
+			if (credential.Revocation != nil) != (credential.Status == StatusRevoked) {
+				t.Errorf("%s credential has revocation %+v", credential.Status, credential.Revocation)
+			}
+			if ledger.event.name != tt.event {
+				t.Errorf("last event %s, want %s", ledger.event.name, tt.event)
+			}
+			for status := range credentialTransitions {
+				if ledger.indexed(StatusCredentialIndex, status, id) != (status == tt.status) {
+					t.Errorf("%s entry of %s for a %s credential is wrong", status, StatusCredentialIndex, tt.status)
+				}
+			}
+		})
+	}
+}
+
+func TestCredentialLifecycleOnlyByItsIssuer(t *testing.T) {
+	ledger := newTestLedger(t)
+	lu := ledger.addIssuer("lu", "Org1MSP")
+	rtu := ledger.addIssuer("rtu", "Org2MSP")
+	id := ledger.issue(lu, 1)
+
+	for _, step := range []lifecycleStep{suspend, revoke} {
+		err := ledger.invoke(rtu.client, func(ctx contractapi.TransactionContextInterface) error {
+			return step.submit(ledger.contract, ctx, id)
+		})
+		if err == nil || !strings.Contains(err.Error(), "not authorized to act for issuer lu") {
+			t.Errorf("%s by another issuer: %v", step, err)
+		}
+	}
+	if status := ledger.credential(id).Status; status != StatusValid {
+		t.Errorf("status %s after rejected transitions", status)
+	}
+}
@@ -0,0 +1,163 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// Transient map key carrying GraduateDetails JSON
+const GraduateDetailsTransientKey = "graduateDetails"
+
+// Private data collection names are the prefix followed by the issuer ID, see collections_config.json
+const GraduateDetailsCollectionPrefix = "graduateDetails_"
+
+const minSaltLength = 16
+
+// GraduateDetails holds the sensitive part of a diploma, kept in the issuer's private data collection.
+// Field order matters, the salted hash is computed over this struct's JSON encoding.
+type GraduateDetails struct {
+	FullName     string        `json:"fullName"`
+	PersonalCode string        `json:"personalCode"`
+	ThesisTitle  string        `json:"thesisTitle"`
+	Grades       []CourseGrade `json:"grades"`
+	Salt         string        `json:"salt,omitempty" metadata:",optional"` // Hex salt, never part of the hashed JSON
+}
+
+type CourseGrade struct {
+	Course  string  `json:"course"`
+	Grade   string  `json:"grade"`
+	Credits float64 `json:"credits"`
+}
+
+// graduateDetailsCollection returns the private data collection of an issuer
+func graduateDetailsCollection(issuerID string) string {
+	return GraduateDetailsCollectionPrefix + issuerID
+}
+
+// graduateDetailsKey returns the private data key of a credential. Migrated credentials keep
+// the key of their legacy ID, private data cannot be moved by a non-member endorser.
+func graduateDetailsKey(credential *Credential) string {
+	if credential.LegacyID != "" {
+		return CredentialKey + credential.LegacyID
+	}
+	return CredentialKey + credentialID(credential)
+}
+
+// HashGraduateDetails returns hex(SHA-256(salt || JSON of details without salt)).
+// This is the value stored as graduateDetailsHash on the public credential.
+func HashGraduateDetails(details *GraduateDetails) (string, error) {
+	salt, err := hex.DecodeString(details.Salt)
+	if err != nil {
+		return "", fmt.Errorf("graduate details salt must be hex: %v", err)
+	}
+	if len(salt) < minSaltLength {
+		return "", fmt.Errorf("graduate details salt must be at least %d bytes", minSaltLength)
+	}
+
+	unsalted := *details
+	unsalted.Salt = ""
+	detailsJSON, err := json.Marshal(unsalted)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	h.Write(salt)
+	h.Write(detailsJSON)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// PutGraduateDetails stores the graduate details passed in the transient map in the issuer's
+// private data collection. The details must match the graduateDetailsHash already recorded
+// on the public credential, so the public ledger commits to exactly this data.
+func (s *SmartContract) PutGraduateDetails(ctx contractapi.TransactionContextInterface, id string) error {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("failed to read transient data: %v", err)
+	}
+
+	detailsJSON, ok := transient[GraduateDetailsTransientKey]
+	if !ok || len(detailsJSON) == 0 {
+		return fmt.Errorf("%s must be passed in the transient map", GraduateDetailsTransientKey)
+	}
+
+	var details GraduateDetails
+	if err := json.Unmarshal(detailsJSON, &details); err != nil {
+		return fmt.Errorf("failed to unmarshal graduate details: %v", err)
+	}
+
+	credential, err := s.readOwnedCredential(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if credential.Status == StatusRevoked {
+		return fmt.Errorf("the credential %s is revoked", id)
+	}
+
+	hash, err := HashGraduateDetails(&details)
+	if err != nil {
+		return err
+	}
+
+	if credential.GraduateDetailsHash == "" {
+		return fmt.Errorf("the credential %s has no graduateDetailsHash to commit to", id)
+	}
+	if hash != credential.GraduateDetailsHash {
+		return fmt.Errorf("graduate details do not match graduateDetailsHash of credential %s", id)
+	}
+
+	detailsBytes, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutPrivateData(graduateDetailsCollection(credential.IssuerID), graduateDetailsKey(credential), detailsBytes)
+}
+
+// ReadGraduateDetails returns the private graduate details of a credential to its issuer or an admin.
+// The peer must be a member of the issuer's collection to hold the data.
+func (s *SmartContract) ReadGraduateDetails(ctx contractapi.TransactionContextInterface, id string) (*GraduateDetails, error) {
+	credential, err := s.ReadCredential(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	caller, err := getCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !caller.Admin {
+		issuer, err := s.ReadIssuer(ctx, credential.IssuerID)
+		if err != nil {
+			return nil, err
+		}
+		if err := assertIssuerOwner(ctx, issuer); err != nil {
+			return nil, err
+		}
+	}
+
+	detailsBytes, err := ctx.GetStub().GetPrivateData(graduateDetailsCollection(credential.IssuerID), graduateDetailsKey(credential))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private data: %v", err)
+	}
+	if detailsBytes == nil {
+		return nil, fmt.Errorf("no graduate details stored for credential %s", id)
+	}
+
+	var details GraduateDetails
+	if err := json.Unmarshal(detailsBytes, &details); err != nil {
+		return nil, err
+	}
+
+	return &details, nil
+}
@@ -0,0 +1,174 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+	"github.com/hyperledger/fabric-protos-go-apiv2/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+)
+
+const DefaultPageSize = 20
+const MaxPageSize = 200
+
+// maxScannedPerQuery bounds how many ledger records one paginated query reads
+// while looking for matches, so sparse filters cannot scan the whole ledger at once.
+const maxScannedPerQuery = 2000
+
+// CredentialFilter narrows down paginated credential queries. Empty fields match everything.
+type CredentialFilter struct {
+	IssuerID       string `json:"issuerId"`
+	Status         string `json:"status"`
+	CredentialType string `json:"credentialType"`
+	IssuedFrom     string `json:"issuedFrom"` // Inclusive, YYYY-MM-DD
+	IssuedTo       string `json:"issuedTo"`   // Inclusive, YYYY-MM-DD
+}
+
+// CredentialPage is one page of a paginated credential query.
+// An empty bookmark means there are no further results.
+type CredentialPage struct {
+	Credentials []*Credential `json:"credentials"`
+	Count       int32         `json:"count"`
+	Bookmark    string        `json:"bookmark"`
+}
+
+// Matches reports whether a credential satisfies every set filter field
+func (f *CredentialFilter) Matches(credential *Credential) bool {
+	if f.IssuerID != "" && credential.IssuerID != f.IssuerID {
+		return false
+	}
+	if f.Status != "" && credential.Status != f.Status {
+		return false
+	}
+	if f.CredentialType != "" && credential.CredentialType != f.CredentialType {
+		return false
+	}
+	// ISO dates compare correctly as strings
+	if f.IssuedFrom != "" && credential.DiplomaMetadata.IssueDate < f.IssuedFrom {
+		return false
+	}
+	if f.IssuedTo != "" && credential.DiplomaMetadata.IssueDate > f.IssuedTo {
+		return false
+	}
+	return true
+}
+
+func (f *CredentialFilter) validate() error {
+	for _, date := range []string{f.IssuedFrom, f.IssuedTo} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse(time.DateOnly, date); err != nil {
+			return fmt.Errorf("invalid issue date %q, expected YYYY-MM-DD", date)
+		}
+	}
+	if f.Status != "" {
+		if _, ok := credentialTransitions[f.Status]; !ok {
+			return fmt.Errorf("unknown credential status %q", f.Status)
+		}
+	}
+	return nil
+}
+
+// QueryCredentialsPaginated returns up to pageSize credentials matching filterJSON, starting at bookmark.
+// A page may hold fewer than pageSize credentials while the bookmark is still set when
+// the scan limit was reached, callers should keep following the bookmark until it is empty.
+func (s *SmartContract) QueryCredentialsPaginated(ctx contractapi.TransactionContextInterface, filterJSON string, pageSize int32, bookmark string) (*CredentialPage, error) {
+	var filter CredentialFilter
+	if filterJSON != "" {
+		if err := json.Unmarshal([]byte(filterJSON), &filter); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal filter: %v", err)
+		}
+	}
+	if err := filter.validate(); err != nil {
+		return nil, err
+	}
+
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	if pageSize > MaxPageSize {
+		pageSize = MaxPageSize
+	}
+
+	page := &CredentialPage{Credentials: []*Credential{}}
+	scanned := 0
+
+	for {
+		// With an issuer filter only that issuer's entries in the issuer~credential index are scanned
+		var resultsIterator shim.StateQueryIteratorInterface
+		var metadata *peer.QueryResponseMetadata
+		var err error
+		if filter.IssuerID != "" {
+			resultsIterator, metadata, err = ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(IssuerCredentialIndex, []string{filter.IssuerID}, pageSize, bookmark)
+		} else {
+			resultsIterator, metadata, err = ctx.GetStub().GetStateByRangeWithPagination(CredentialKey, CredentialKeyRangeEnd, pageSize, bookmark)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		next := metadata.GetBookmark()
+		for resultsIterator.HasNext() {
+			queryResponse, err := resultsIterator.Next()
+			if err != nil {
+				resultsIterator.Close()
+				return nil, err
+			}
+
+			// Page is full, resume from the first record that was not consumed
+			if page.Count == pageSize || scanned == maxScannedPerQuery {
+				next = queryResponse.Key
+				break
+			}
+			scanned++
+
+			credential, err := s.resolveQueryResult(ctx, filter.IssuerID != "", queryResponse)
+			if err != nil {
+				resultsIterator.Close()
+				return nil, err
+			}
+
+			if filter.Matches(credential) {
+				page.Credentials = append(page.Credentials, credential)
+				page.Count++
+			}
+		}
+		resultsIterator.Close()
+
+		bookmark = next
+		if bookmark == "" || page.Count == pageSize || scanned == maxScannedPerQuery {
+			break
+		}
+	}
+
+	page.Bookmark = bookmark
+	return page, nil
+}
+
+// resolveQueryResult decodes a credential record, or reads the credential an index entry points to
+func (s *SmartContract) resolveQueryResult(ctx contractapi.TransactionContextInterface, indexed bool, queryResponse *queryresult.KV) (*Credential, error) {
+	if indexed {
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(attributes) != 2 {
+			return nil, fmt.Errorf("malformed index key %q", queryResponse.Key)
+		}
+		return s.ReadCredential(ctx, attributes[1])
+	}
+
+	var credential Credential
+	if err := json.Unmarshal(queryResponse.Value, &credential); err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
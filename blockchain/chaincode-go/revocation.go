@@ -0,0 +1,45 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Reason codes accepted when revoking a credential
+const RevocationReasonFraud = "fraud"
+const RevocationReasonError = "error"
+const RevocationReasonSuperseded = "superseded"
+const RevocationReasonWithdrawn = "withdrawn"
+
+// Reason recorded on credentials revoked together with their issuer, it cannot be given to RevokeCredential
+const RevocationReasonIssuerRevoked = "issuer_revoked"
+
+// Revocation records why, when and by whom a credential was revoked
+type Revocation struct {
+	Reason        string `json:"reason"`        // One of the RevocationReason codes
+	Note          string `json:"note"`          // Free-text explanation for verifiers
+	RevokedAt     string `json:"revokedAt"`     // RFC 3339 timestamp of the revoking transaction
+	RevokedBy     string `json:"revokedBy"`     // Client identity that submitted the revocation
+	RevokerMSPID  string `json:"revokerMspId"`  // Organization of the revoking client
+	RevokerIssuer string `json:"revokerIssuer"` // issuerId attribute of the revoking client
+	TxID          string `json:"txId"`          // Transaction that revoked the credential
+}
+
+// validateRevocationReason normalizes reason and rejects unknown codes
+func validateRevocationReason(reason string) (string, error) {
+	reason = strings.ToLower(strings.TrimSpace(reason))
+
+	switch reason {
+	case RevocationReasonFraud, RevocationReasonError, RevocationReasonSuperseded, RevocationReasonWithdrawn:
+		return reason, nil
+	case "":
+		return "", fmt.Errorf("revocation reason is required")
+	default:
+		return "", fmt.Errorf("unknown revocation reason %q, expected one of %s, %s, %s, %s",
+			reason, RevocationReasonFraud, RevocationReasonError, RevocationReasonSuperseded, RevocationReasonWithdrawn)
+	}
+}
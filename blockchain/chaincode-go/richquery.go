@@ -0,0 +1,190 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// queryableFields lists the credential fields a rich query selector may reference.
+// Dotted paths address nested DiplomaMetadata fields.
+var queryableFields = map[string]bool{
+	"issuerId":                       true,
+	"status":                         true,
+	"credentialType":                 true,
+	"diplomaHash":                    true,
+	"diplomaMetadata.universityName": true,
+	"diplomaMetadata.degreeName":     true,
+	"diplomaMetadata.issueDate":      true,
+	"diplomaMetadata.expiryDate":     true,
+}
+
+// queryOperators lists the Mango operators a selector may use. $regex and $where style
+// operators are left out so a query cannot force expensive scans on the peer.
+var queryOperators = map[string]bool{
+	"$eq":  true,
+	"$ne":  true,
+	"$gt":  true,
+	"$gte": true,
+	"$lt":  true,
+	"$lte": true,
+	"$in":  true,
+	"$nin": true,
+}
+
+// QueryCredentials runs a CouchDB Mango selector over credentials and returns one page of results.
+// Only whitelisted fields and comparison operators are accepted. Requires CouchDB as state database.
+func (s *SmartContract) QueryCredentials(ctx contractapi.TransactionContextInterface, selectorJSON string, pageSize int32, bookmark string) (*CredentialPage, error) {
+	var selector map[string]interface{}
+	if err := json.Unmarshal([]byte(selectorJSON), &selector); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal selector: %v", err)
+	}
+
+	if err := validateSelector(selector, ""); err != nil {
+		return nil, err
+	}
+
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	if pageSize > MaxPageSize {
+		pageSize = MaxPageSize
+	}
+
+	// Restrict the query to credential documents, issuers share the namespace
+	query := map[string]interface{}{
+		"selector": map[string]interface{}{
+			"$and": []interface{}{
+				map[string]interface{}{"_id": map[string]interface{}{"$gt": CredentialKey, "$lt": CredentialKeyRangeEnd}},
+				selector,
+			},
+		},
+	}
+
+	queryJSON, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(string(queryJSON), pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	page := &CredentialPage{Credentials: []*Credential{}}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		credential, err := s.resolveQueryResult(ctx, false, queryResponse)
+		if err != nil {
+			return nil, err
+		}
+		page.Credentials = append(page.Credentials, credential)
+		page.Count++
+	}
+
+	// CouchDB keeps returning a bookmark after the last page, clear it once a page comes back short
+	if page.Count == pageSize {
+		page.Bookmark = metadata.GetBookmark()
+	}
+
+	return page, nil
+}
+
+// validateSelector walks a Mango selector and rejects fields and operators outside the whitelist.
+// path is the dotted field path of the object being validated, empty at the top level.
+func validateSelector(selector map[string]interface{}, path string) error {
+	if len(selector) == 0 {
+		return fmt.Errorf("selector must not be empty")
+	}
+
+	for key, value := range selector {
+		switch {
+		case key == "$and" || key == "$or":
+			if path != "" {
+				return fmt.Errorf("%s is only allowed outside of field conditions", key)
+			}
+			clauses, ok := value.([]interface{})
+			if !ok || len(clauses) == 0 {
+				return fmt.Errorf("%s expects a non-empty array of selectors", key)
+			}
+			for _, clause := range clauses {
+				clauseSelector, ok := clause.(map[string]interface{})
+				if !ok {
+					return fmt.Errorf("%s expects a non-empty array of selectors", key)
+				}
+				if err := validateSelector(clauseSelector, ""); err != nil {
+					return err
+				}
+			}
+
+		case strings.HasPrefix(key, "$"):
+			if !queryOperators[key] {
+				return fmt.Errorf("operator %s is not allowed", key)
+			}
+			if path == "" || !queryableFields[path] {
+				return fmt.Errorf("operator %s must be applied to a queryable field", key)
+			}
+			if err := validateOperand(key, value); err != nil {
+				return err
+			}
+
+		default:
+			field := key
+			if path != "" {
+				field = path + "." + key
+			}
+
+			if nested, ok := value.(map[string]interface{}); ok {
+				if err := validateSelector(nested, field); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if !queryableFields[field] {
+				return fmt.Errorf("field %s is not queryable", field)
+			}
+			if err := validateOperand("$eq", value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateOperand accepts scalar operands, and arrays of scalars for $in and $nin
+func validateOperand(operator string, value interface{}) error {
+	switch v := value.(type) {
+	case string, float64, bool:
+		if operator == "$in" || operator == "$nin" {
+			return fmt.Errorf("%s expects an array", operator)
+		}
+		return nil
+	case []interface{}:
+		if operator != "$in" && operator != "$nin" {
+			return fmt.Errorf("%s expects a single value", operator)
+		}
+		for _, item := range v {
+			switch item.(type) {
+			case string, float64, bool:
+			default:
+				return fmt.Errorf("%s values must be scalars", operator)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported value for %s", operator)
+	}
+}
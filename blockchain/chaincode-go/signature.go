@@ -0,0 +1,161 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// SignatureVerification is the outcome of re-checking a stored credential's issuer signature
+type SignatureVerification struct {
+	CredentialID string `json:"credentialId"`
+	IssuerID     string `json:"issuerId"`
+	Valid        bool   `json:"valid"`
+	IssuerStatus string `json:"issuerStatus"`                          // "Revoked" when the issuer lost its accreditation, even if the signature is valid
+	KeyID        string `json:"keyId,omitempty" metadata:",optional"`  // Issuer key the signature matched
+	Reason       string `json:"reason,omitempty" metadata:",optional"` // Why the signature was rejected
+}
+
+// VerifyCredentialSignature checks the stored issuer signature of a credential
+// against the issuer key that was active when the signature was recorded.
+func (s *SmartContract) VerifyCredentialSignature(ctx contractapi.TransactionContextInterface, id string) (*SignatureVerification, error) {
+	credential, err := s.ReadCredential(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	issuer, err := s.ReadIssuer(ctx, credential.IssuerID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SignatureVerification{
+		CredentialID: id,
+		IssuerID:     credential.IssuerID,
+		Valid:        true,
+		IssuerStatus: issuer.Status,
+	}
+
+	key, err := verifySignatureAt(issuer, credential)
+	if key != nil {
+		result.KeyID = key.ID
+	}
+	if err != nil {
+		result.Valid = false
+		result.Reason = err.Error()
+	}
+
+	return result, nil
+}
+
+// verifyIssuerSignature checks that signature was produced over diplomaHash by the owner of publicKey.
+// The signed message is the diplomaHash string itself. ECDSA and RSA signatures are
+// computed over its SHA-256 digest, Ed25519 signs the message directly.
+func verifyIssuerSignature(publicKey string, diplomaHash string, signature string) error {
+	key, err := parsePublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+
+	sig, err := decodeSignature(signature)
+	if err != nil {
+		return err
+	}
+
+	message := []byte(diplomaHash)
+	digest := sha256.Sum256(message)
+
+	switch pub := key.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, digest[:], sig) {
+			return fmt.Errorf("ECDSA signature does not match diploma hash")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(pub, message, sig) {
+			return fmt.Errorf("Ed25519 signature does not match diploma hash")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig); err != nil {
+			if err := rsa.VerifyPSS(pub, crypto.SHA256, digest[:], sig, nil); err != nil {
+				return fmt.Errorf("RSA signature does not match diploma hash")
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", key)
+	}
+
+	return nil
+}
+
+// parsePublicKey accepts a PKIX (SubjectPublicKeyInfo) key either PEM armored or as base64 DER
+func parsePublicKey(publicKey string) (crypto.PublicKey, error) {
+	der, err := decodePublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %v", err)
+	}
+
+	switch pub := key.(type) {
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("unsupported ECDSA curve %s, only P-256 is accepted", pub.Curve.Params().Name)
+		}
+	case ed25519.PublicKey, *rsa.PublicKey:
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", key)
+	}
+
+	return key, nil
+}
+
+// decodePublicKey removes the PEM or base64 armor of a public key
+func decodePublicKey(publicKey string) ([]byte, error) {
+	if block, _ := pem.Decode([]byte(publicKey)); block != nil {
+		return block.Bytes, nil
+	}
+
+	der, err := base64.StdEncoding.DecodeString(strings.TrimSpace(publicKey))
+	if err != nil {
+		return nil, fmt.Errorf("public key is neither PEM nor base64 DER: %v", err)
+	}
+
+	return der, nil
+}
+
+// decodeSignature accepts hex (as produced by openssl dgst | xxd -p) or base64 signatures
+func decodeSignature(signature string) ([]byte, error) {
+	signature = strings.TrimSpace(signature)
+	if signature == "" {
+		return nil, fmt.Errorf("issuer signature is empty")
+	}
+
+	if sig, err := hex.DecodeString(signature); err == nil {
+		return sig, nil
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return nil, fmt.Errorf("issuer signature is neither hex nor base64")
+	}
+
+	return sig, nil
+}
@@ -0,0 +1,120 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// rotate replaces the signing key of issuer through RotateIssuerKey and returns the ID of the key it retired
+func (l *testLedger) rotate(issuer *testIssuer, compromised bool) string {
+	l.t.Helper()
+	previous := keyFingerprint(l.issuer(issuer.id).PublicKey)
+	key, publicKey := newTestKey(l.t)
+	l.mustInvoke(issuer.client, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.RotateIssuerKey(ctx, issuer.id, publicKey, compromised)
+		return err
+	})
+	issuer.key = key
+	return previous
+}
+
+// verify runs VerifyCredentialSignature for a credential
+func (l *testLedger) verify(id string) *SignatureVerification {
+	l.t.Helper()
+	var verification *SignatureVerification
+	l.mustInvoke(testReader, func(ctx contractapi.TransactionContextInterface) (err error) {
+		verification, err = l.contract.VerifyCredentialSignature(ctx, id)
+		return err
+	})
+	return verification
+}
+
+func TestSignatureVerifiesWithKeyValidAtSigning(t *testing.T) {
+	ledger := newTestLedger(t)
+	lu := ledger.addIssuer("lu", "Org1MSP")
+	signedBefore := ledger.issue(lu, 1)
+	retired := ledger.rotate(lu, false)
+	signedAfter := ledger.issue(lu, 2)
+
+	verification := ledger.verify(signedBefore)
+	if !verification.Valid || verification.KeyID != retired {
+		t.Errorf("credential signed before the rotation: %+v, want valid with key %s", verification, retired)
+	}
+	active := keyFingerprint(ledger.issuer("lu").PublicKey)
+	if verification := ledger.verify(signedAfter); !verification.Valid || verification.KeyID != active {
+		t.Errorf("credential signed after the rotation: %+v, want valid with key %s", verification, active)
+	}
+
+	keys := ledger.issuer("lu").Keys
+	if len(keys) != 2 || keys[0].Status != KeyStatusRetired || keys[0].ValidTo == "" || keys[1].ValidFrom != keys[0].ValidTo {
+		t.Errorf("keys after the rotation %+v", keys)
+	}
+}
+
+func TestSignatureOfRetiredKeyRejectedAfterRotation(t *testing.T) {
+	ledger := newTestLedger(t)
+	lu := ledger.addIssuer("lu", "Org1MSP")
+	oldKey := lu.key
+	ledger.rotate(lu, false)
+
+	// New credentials must be signed with the active key
+	credential := lu.newTestCredential(t, 1)
+	credential.IssuerSignature = signHash(t, oldKey, credential.DiplomaHash)
+	credentialJSON, _ := json.Marshal(credential)
+	err := ledger.invoke(lu.client, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := ledger.contract.CreateCredential(ctx, string(credentialJSON))
+		return err
+	})
+	if err == nil || !strings.Contains(err.Error(), "invalid issuer signature") {
+		t.Errorf("credential signed with the retired key: %v", err)
+	}
+
+	// A signature of the new key claiming a signing time before the rotation matches no key
+	backdated := *lu.newTestCredential(t, 2)
+	backdated.ID = "credential2"
+	backdated.Status = StatusValid
+	backdated.SignedAt = ledger.issuer("lu").Keys[0].ValidFrom
+	ledger.putLegacyCredential(backdated)
+	if verification := ledger.verify("credential2"); verification.Valid {
+		t.Errorf("backdated signature of the new key verified with key %s", verification.KeyID)
+	}
+}
+
+func TestSignatureOfCompromisedKeyFails(t *testing.T) {
+	ledger := newTestLedger(t)
+	lu := ledger.addIssuer("lu", "Org1MSP")
+	id := ledger.issue(lu, 1)
+	compromised := ledger.rotate(lu, true)
+
+	verification := ledger.verify(id)
+	if verification.Valid || verification.KeyID != compromised || !strings.Contains(verification.Reason, "compromised") {
+		t.Errorf("credential signed with a compromised key: %+v", verification)
+	}
+	if status := ledger.issuer("lu").Keys[0].Status; status != KeyStatusCompromised {
+		t.Errorf("key is %s", status)
+	}
+
+	// Reissuing with the new key makes the credential verify again
+	reissued := ledger.issue(lu, 2)
+	if verification := ledger.verify(reissued); !verification.Valid {
+		t.Errorf("credential signed with the new key: %+v", verification)
+	}
+}
+
+func TestSignatureWithoutSigningTimeUsesFirstKey(t *testing.T) {
+	ledger := newTestLedger(t)
+	lu := ledger.addIssuer("lu", "Org1MSP")
+	ledger.putLegacyCredential(legacyCredential(t, lu, 1))
+	first := ledger.rotate(lu, false)
+
+	if verification := ledger.verify("credential1"); !verification.Valid || verification.KeyID != first {
+		t.Errorf("credential without signedAt: %+v, want valid with key %s", verification, first)
+	}
+}
@@ -7,6 +7,7 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
 )
@@ -25,14 +26,23 @@ type SmartContract struct {
 // Insert struct field in alphabetic order => to achieve determinism across languages
 // golang keeps the order when marshal to json but doesn't order automatically
 type Credential struct {
-	ID                string          `json:"id"`                // Transaction identifier
-	DiplomaHash       string          `json:"diplomaHash"`       // SHA-256 hash of diploma file
-	GraduatePublicKey string          `json:"graduatePublicKey"` // Graduate's public key
+	ID                string          `json:"id"`                                        // Transaction identifier
+	DiplomaHash       string          `json:"diplomaHash"`                               // SHA-256 hash of diploma file, or a salted commitment to it
+	HashScheme        string          `json:"hashScheme,omitempty" metadata:",optional"` // See HashSchemeSHA256, empty for legacy credentials
+	GraduatePublicKey string          `json:"graduatePublicKey"`                         // Graduate's public key
 	IssuerID          string          `json:"issuerId"`
-	IssuerSignature   string          `json:"issuerSignature"` // Issuer's signature of diplomaHash
-	DiplomaMetadata   DiplomaMetadata `json:"diplomaMetadata"` // Non-sensitive metadata
-	Status            string          `json:"status"`          // "Valid" or "Revoked"
-	CredentialType    string          `json:"credentialType"`  // Type of credential
+	IssuerSignature   string          `json:"issuerSignature"`                           // Issuer's signature of diplomaHash
+	DiplomaMetadata   DiplomaMetadata `json:"diplomaMetadata"`                           // Non-sensitive metadata
+	Status            string          `json:"status"`                                    // "Valid", "Suspended" or "Revoked"
+	CredentialType    string          `json:"credentialType"`                            // Type of credential
+	Revocation        *Revocation     `json:"revocation,omitempty" metadata:",optional"` // Set once the credential is revoked
+	Suspension        *Suspension     `json:"suspension,omitempty" metadata:",optional"` // Set while the credential is suspended
+	// Salted hash of the graduate details kept in the issuer's private data collection
+	GraduateDetailsHash string `json:"graduateDetailsHash,omitempty" metadata:",optional"`
+	// ID before MigrateCredentialIDs re-keyed the credential, still resolvable as an alias
+	LegacyID string `json:"legacyId,omitempty" metadata:",optional"`
+	// Ledger time the issuer signature was accepted, selects the issuer key it is verified with
+	SignedAt string `json:"signedAt,omitempty" metadata:",optional"`
 }
 
 type DiplomaMetadata struct {
@@ -45,17 +55,40 @@ type DiplomaMetadata struct {
 type Issuer struct {
 	ID        string `json:"id"`
 	Name      string `json:"name"`
+	MSPID     string `json:"mspId"`     // Organization whose clients may act for the issuer
 	Status    string `json:"status"`    // "Active" or "Revoked"
-	PublicKey string `json:"publicKey"` // Issuer's public key for verification
+	PublicKey string `json:"publicKey"` // Active signing key, new signatures are verified with it
+	// Every signing key with the period it was active, oldest first
+	Keys []IssuerSigningKey `json:"keys,omitempty" metadata:",optional"`
+	// Keys of the proofs on exported verifiable credentials, oldest first. They never sign ledger credentials.
+	AssertionKeys []IssuerSigningKey `json:"assertionKeys,omitempty" metadata:",optional"`
+	// Set once the issuer is revoked, with the policy for its credentials
+	Revocation *IssuerRevocation `json:"revocation,omitempty" metadata:",optional"`
 }
 
 // InitLedger adds a base set of issuers to the ledger
 func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
+	if err := assertAdmin(ctx); err != nil {
+		return err
+	}
+
 	err := s.addAuthorizedIssuers(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to add authorized issuers: %v", err)
 	}
 
+	// Both test network organizations have to approve new issuers
+	existing, err := ctx.GetStub().GetState(GovernanceKey)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existing == nil {
+		err = putGovernance(ctx, &Governance{AdminMSPIDs: []string{"Org1MSP", "Org2MSP"}, Threshold: 2})
+		if err != nil {
+			return fmt.Errorf("failed to initialize governance: %v", err)
+		}
+	}
+
 	return nil
 }
 
@@ -68,27 +101,62 @@ func (s *SmartContract) CreateCredential(ctx contractapi.TransactionContextInter
 		return "", fmt.Errorf("failed to unmarshal credential: %v", err)
 	}
 
-	credential.ID = CredentialKey + credential.ID
+	// Check if the issuer exists, is active and is the one submitting
+	issuer, err := s.readActiveIssuer(ctx, credential.IssuerID)
+	if err != nil {
+		return "", err
+	}
 
-	// Check if the issuer exists and is active
-	issuerKey := "ISSUER_" + credential.IssuerID
+	if err := assertIssuerOwner(ctx, issuer); err != nil {
+		return "", err
+	}
 
-	issuerBytes, err := ctx.GetStub().GetState(issuerKey)
+	id, err := s.issueCredential(ctx, issuer, &credential)
 	if err != nil {
-		return "", fmt.Errorf("failed to read issuer from ledger: %v", err)
+		return "", err
 	}
 
-	if issuerBytes == nil {
-		return "", fmt.Errorf("issuer %s does not exist", credential.IssuerID)
+	if err := emitCredentialEvent(ctx, EventCredentialIssued, id, &credential); err != nil {
+		return "", err
 	}
 
-	var issuer Issuer
-	if err := json.Unmarshal(issuerBytes, &issuer); err != nil {
-		return "", fmt.Errorf("failed to unmarshal issuer data: %v", err)
+	return id, nil
+}
+
+// issueCredential validates a new credential of an active issuer the caller acts for and writes it.
+// It returns the assigned ID and leaves emitting events to the calling transaction.
+func (s *SmartContract) issueCredential(ctx contractapi.TransactionContextInterface, issuer *Issuer, credential *Credential) (string, error) {
+	// IDs are derived from issuer, diploma hash and graduate key, a caller supplied ID must match
+	id := NewCredentialID(credential.IssuerID, credential.DiplomaHash, credential.GraduatePublicKey)
+	if credential.ID != "" && credential.ID != id {
+		return "", fmt.Errorf("credential id must be empty or %s", id)
 	}
+	credential.ID = CredentialKey + id
+	credential.LegacyID = ""
 
-	if issuer.Status != "Active" {
-		return "", fmt.Errorf("issuer %s is not active", credential.IssuerID)
+	// New credentials always start as valid, other statuses are reached through transitions
+	if credential.Status != "" && credential.Status != StatusValid {
+		return "", fmt.Errorf("new credentials must have status %s, got %s", StatusValid, credential.Status)
+	}
+	credential.Status = StatusValid
+	credential.Revocation = nil
+	credential.Suspension = nil
+
+	if err := validateHashScheme(credential); err != nil {
+		return "", err
+	}
+
+	key, err := activeSigningKey(issuer)
+	if err != nil {
+		return "", err
+	}
+	if err := verifyIssuerSignature(key.PublicKey, credential.DiplomaHash, credential.IssuerSignature); err != nil {
+		return "", fmt.Errorf("invalid issuer signature: %v", err)
+	}
+
+	credential.SignedAt, err = txTimestamp(ctx)
+	if err != nil {
+		return "", err
 	}
 
 	exists, err := s.CredentialExists(ctx, credential.ID)
@@ -99,21 +167,33 @@ func (s *SmartContract) CreateCredential(ctx contractapi.TransactionContextInter
 		return "", fmt.Errorf("the credential %s already exists", credential.ID)
 	}
 
-	credentialBytes, err := json.Marshal(credential)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal credential data: %v", err)
+	if err := s.putCredential(ctx, id, credential); err != nil {
+		return "", err
 	}
 
-	return credential.ID, ctx.GetStub().PutState(credential.ID, credentialBytes)
+	return id, nil
 }
 
 // ReadCredential returns the credential stored in the world state with given id.
+// IDs from before MigrateCredentialIDs resolve to the migrated credential.
 func (s *SmartContract) ReadCredential(ctx contractapi.TransactionContextInterface, id string) (*Credential, error) {
 	key := CredentialKey + id
 	credentialJSON, err := ctx.GetStub().GetState(key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read from world state: %v", err)
 	}
+	if credentialJSON == nil {
+		target, err := resolveCredentialAlias(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if target != "" {
+			credentialJSON, err = ctx.GetStub().GetState(CredentialKey + target)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read from world state: %v", err)
+			}
+		}
+	}
 	if credentialJSON == nil {
 		return nil, fmt.Errorf("the credential %s does not exist", id)
 	}
@@ -135,56 +215,94 @@ func (s *SmartContract) UpdateCredential(ctx contractapi.TransactionContextInter
 		return fmt.Errorf("failed to unmarshal credential: %v", err)
 	}
 
-	credential.ID = CredentialKey + credential.ID
+	// Check if the issuer exists, is active and is the one submitting
+	issuer, err := s.readActiveIssuer(ctx, credential.IssuerID)
+	if err != nil {
+		return err
+	}
 
-	// Check if the issuer exists and is active
-	issuerKey := "ISSUER_" + credential.IssuerID
+	if err := assertIssuerOwner(ctx, issuer); err != nil {
+		return err
+	}
 
-	issuerBytes, err := ctx.GetStub().GetState(issuerKey)
+	// The stored credential must belong to the same issuer, otherwise an issuer
+	// could take over another university's credential by rewriting issuerId
+	existing, err := s.ReadCredential(ctx, credential.ID)
 	if err != nil {
-		return fmt.Errorf("failed to read issuer from ledger: %v", err)
+		return err
+	}
+	if existing.IssuerID != issuer.ID {
+		return fmt.Errorf("the credential %s belongs to issuer %s", credential.ID, existing.IssuerID)
 	}
 
-	if issuerBytes == nil {
-		return fmt.Errorf("issuer %s does not exist", credential.IssuerID)
+	if err := validateHashScheme(&credential); err != nil {
+		return err
 	}
 
-	var issuer Issuer
-	if err := json.Unmarshal(issuerBytes, &issuer); err != nil {
-		return fmt.Errorf("failed to unmarshal issuer data: %v", err)
+	// An unchanged signature keeps the key it was made with, a new one has to match the active key
+	if credential.DiplomaHash == existing.DiplomaHash && credential.IssuerSignature == existing.IssuerSignature {
+		credential.SignedAt = existing.SignedAt
+	} else {
+		key, err := activeSigningKey(issuer)
+		if err != nil {
+			return err
+		}
+		if err := verifyIssuerSignature(key.PublicKey, credential.DiplomaHash, credential.IssuerSignature); err != nil {
+			return fmt.Errorf("invalid issuer signature: %v", err)
+		}
+
+		credential.SignedAt, err = txTimestamp(ctx)
+		if err != nil {
+			return err
+		}
 	}
 
-	if issuer.Status != "Active" {
-		return fmt.Errorf("issuer %s is not active", credential.IssuerID)
+	// Status, revocation and suspension details only change through their own transactions
+	if existing.Status == StatusRevoked {
+		return fmt.Errorf("the credential %s is revoked and can no longer be updated", credential.ID)
+	}
+	if credential.Status != "" && credential.Status != existing.Status {
+		return fmt.Errorf("status cannot be changed by UpdateCredential, use SuspendCredential, ReinstateCredential or RevokeCredential")
 	}
+	credential.Status = existing.Status
+	credential.Revocation = existing.Revocation
+	credential.Suspension = existing.Suspension
+	credential.LegacyID = existing.LegacyID
 
-	exists, err := s.CredentialExists(ctx, credential.ID)
+	// credential.ID may be an alias, always write the current key
+	id := credentialID(existing)
+	credential.ID = CredentialKey + id
+
+	return s.putCredential(ctx, id, &credential)
+}
+
+// DeleteCredential deletes an given credential from the world state.
+func (s *SmartContract) DeleteCredential(ctx contractapi.TransactionContextInterface, id string) error {
+	credential, err := s.ReadCredential(ctx, id)
 	if err != nil {
 		return err
 	}
-	if !exists {
-		return fmt.Errorf("the credential %s does not exist", credential.ID)
-	}
 
-	credentialBytes, err := json.Marshal(credential)
+	issuer, err := s.ReadIssuer(ctx, credential.IssuerID)
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState(credential.ID, credentialBytes)
-}
+	if err := assertIssuerOwner(ctx, issuer); err != nil {
+		return err
+	}
 
-// DeleteCredential deletes an given credential from the world state.
-func (s *SmartContract) DeleteCredential(ctx contractapi.TransactionContextInterface, id string) error {
-	exists, err := s.CredentialExists(ctx, id)
-	if err != nil {
+	id = credentialID(credential)
+	if err := ctx.GetStub().DelState(CredentialKey + id); err != nil {
 		return err
 	}
-	if !exists {
-		return fmt.Errorf("the credential %s does not exist", id)
+	if credential.LegacyID != "" {
+		if err := ctx.GetStub().DelState(CredentialAliasKey + credential.LegacyID); err != nil {
+			return err
+		}
 	}
 
-	return ctx.GetStub().DelState(id)
+	return updateCredentialIndexes(ctx, id, credential, nil)
 }
 
 // CredentialExists returns true when credential with given ID exists in world state
@@ -225,28 +343,52 @@ func (s *SmartContract) GetAllCredentials(ctx contractapi.TransactionContextInte
 	return credentials, nil
 }
 
-func (s *SmartContract) RevokeCredential(ctx contractapi.TransactionContextInterface, id string) error {
-	key := CredentialKey + id
+// RevokeCredential permanently invalidates a credential, recording the reason code,
+// an optional note, the transaction time and the identity of the revoking client.
+func (s *SmartContract) RevokeCredential(ctx contractapi.TransactionContextInterface, id string, reason string, note string) error {
+	reason, err := validateRevocationReason(reason)
+	if err != nil {
+		return err
+	}
 
-	data, err := ctx.GetStub().GetState(key)
+	credential, err := s.readOwnedCredential(ctx, id)
 	if err != nil {
 		return err
 	}
+	id = credentialID(credential)
 
-	if data == nil {
-		return fmt.Errorf("credential not found")
+	if err := checkTransition(credential.Status, StatusRevoked); err != nil {
+		return err
 	}
 
-	var credential Credential
-	if err := json.Unmarshal(data, &credential); err != nil {
+	caller, err := getCaller(ctx)
+	if err != nil {
+		return err
+	}
+
+	revokedAt, err := txTimestamp(ctx)
+	if err != nil {
 		return err
 	}
 
-	credential.Status = "Revoked"
+	// A suspension ends with the revocation, the record keeps only the terminal state
+	credential.Status = StatusRevoked
+	credential.Suspension = nil
+	credential.Revocation = &Revocation{
+		Reason:        reason,
+		Note:          note,
+		RevokedAt:     revokedAt,
+		RevokedBy:     caller.ID,
+		RevokerMSPID:  caller.MSPID,
+		RevokerIssuer: caller.IssuerID,
+		TxID:          ctx.GetStub().GetTxID(),
+	}
 
-	newData, _ := json.Marshal(credential)
-	
-	return ctx.GetStub().PutState(key, newData)
+	if err := s.putCredential(ctx, id, credential); err != nil {
+		return err
+	}
+
+	return emitCredentialEvent(ctx, EventCredentialRevoked, id, credential)
 }
 
 func (s *SmartContract) ReadIssuer(ctx contractapi.TransactionContextInterface, id string) (*Issuer, error) {
@@ -269,46 +411,38 @@ func (s *SmartContract) ReadIssuer(ctx contractapi.TransactionContextInterface,
 	return &issuer, nil
 }
 
-func (s *SmartContract) CreateIssuer(ctx contractapi.TransactionContextInterface, issuerJSON string) error {
-	var issuer Issuer
-	if err := json.Unmarshal([]byte(issuerJSON), &issuer); err != nil {
-		return err
-	}
-
-	key := IssuerKey + issuer.ID
-	exists, err := ctx.GetStub().GetState(key)
+// txTimestamp returns the client-supplied timestamp of the current transaction in RFC 3339 form.
+// It is identical on every endorsing peer, unlike the local clock.
+func txTimestamp(ctx contractapi.TransactionContextInterface) (string, error) {
+	ts, err := ctx.GetStub().GetTxTimestamp()
 	if err != nil {
-		return err
-	}
-	if exists != nil {
-		return fmt.Errorf("issuer %s already exists", issuer.ID)
+		return "", fmt.Errorf("failed to read transaction timestamp: %v", err)
 	}
 
-	data, err := json.Marshal(issuer)
-	if err != nil {
-		return err
-	}
-	return ctx.GetStub().PutState(key, data)
+	return time.Unix(ts.Seconds, int64(ts.Nanos)).UTC().Format(time.RFC3339), nil
 }
 
-func (s *SmartContract) RevokeIssuer(ctx contractapi.TransactionContextInterface, id string) error {
-	key := IssuerKey + id
-	data, err := ctx.GetStub().GetState(key)
+// readActiveIssuer returns the issuer with given id, failing when it is missing or revoked
+func (s *SmartContract) readActiveIssuer(ctx contractapi.TransactionContextInterface, id string) (*Issuer, error) {
+	issuer, err := s.ReadIssuer(ctx, id)
 	if err != nil {
-		return err
-	}
-	if data == nil {
-		return fmt.Errorf("issuer not found")
+		return nil, err
 	}
 
-	var issuer Issuer
-	if err := json.Unmarshal(data, &issuer); err != nil {
-		return err
+	if issuer.Status != "Active" {
+		return nil, fmt.Errorf("issuer %s is not active", id)
 	}
-	issuer.Status = "Revoked"
 
-	newData, _ := json.Marshal(issuer)
-	return ctx.GetStub().PutState(key, newData)
+	return issuer, nil
+}
+
+// putIssuer writes an issuer record under its ledger key
+func putIssuer(ctx contractapi.TransactionContextInterface, issuer *Issuer) error {
+	data, err := json.Marshal(issuer)
+	if err != nil {
+		return fmt.Errorf("failed to marshal issuer: %v", err)
+	}
+	return ctx.GetStub().PutState(IssuerKey+issuer.ID, data)
 }
 
 func (s *SmartContract) GetAllIssuers(ctx contractapi.TransactionContextInterface) ([]*Issuer, error) {
@@ -343,12 +477,14 @@ func (s *SmartContract) addAuthorizedIssuers(ctx contractapi.TransactionContextI
 		{
 			ID:        "lu",
 			Name:      "University of Latvia",
+			MSPID:     "Org1MSP",
 			Status:    "Active",
 			PublicKey: "MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEA5678...",
 		},
 		{
 			ID:        "rtu",
 			Name:      "Riga Technical University",
+			MSPID:     "Org2MSP",
 			Status:    "Active",
 			PublicKey: "MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEA5678...",
 		},
@@ -372,12 +508,16 @@ func (s *SmartContract) addAuthorizedIssuers(ctx contractapi.TransactionContextI
 }
 
 func (s *SmartContract) AddMockCredentials(ctx contractapi.TransactionContextInterface) error {
+	if err := assertAdmin(ctx); err != nil {
+		return err
+	}
+
 	credentials := []Credential{
 		{
 			ID:                "credential1",
 			DiplomaHash:       "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
 			GraduatePublicKey: "MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEA1234...",
-			IssuerID: "lu",
+			IssuerID:          "lu",
 			IssuerSignature:   "3045022100abcd...",
 			DiplomaMetadata: DiplomaMetadata{
 				UniversityName: "MIT",
@@ -385,14 +525,14 @@ func (s *SmartContract) AddMockCredentials(ctx contractapi.TransactionContextInt
 				IssueDate:      "2024-06-15",
 				ExpiryDate:     "",
 			},
-			Status:         "Valid",
+			Status:         StatusValid,
 			CredentialType: "Diploma",
 		},
 		{
 			ID:                "credential2",
 			DiplomaHash:       "d7a8fbb307d7809469ca9abcb0082e4f8d5651e46d3cdb762d02d0bf37c9e592",
 			GraduatePublicKey: "MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEA2345...",
-			IssuerID: "lu",
+			IssuerID:          "lu",
 			IssuerSignature:   "3046022100bcde...",
 			DiplomaMetadata: DiplomaMetadata{
 				UniversityName: "Stanford University",
@@ -400,14 +540,14 @@ func (s *SmartContract) AddMockCredentials(ctx contractapi.TransactionContextInt
 				IssueDate:      "2024-05-20",
 				ExpiryDate:     "",
 			},
-			Status:         "Valid",
+			Status:         StatusValid,
 			CredentialType: "Diploma",
 		},
 		{
 			ID:                "credential3",
 			DiplomaHash:       "6b86b273ff34fce19d6b804eff5a3f5747ada4eaa22f1d49c01e52ddb7875b4b",
 			GraduatePublicKey: "MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEA3456...",
-			IssuerID: "rtu",
+			IssuerID:          "rtu",
 			IssuerSignature:   "3045022100cdef...",
 			DiplomaMetadata: DiplomaMetadata{
 				UniversityName: "Seoul National University",
@@ -415,19 +555,13 @@ func (s *SmartContract) AddMockCredentials(ctx contractapi.TransactionContextInt
 				IssueDate:      "2024-08-10",
 				ExpiryDate:     "",
 			},
-			Status:         "Valid",
+			Status:         StatusValid,
 			CredentialType: "Diploma",
 		},
 	}
 
 	for _, credential := range credentials {
-		credentialJSON, err := json.Marshal(credential)
-		if err != nil {
-			return err
-		}
-
-		key := CredentialKey + credential.ID
-		err = ctx.GetStub().PutState(key, credentialJSON)
+		err := s.putCredential(ctx, credential.ID, &credential)
 		if err != nil {
 			return fmt.Errorf("failed to put to world state. %v", err)
 		}
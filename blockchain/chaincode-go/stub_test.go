@@ -0,0 +1,388 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+	"github.com/hyperledger/fabric-protos-go-apiv2/ledger/queryresult"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// testLedger is the world state of a channel in chaincode tests. Every invoke is one transaction:
+// like on a peer it reads the state committed before it, and its writes only become visible once
+// it succeeded.
+type testLedger struct {
+	t        *testing.T
+	contract *SmartContract
+	state    map[string][]byte
+	now      time.Time  // Timestamp of the next transaction
+	txCount  int        // Transactions invoked so far, numbers the transaction IDs
+	event    *testEvent // Last event of a committed transaction
+}
+
+type testEvent struct {
+	name    string
+	payload []byte
+}
+
+func newTestLedger(t *testing.T) *testLedger {
+	return &testLedger{
+		t:        t,
+		contract: &SmartContract{},
+		state:    map[string][]byte{},
+		now:      time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC),
+	}
+}
+
+// invoke runs fn as a transaction submitted by caller and commits its writes when it returns nil.
+// The clock moves on by a minute after every transaction.
+func (l *testLedger) invoke(caller *testIdentity, fn func(ctx contractapi.TransactionContextInterface) error) error {
+	l.txCount++
+	stub := &testStub{
+		ledger:    l,
+		txID:      fmt.Sprintf("tx%03d", l.txCount),
+		timestamp: l.now,
+		writes:    map[string][]byte{},
+	}
+	ctx := &contractapi.TransactionContext{}
+	ctx.SetStub(stub)
+	ctx.SetClientIdentity(caller)
+
+	err := fn(ctx)
+	l.now = l.now.Add(time.Minute)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range stub.writes {
+		if value == nil {
+			delete(l.state, key)
+		} else {
+			l.state[key] = value
+		}
+	}
+	if stub.event != nil {
+		l.event = stub.event
+	}
+	return nil
+}
+
+// mustInvoke is invoke for transactions the test expects to succeed
+func (l *testLedger) mustInvoke(caller *testIdentity, fn func(ctx contractapi.TransactionContextInterface) error) {
+	l.t.Helper()
+	if err := l.invoke(caller, fn); err != nil {
+		l.t.Fatal(err)
+	}
+}
+
+// credential reads a credential by ID, legacy IDs included
+func (l *testLedger) credential(id string) *Credential {
+	l.t.Helper()
+	var credential *Credential
+	l.mustInvoke(testReader, func(ctx contractapi.TransactionContextInterface) (err error) {
+		credential, err = l.contract.ReadCredential(ctx, id)
+		return err
+	})
+	return credential
+}
+
+// issuer reads an issuer record
+func (l *testLedger) issuer(id string) *Issuer {
+	l.t.Helper()
+	var issuer *Issuer
+	l.mustInvoke(testReader, func(ctx contractapi.TransactionContextInterface) (err error) {
+		issuer, err = l.contract.ReadIssuer(ctx, id)
+		return err
+	})
+	return issuer
+}
+
+// indexed reports whether a composite key index has the entry of value and credential id
+func (l *testLedger) indexed(index string, value string, id string) bool {
+	l.t.Helper()
+	key, err := shim.CreateCompositeKey(index, []string{value, id})
+	if err != nil {
+		l.t.Fatal(err)
+	}
+	_, ok := l.state[key]
+	return ok
+}
+
+// putLegacyCredential stores a credential under an ID from before NewCredentialID, the way
+// AddMockCredentials and earlier chaincode versions did
+func (l *testLedger) putLegacyCredential(credential Credential) {
+	l.t.Helper()
+	l.mustInvoke(testReader, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.putCredential(ctx, credential.ID, &credential)
+	})
+}
+
+// testStub implements the parts of the chaincode stub the contract uses on top of a testLedger
+type testStub struct {
+	shim.ChaincodeStubInterface // Methods the contract does not use panic
+
+	ledger    *testLedger
+	txID      string
+	timestamp time.Time
+	writes    map[string][]byte // A nil value deletes the key
+	event     *testEvent
+}
+
+func (s *testStub) GetState(key string) ([]byte, error) {
+	return s.ledger.state[key], nil
+}
+
+func (s *testStub) PutState(key string, value []byte) error {
+	if key == "" {
+		return fmt.Errorf("key must not be an empty string")
+	}
+	s.writes[key] = append([]byte{}, value...)
+	return nil
+}
+
+func (s *testStub) DelState(key string) error {
+	s.writes[key] = nil
+	return nil
+}
+
+// GetStateByRange refuses composite keys like the shim does
+func (s *testStub) GetStateByRange(startKey string, endKey string) (shim.StateQueryIteratorInterface, error) {
+	for _, key := range []string{startKey, endKey} {
+		if strings.HasPrefix(key, "\x00") {
+			return nil, fmt.Errorf("first character of the key [%s] contains a null character which is not allowed", key)
+		}
+	}
+	return s.rangeOf(startKey, endKey), nil
+}
+
+func (s *testStub) GetStateByPartialCompositeKey(objectType string, attributes []string) (shim.StateQueryIteratorInterface, error) {
+	prefix, err := shim.CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+	return s.rangeOf(prefix, prefix+string(utf8.MaxRune)), nil
+}
+
+// rangeOf returns the committed keys from startKey up to, not including, endKey in key order
+func (s *testStub) rangeOf(startKey string, endKey string) *testIterator {
+	var keys []string
+	for key := range s.ledger.state {
+		if key >= startKey && (endKey == "" || key < endKey) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	iterator := &testIterator{}
+	for _, key := range keys {
+		iterator.entries = append(iterator.entries, &queryresult.KV{Key: key, Value: s.ledger.state[key]})
+	}
+	return iterator
+}
+
+func (s *testStub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	return shim.CreateCompositeKey(objectType, attributes)
+}
+
+func (s *testStub) SplitCompositeKey(compositeKey string) (string, []string, error) {
+	components := strings.Split(strings.TrimSuffix(strings.TrimPrefix(compositeKey, "\x00"), "\x00"), "\x00")
+	return components[0], components[1:], nil
+}
+
+func (s *testStub) GetTxID() string {
+	return s.txID
+}
+
+func (s *testStub) GetTxTimestamp() (*timestamppb.Timestamp, error) {
+	return timestamppb.New(s.timestamp), nil
+}
+
+// SetEvent keeps only the last event, like a peer
+func (s *testStub) SetEvent(name string, payload []byte) error {
+	s.event = &testEvent{name: name, payload: payload}
+	return nil
+}
+
+type testIterator struct {
+	entries []*queryresult.KV
+}
+
+func (i *testIterator) HasNext() bool {
+	return len(i.entries) > 0
+}
+
+func (i *testIterator) Next() (*queryresult.KV, error) {
+	if len(i.entries) == 0 {
+		return nil, fmt.Errorf("no more results")
+	}
+	entry := i.entries[0]
+	i.entries = i.entries[1:]
+	return entry, nil
+}
+
+func (i *testIterator) Close() error {
+	return nil
+}
+
+// testIdentity is the client identity submitting a test transaction
+type testIdentity struct {
+	id       string
+	mspID    string
+	issuerID string // issuerId certificate attribute
+	admin    bool   // role=admin certificate attribute
+}
+
+// Organization admins of the test network and a client without attributes
+var (
+	org1Admin  = &testIdentity{id: "x509::CN=Admin@org1.example.com", mspID: "Org1MSP", admin: true}
+	org2Admin  = &testIdentity{id: "x509::CN=Admin@org2.example.com", mspID: "Org2MSP", admin: true}
+	org3Admin  = &testIdentity{id: "x509::CN=Admin@org3.example.com", mspID: "Org3MSP", admin: true}
+	testReader = &testIdentity{id: "x509::CN=User1@org1.example.com", mspID: "Org1MSP"}
+)
+
+func (i *testIdentity) GetID() (string, error) {
+	return i.id, nil
+}
+
+func (i *testIdentity) GetMSPID() (string, error) {
+	return i.mspID, nil
+}
+
+func (i *testIdentity) GetAttributeValue(name string) (string, bool, error) {
+	switch {
+	case name == IssuerIDAttribute && i.issuerID != "":
+		return i.issuerID, true, nil
+	case name == RoleAttribute && i.admin:
+		return AdminRole, true, nil
+	}
+	return "", false, nil
+}
+
+func (i *testIdentity) AssertAttributeValue(name string, value string) error {
+	if actual, _, _ := i.GetAttributeValue(name); actual != value {
+		return fmt.Errorf("attribute %s is %q, not %q", name, actual, value)
+	}
+	return nil
+}
+
+func (i *testIdentity) GetX509Certificate() (*x509.Certificate, error) {
+	return nil, nil
+}
+
+// testIssuer is an active issuer with its private signing key and a client acting for it
+type testIssuer struct {
+	id     string
+	key    *ecdsa.PrivateKey
+	client *testIdentity
+}
+
+// newTestKey returns a P-256 key pair with the public key PEM encoded
+func newTestKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// addIssuer writes an active issuer of mspID to the state, as an approved proposal would
+func (l *testLedger) addIssuer(id string, mspID string) *testIssuer {
+	l.t.Helper()
+	key, publicKey := newTestKey(l.t)
+	signingKey, err := newSigningKey(publicKey, l.now.Add(-time.Hour).Format(time.RFC3339))
+	if err != nil {
+		l.t.Fatal(err)
+	}
+	data, err := json.Marshal(&Issuer{
+		ID:        id,
+		Name:      strings.ToUpper(id),
+		MSPID:     mspID,
+		Status:    "Active",
+		PublicKey: publicKey,
+		Keys:      []IssuerSigningKey{*signingKey},
+	})
+	if err != nil {
+		l.t.Fatal(err)
+	}
+	l.state[IssuerKey+id] = data
+
+	return &testIssuer{
+		id:     id,
+		key:    key,
+		client: &testIdentity{id: "x509::CN=" + id + "@example.com", mspID: mspID, issuerID: id},
+	}
+}
+
+// signHash signs a diploma hash with key the way issuers do, hex encoded ASN.1 ECDSA over its SHA-256
+func signHash(t *testing.T, key *ecdsa.PrivateKey, diplomaHash string) string {
+	t.Helper()
+	digest := sha256.Sum256([]byte(diplomaHash))
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(signature)
+}
+
+// testDiplomaHash returns a distinct diploma hash for every n
+func testDiplomaHash(n int) string {
+	digest := sha256.Sum256([]byte(fmt.Sprintf("diploma %d", n)))
+	return hex.EncodeToString(digest[:])
+}
+
+// newTestCredential returns a credential of issuer for diploma n, signed with its current key
+func (i *testIssuer) newTestCredential(t *testing.T, n int) *Credential {
+	t.Helper()
+	diplomaHash := testDiplomaHash(n)
+	return &Credential{
+		DiplomaHash:       diplomaHash,
+		GraduatePublicKey: fmt.Sprintf("graduate %d", n),
+		IssuerID:          i.id,
+		IssuerSignature:   signHash(t, i.key, diplomaHash),
+		DiplomaMetadata: DiplomaMetadata{
+			UniversityName: strings.ToUpper(i.id),
+			DegreeName:     "Computer Science",
+			IssueDate:      "2025-06-20",
+		},
+		CredentialType: "Bachelor",
+	}
+}
+
+// issue creates credential n of issuer through CreateCredential and returns its ID
+func (l *testLedger) issue(issuer *testIssuer, n int) string {
+	l.t.Helper()
+	credentialJSON, err := json.Marshal(issuer.newTestCredential(l.t, n))
+	if err != nil {
+		l.t.Fatal(err)
+	}
+
+	var id string
+	l.mustInvoke(issuer.client, func(ctx contractapi.TransactionContextInterface) (err error) {
+		id, err = l.contract.CreateCredential(ctx, string(credentialJSON))
+		return err
+	})
+	return id
+}
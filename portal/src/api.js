@@ -1,7 +1,7 @@
 import axios from 'axios';
 import { APP_CONFIG } from '@/constants';
 import { exceptionInterceptor } from '@/interceptors/exceptionInterceptor';
-import { getSessionKey } from '@/utils/sessionUtils';
+import { getRefreshKey, getSessionKey, setRefreshKey, setSessionKey } from '@/utils/sessionUtils';
 
 /**
  *
@@ -17,10 +17,27 @@ export default (options = {}) => {
     headers: {
       Accept: 'application/json',
       'Content-Type': 'application/json',
-      // Authorization: `Bearer ${getSessionKey()}`,
-      'X-API-Key': 'test',
     },
   });
+  // The gateway access token is short lived, on 401 it is renewed once with the refresh token
+  http.interceptors.request.use((config) => {
+    const token = getSessionKey();
+    if (token) {
+      config.headers.Authorization = `Bearer ${token}`;
+    }
+    return config;
+  });
+  http.interceptors.response.use(null, async (error) => {
+    const { config, response } = error;
+    const refreshToken = getRefreshKey();
+    if (response?.status !== 401 || !refreshToken || config.retried || config.url === '/auth/refresh') {
+      return Promise.reject(error);
+    }
+    const refreshed = await http.post('/auth/refresh', { refreshToken });
+    setSessionKey(refreshed.data.accessToken);
+    setRefreshKey(refreshed.data.refreshToken);
+    return http({ ...config, retried: true });
+  });
   // http.interceptors.response.use(null, useExceptionInterceptor && exceptionInterceptor);
   return http;
 };
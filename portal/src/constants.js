@@ -16,6 +16,7 @@ export const APP_CONFIG = {
 };
 
 export const AUTH_KEY_TOKEN_SESSION = 'blockchain-sessionkey';
+export const AUTH_KEY_REFRESH_SESSION = 'blockchain-refreshkey';
 export const SYSTEM_NAME = 'blockchain';
 export const AUTH_SCOPE = 'vpm';
 export const AUTH_TYPE = 'VPM';
@@ -16,6 +16,10 @@ export function getCredentialList(id) {
   return api().get('/credentials', { params: { university: id } });
 }
 
-export function revokeCredential(id) {
-  return api().patch(`/credential/${id}/revoke`);
+export function revokeCredential(id, reason = 'withdrawn', note = '') {
+  return api().patch(`/credential/${id}/revoke`, { reason, note });
+}
+
+export function getTransactionStatus(txId) {
+  return api().get(`/transactions/${txId}`);
 }
@@ -1,4 +1,4 @@
-import { AUTH_KEY_TOKEN_SESSION } from '@/constants';
+import { AUTH_KEY_REFRESH_SESSION, AUTH_KEY_TOKEN_SESSION } from '@/constants';
 
 const storage = () => sessionStorage;
 
@@ -9,3 +9,11 @@ export const setSessionKey = (key) => {
 export const getSessionKey = () => storage().getItem(AUTH_KEY_TOKEN_SESSION);
 
 export const removeSessionKey = () => storage().removeItem(AUTH_KEY_TOKEN_SESSION);
+
+export const setRefreshKey = (key) => {
+  storage().setItem(AUTH_KEY_REFRESH_SESSION, key);
+};
+
+export const getRefreshKey = () => storage().getItem(AUTH_KEY_REFRESH_SESSION);
+
+export const removeRefreshKey = () => storage().removeItem(AUTH_KEY_REFRESH_SESSION);